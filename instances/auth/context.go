@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// CodeUnauthenticated - код ошибки GraphQL для запросов без действительного токена
+const CodeUnauthenticated = "UNAUTHENTICATED"
+
+// publicFields - корневые поля, доступные без токена: роутер федерации
+// запрашивает _service при сборке схемы
+var publicFields = map[string]bool{
+	"_service":   true,
+	"__typename": true,
+}
+
+type claimsKey struct{}
+
+// WithClaims добавляет claims вызывающего в контекст
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext возвращает claims вызывающего, если запрос прошел проверку
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// UserIDFromContext возвращает ID вызывающего или nil, если claims нет, то есть
+// при отключенной аутентификации
+func UserIDFromContext(ctx context.Context) *string {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return &claims.UserID
+}
+
+// Middleware проверяет bearer-токен из заголовка Authorization. Запросы без
+// заголовка пропускаются: websocket-клиенты передают токен в connection_init,
+// а операции без токена отклоняет RequireClaims
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		claims, err := v.verifyBearer(r.Context(), header)
+		if err != nil {
+			writeUnauthenticated(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+	})
+}
+
+// WebsocketInit проверяет токен из поля Authorization сообщения
+// connection_init. Соединение, открытое с заголовком Authorization, уже
+// проверено Middleware и может не передавать токен повторно
+func (v *Verifier) WebsocketInit(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	header := payload.Authorization()
+	if header == "" {
+		if _, ok := ClaimsFromContext(ctx); ok {
+			return ctx, nil, nil
+		}
+		return nil, nil, fmt.Errorf("authorization token is required")
+	}
+
+	claims, err := v.verifyBearer(ctx, header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return WithClaims(ctx, claims), nil, nil
+}
+
+// RequireClaims отклоняет операции без проверенного токена, кроме запросов
+// только к publicFields. Подключается через AroundOperations
+func RequireClaims(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	if _, ok := ClaimsFromContext(ctx); ok || isPublic(graphql.GetOperationContext(ctx)) {
+		return next(ctx)
+	}
+
+	return graphql.OneShot(&graphql.Response{
+		Errors: gqlerror.List{unauthenticated(fmt.Errorf("authorization token is required"))},
+	})
+}
+
+// verifyBearer проверяет значение заголовка вида "Bearer <token>"
+func (v *Verifier) verifyBearer(ctx context.Context, header string) (*Claims, error) {
+	scheme, token, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, fmt.Errorf("authorization must be a bearer token")
+	}
+
+	return v.Verify(ctx, strings.TrimSpace(token))
+}
+
+// isPublic сообщает, обращается ли операция только к publicFields
+func isPublic(opCtx *graphql.OperationContext) bool {
+	if opCtx == nil || opCtx.Operation == nil || len(opCtx.Operation.SelectionSet) == 0 {
+		return false
+	}
+
+	for _, field := range graphql.CollectFields(opCtx, opCtx.Operation.SelectionSet, nil) {
+		if !publicFields[field.Name] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// unauthenticated оборачивает ошибку проверки токена в ошибку GraphQL
+func unauthenticated(err error) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message:    err.Error(),
+		Extensions: map[string]interface{}{"code": CodeUnauthenticated},
+	}
+}
+
+// writeUnauthenticated отвечает на HTTP-запрос с недействительным токеном
+func writeUnauthenticated(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(&graphql.Response{Errors: gqlerror.List{unauthenticated(err)}})
+}
@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// leeway - допустимое расхождение часов при проверке exp и nbf
+	leeway = time.Minute
+	// refreshInterval ограничивает повторную загрузку JWKS при неизвестном kid
+	refreshInterval = time.Minute
+	// fetchTimeout - таймаут загрузки JWKS по URL
+	fetchTimeout = 10 * time.Second
+)
+
+// hashes сопоставляет поддерживаемые алгоритмы подписи хеш-функциям
+var hashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256,
+	"HS384": crypto.SHA384,
+	"HS512": crypto.SHA512,
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+// Options задает источники ключей и ожидаемые значения claims
+type Options struct {
+	// JWKS - путь к файлу или http(s) URL набора ключей
+	JWKS string
+	// HMACSecret - общий секрет для HS256, HS384 и HS512
+	HMACSecret string
+	// RSAPublicKeyFile - PEM-файл с открытым ключом или сертификатом для
+	// RS256, RS384 и RS512
+	RSAPublicKeyFile string
+	// Issuer и Audience проверяются, если заданы
+	Issuer   string
+	Audience string
+}
+
+// Claims содержит сведения о вызывающем из проверенного токена
+type Claims struct {
+	Subject string
+	// UserID берется из claim user_id, а без него - из sub
+	UserID string
+	// ProjectIDs берутся из claim project_ids или project_id
+	ProjectIDs []string
+	ExpiresAt  time.Time
+}
+
+// rawClaims - claims токена в том виде, в котором они подписаны
+type rawClaims struct {
+	Subject    string          `json:"sub"`
+	Issuer     string          `json:"iss"`
+	Audience   json.RawMessage `json:"aud"`
+	ExpiresAt  *float64        `json:"exp"`
+	NotBefore  *float64        `json:"nbf"`
+	UserID     string          `json:"user_id"`
+	ProjectIDs []string        `json:"project_ids"`
+	ProjectID  string          `json:"project_id"`
+}
+
+// Verifier проверяет подпись и срок действия JWT
+type Verifier struct {
+	opts   Options
+	client *http.Client
+
+	// static - ключи из HMACSecret и RSAPublicKeyFile
+	static []key
+
+	mu sync.RWMutex
+	// jwks - ключи из набора; перечитываются, когда приходит неизвестный kid
+	jwks    []key
+	fetched time.Time
+}
+
+// NewVerifier загружает ключи. Нужен хотя бы один источник ключей
+func NewVerifier(opts Options) (*Verifier, error) {
+	v := &Verifier{
+		opts:   opts,
+		client: &http.Client{Timeout: fetchTimeout},
+	}
+
+	if opts.HMACSecret != "" {
+		v.static = append(v.static, key{secret: []byte(opts.HMACSecret)})
+	}
+
+	if opts.RSAPublicKeyFile != "" {
+		content, err := os.ReadFile(opts.RSAPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read RSA public key: %v", err)
+		}
+		public, err := parseRSAPublicKey(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load RSA public key %s: %v", opts.RSAPublicKeyFile, err)
+		}
+		v.static = append(v.static, key{public: public})
+	}
+
+	if opts.JWKS != "" {
+		if err := v.refresh(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(v.static) == 0 && opts.JWKS == "" {
+		return nil, fmt.Errorf("no JWT keys configured")
+	}
+
+	return v, nil
+}
+
+// Verify проверяет токен и возвращает его claims
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header")
+	}
+
+	hash, supported := hashes[header.Alg]
+	if !supported {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature")
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	if !v.verifySignature(ctx, header.Alg, header.Kid, hash, signed, signature) {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+
+	var raw rawClaims
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("malformed token claims")
+	}
+
+	return v.validate(raw)
+}
+
+// verifySignature проверяет подпись ключами, подходящими по kid и алгоритму.
+// Неизвестный kid приводит к повторной загрузке JWKS
+func (v *Verifier) verifySignature(ctx context.Context, alg, kid string, hash crypto.Hash, signed, signature []byte) bool {
+	v.mu.RLock()
+	keys := append(append([]key(nil), v.static...), v.jwks...)
+	stale := time.Since(v.fetched) > refreshInterval
+	v.mu.RUnlock()
+
+	matched := false
+	for _, candidate := range keys {
+		if kid != "" && candidate.id != "" && candidate.id != kid {
+			continue
+		}
+		if candidate.id == kid {
+			matched = true
+		}
+		if verifyWith(candidate, alg, hash, signed, signature) {
+			return true
+		}
+	}
+
+	if kid == "" || matched || v.opts.JWKS == "" || !stale {
+		return false
+	}
+
+	if err := v.refresh(ctx); err != nil {
+		fmt.Printf("Warning: failed to refresh JWKS: %v\n", err)
+		return false
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	for _, candidate := range v.jwks {
+		if candidate.id == kid && verifyWith(candidate, alg, hash, signed, signature) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyWith проверяет подпись одним ключом. Секрет HMAC не принимается для
+// RS-алгоритмов и наоборот, поэтому открытый ключ нельзя выдать за секрет
+func verifyWith(k key, alg string, hash crypto.Hash, signed, signature []byte) bool {
+	if k.alg != "" && k.alg != alg {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(alg, "HS") && k.secret != nil:
+		mac := hmac.New(hash.New, k.secret)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case strings.HasPrefix(alg, "RS") && k.public != nil:
+		digest := hash.New()
+		digest.Write(signed)
+		return rsa.VerifyPKCS1v15(k.public, hash, digest.Sum(nil), signature) == nil
+	}
+
+	return false
+}
+
+// validate проверяет срок действия, издателя и получателя токена
+func (v *Verifier) validate(raw rawClaims) (*Claims, error) {
+	now := time.Now()
+
+	if raw.ExpiresAt == nil {
+		return nil, fmt.Errorf("token has no expiration time")
+	}
+	expiresAt := time.Unix(int64(*raw.ExpiresAt), 0)
+	if now.After(expiresAt.Add(leeway)) {
+		return nil, fmt.Errorf("token has expired")
+	}
+	if raw.NotBefore != nil && now.Add(leeway).Before(time.Unix(int64(*raw.NotBefore), 0)) {
+		return nil, fmt.Errorf("token is not valid yet")
+	}
+
+	if v.opts.Issuer != "" && raw.Issuer != v.opts.Issuer {
+		return nil, fmt.Errorf("unexpected token issuer %q", raw.Issuer)
+	}
+	if v.opts.Audience != "" && !hasAudience(raw.Audience, v.opts.Audience) {
+		return nil, fmt.Errorf("token is not issued for %s", v.opts.Audience)
+	}
+
+	claims := &Claims{
+		Subject:    raw.Subject,
+		UserID:     raw.UserID,
+		ProjectIDs: raw.ProjectIDs,
+		ExpiresAt:  expiresAt,
+	}
+	if claims.UserID == "" {
+		claims.UserID = raw.Subject
+	}
+	if len(claims.ProjectIDs) == 0 && raw.ProjectID != "" {
+		claims.ProjectIDs = []string{raw.ProjectID}
+	}
+	if claims.UserID == "" {
+		return nil, fmt.Errorf("token has no subject")
+	}
+
+	return claims, nil
+}
+
+// refresh загружает набор ключей из файла или по URL
+func (v *Verifier) refresh(ctx context.Context) error {
+	var content []byte
+	var err error
+	if strings.HasPrefix(v.opts.JWKS, "http://") || strings.HasPrefix(v.opts.JWKS, "https://") {
+		content, err = v.fetch(ctx)
+	} else {
+		content, err = os.ReadFile(v.opts.JWKS)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.fetched = time.Now()
+
+	if err != nil {
+		return fmt.Errorf("failed to load JWKS %s: %v", v.opts.JWKS, err)
+	}
+
+	keys, err := parseJWKS(content)
+	if err != nil {
+		return err
+	}
+	v.jwks = keys
+
+	return nil
+}
+
+// fetch скачивает набор ключей по URL
+func (v *Verifier) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.opts.JWKS, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// decodeSegment декодирует часть токена в формате base64url JSON
+func decodeSegment(segment string, target interface{}) error {
+	content, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, target)
+}
+
+// hasAudience проверяет claim aud, который может быть строкой или списком
+func hasAudience(raw json.RawMessage, audience string) bool {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return single == audience
+	}
+
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		for _, value := range list {
+			if value == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+// sign builds a token from the header and claims, signing the encoded part
+// with signer.
+func sign(t *testing.T, header, claims map[string]interface{}, signer func(signed []byte) []byte) string {
+	t.Helper()
+
+	encode := func(value interface{}) string {
+		content, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(content)
+	}
+
+	signed := encode(header) + "." + encode(claims)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signer([]byte(signed)))
+}
+
+func hmacSigner(secret []byte) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		mac := hmac.New(crypto.SHA256.New, secret)
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+}
+
+func rsaSigner(t *testing.T, private *rsa.PrivateKey) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		digest := crypto.SHA256.New()
+		digest.Write(signed)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, private, crypto.SHA256, digest.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+}
+
+func TestVerify(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	publicFile := filepath.Join(t.TempDir(), "public.pem")
+	if err := os.WriteFile(publicFile, publicPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	hmacVerifier, err := NewVerifier(Options{HMACSecret: testSecret, Issuer: "issuer", Audience: "instances"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaVerifier, err := NewVerifier(Options{RSAPublicKeyFile: publicFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		result := map[string]interface{}{
+			"sub": "user-1",
+			"iss": "issuer",
+			"aud": []string{"other", "instances"},
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for name, value := range overrides {
+			if value == nil {
+				delete(result, name)
+			} else {
+				result[name] = value
+			}
+		}
+		return result
+	}
+	hs256 := map[string]interface{}{"alg": "HS256", "typ": "JWT"}
+	rs256 := map[string]interface{}{"alg": "RS256", "typ": "JWT"}
+
+	tests := []struct {
+		name     string
+		verifier *Verifier
+		token    string
+		wantErr  string
+	}{
+		{
+			name:     "valid HS256",
+			verifier: hmacVerifier,
+			token:    sign(t, hs256, claims(nil), hmacSigner([]byte(testSecret))),
+		},
+		{
+			name:     "valid RS256",
+			verifier: rsaVerifier,
+			token:    sign(t, rs256, claims(nil), rsaSigner(t, private)),
+		},
+		{
+			name:     "expired within leeway",
+			verifier: hmacVerifier,
+			token:    sign(t, hs256, claims(map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()}), hmacSigner([]byte(testSecret))),
+		},
+		{
+			name:     "expired",
+			verifier: hmacVerifier,
+			token:    sign(t, hs256, claims(map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()}), hmacSigner([]byte(testSecret))),
+			wantErr:  "token has expired",
+		},
+		{
+			name:     "no expiration",
+			verifier: hmacVerifier,
+			token:    sign(t, hs256, claims(map[string]interface{}{"exp": nil}), hmacSigner([]byte(testSecret))),
+			wantErr:  "token has no expiration time",
+		},
+		{
+			name:     "not valid yet",
+			verifier: hmacVerifier,
+			token:    sign(t, hs256, claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()}), hmacSigner([]byte(testSecret))),
+			wantErr:  "token is not valid yet",
+		},
+		{
+			name:     "wrong secret",
+			verifier: hmacVerifier,
+			token:    sign(t, hs256, claims(nil), hmacSigner([]byte("other-secret"))),
+			wantErr:  "invalid token signature",
+		},
+		{
+			name:     "alg none",
+			verifier: hmacVerifier,
+			token:    sign(t, map[string]interface{}{"alg": "none"}, claims(nil), func([]byte) []byte { return nil }),
+			wantErr:  "unsupported signing algorithm",
+		},
+		{
+			name:     "unsupported alg",
+			verifier: hmacVerifier,
+			token:    sign(t, map[string]interface{}{"alg": "ES256"}, claims(nil), hmacSigner([]byte(testSecret))),
+			wantErr:  "unsupported signing algorithm",
+		},
+		{
+			name:     "RSA public key used as HMAC secret",
+			verifier: rsaVerifier,
+			token:    sign(t, hs256, claims(nil), hmacSigner(publicPEM)),
+			wantErr:  "invalid token signature",
+		},
+		{
+			name:     "HMAC signature under RS256 header",
+			verifier: hmacVerifier,
+			token:    sign(t, rs256, claims(nil), hmacSigner([]byte(testSecret))),
+			wantErr:  "invalid token signature",
+		},
+		{
+			name:     "wrong issuer",
+			verifier: hmacVerifier,
+			token:    sign(t, hs256, claims(map[string]interface{}{"iss": "someone"}), hmacSigner([]byte(testSecret))),
+			wantErr:  "unexpected token issuer",
+		},
+		{
+			name:     "wrong audience",
+			verifier: hmacVerifier,
+			token:    sign(t, hs256, claims(map[string]interface{}{"aud": "other"}), hmacSigner([]byte(testSecret))),
+			wantErr:  "token is not issued for instances",
+		},
+		{
+			name:     "no subject",
+			verifier: hmacVerifier,
+			token:    sign(t, hs256, claims(map[string]interface{}{"sub": nil}), hmacSigner([]byte(testSecret))),
+			wantErr:  "token has no subject",
+		},
+		{
+			name:     "malformed",
+			verifier: hmacVerifier,
+			token:    "not-a-token",
+			wantErr:  "malformed token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.verifier.Verify(context.Background(), tt.token)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Verify() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if got.UserID != "user-1" {
+				t.Errorf("Verify() UserID = %q, want %q", got.UserID, "user-1")
+			}
+		})
+	}
+}
+
+func TestVerifyProjectClaims(t *testing.T) {
+	verifier, err := NewVerifier(Options{HMACSecret: testSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name         string
+		claims       map[string]interface{}
+		wantUserID   string
+		wantProjects []string
+	}{
+		{
+			name:       "user_id takes precedence over sub",
+			claims:     map[string]interface{}{"sub": "subject", "user_id": "user-1", "exp": exp},
+			wantUserID: "user-1",
+		},
+		{
+			name:         "single project_id",
+			claims:       map[string]interface{}{"sub": "user-1", "project_id": "project-1", "exp": exp},
+			wantUserID:   "user-1",
+			wantProjects: []string{"project-1"},
+		},
+		{
+			name:         "project_ids take precedence over project_id",
+			claims:       map[string]interface{}{"sub": "user-1", "project_id": "project-1", "project_ids": []string{"project-2", "project-3"}, "exp": exp},
+			wantUserID:   "user-1",
+			wantProjects: []string{"project-2", "project-3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := sign(t, map[string]interface{}{"alg": "HS256"}, tt.claims, hmacSigner([]byte(testSecret)))
+			got, err := verifier.Verify(context.Background(), token)
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if got.UserID != tt.wantUserID {
+				t.Errorf("Verify() UserID = %q, want %q", got.UserID, tt.wantUserID)
+			}
+			if strings.Join(got.ProjectIDs, ",") != strings.Join(tt.wantProjects, ",") {
+				t.Errorf("Verify() ProjectIDs = %v, want %v", got.ProjectIDs, tt.wantProjects)
+			}
+		})
+	}
+}
+
+func TestNewVerifierRequiresKeys(t *testing.T) {
+	if _, err := NewVerifier(Options{}); err == nil {
+		t.Fatal("NewVerifier() without keys succeeded")
+	}
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+)
+
+// key - ключ проверки подписи: секрет HMAC или открытый ключ RSA
+type key struct {
+	// id совпадает с kid из заголовка токена; у ключей не из JWKS он пустой
+	id string
+	// alg ограничивает алгоритм ключа; пустое значение допускает любой
+	// алгоритм семейства
+	alg    string
+	secret []byte
+	public *rsa.PublicKey
+}
+
+// jwk - ключ из набора JWKS (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// parseJWKS разбирает набор ключей. Ключи не для подписи и ключи
+// неподдерживаемых типов пропускаются
+func parseJWKS(content []byte) ([]key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %v", err)
+	}
+
+	keys := make([]key, 0, len(set.Keys))
+	for _, entry := range set.Keys {
+		if entry.Use != "" && entry.Use != "sig" {
+			continue
+		}
+
+		switch entry.Kty {
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(entry.K)
+			if err != nil || len(secret) == 0 {
+				return nil, fmt.Errorf("JWKS key %q has invalid k", entry.Kid)
+			}
+			keys = append(keys, key{id: entry.Kid, alg: entry.Alg, secret: secret})
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(entry.N)
+			e, errE := base64.RawURLEncoding.DecodeString(entry.E)
+			if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+				return nil, fmt.Errorf("JWKS key %q has invalid n or e", entry.Kid)
+			}
+			public := &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+			keys = append(keys, key{id: entry.Kid, alg: entry.Alg, public: public})
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS has no signing keys")
+	}
+
+	return keys, nil
+}
+
+// parseRSAPublicKey разбирает открытый ключ RSA или сертификат в формате PEM
+func parseRSAPublicKey(content []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	var public interface{}
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			public = cert.PublicKey
+		}
+	default:
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %v", err)
+	}
+
+	rsaKey, ok := public.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is %T, not RSA", public)
+	}
+
+	return rsaKey, nil
+}
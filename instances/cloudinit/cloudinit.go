@@ -0,0 +1,170 @@
+package cloudinit
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// header - обязательная первая строка документа cloud-config
+const header = "#cloud-config"
+
+// packageManagerKeys связывает модули cloud-init с менеджером пакетов, для
+// которого они работают
+var packageManagerKeys = map[string]string{
+	"apt":       "apt",
+	"yum_repos": "dnf",
+	"zypper":    "zypper",
+}
+
+// Config описывает VM, для которой собирается cloud-init
+type Config struct {
+	Hostname string
+	Profile  Profile
+	// SSHKeys содержит проверенные публичные ключи пользователя
+	SSHKeys []string
+	// PasswordHash - хэш пароля пользователя; без него вход по паролю запрещен
+	PasswordHash string
+	// UserData - пользовательский документ cloud-config, который дополняет
+	// настройки по умолчанию
+	UserData string
+}
+
+// Compose собирает документ cloud-config из настроек профиля образа и
+// пользовательских данных. Списки объединяются, словари сливаются рекурсивно,
+// а скалярные значения пользователя заменяют значения по умолчанию
+func Compose(config Config) (string, error) {
+	document := defaults(config)
+
+	if strings.TrimSpace(config.UserData) != "" {
+		userData, err := parseUserData(config.UserData)
+		if err != nil {
+			return "", err
+		}
+
+		if err := checkPackageManager(userData, config.Profile); err != nil {
+			return "", err
+		}
+
+		document = merge(document, userData)
+	}
+
+	out, err := yaml.Marshal(document)
+	if err != nil {
+		return "", fmt.Errorf("failed to render cloud-init: %v", err)
+	}
+
+	// Проверяем, что итоговый документ разбирается как YAML
+	var check map[string]interface{}
+	if err := yaml.Unmarshal(out, &check); err != nil {
+		return "", fmt.Errorf("generated cloud-init is not valid YAML: %v", err)
+	}
+
+	return header + "\n" + string(out), nil
+}
+
+// defaults возвращает настройки cloud-init по умолчанию для профиля
+func defaults(config Config) map[string]interface{} {
+	user := map[string]interface{}{
+		"name":        config.Profile.DefaultUser,
+		"sudo":        "ALL=(ALL) NOPASSWD:ALL",
+		"groups":      strings.Join(config.Profile.Groups, ", "),
+		"shell":       "/bin/bash",
+		"lock_passwd": config.PasswordHash == "",
+	}
+	if config.PasswordHash != "" {
+		user["passwd"] = config.PasswordHash
+	}
+	if len(config.SSHKeys) > 0 {
+		user["ssh_authorized_keys"] = toList(config.SSHKeys)
+	}
+
+	return map[string]interface{}{
+		"hostname":        config.Hostname,
+		"fqdn":            config.Hostname + ".local",
+		"users":           []interface{}{user},
+		"timezone":        "UTC",
+		"locale":          "en_US.UTF-8",
+		"packages":        toList(config.Profile.Packages),
+		"package_update":  true,
+		"package_upgrade": true,
+		"runcmd": []interface{}{
+			"systemctl enable --now qemu-guest-agent",
+		},
+		// Пароль нужен только для входа через консоль
+		"ssh_pwauth": false,
+	}
+}
+
+// parseUserData разбирает пользовательский документ cloud-config
+func parseUserData(userData string) (map[string]interface{}, error) {
+	trimmed := strings.TrimSpace(userData)
+	if strings.HasPrefix(trimmed, "#!") {
+		return nil, fmt.Errorf("user data must be a %s document, scripts are not supported", header)
+	}
+
+	var document map[string]interface{}
+	if err := yaml.Unmarshal([]byte(trimmed), &document); err != nil {
+		return nil, fmt.Errorf("user data is not valid YAML: %v", err)
+	}
+	if document == nil {
+		return nil, fmt.Errorf("user data must be a YAML mapping")
+	}
+
+	return document, nil
+}
+
+// checkPackageManager отклоняет модули cloud-init, которые не работают
+// с менеджером пакетов образа
+func checkPackageManager(userData map[string]interface{}, profile Profile) error {
+	for key, packageManager := range packageManagerKeys {
+		if _, exists := userData[key]; exists && packageManager != profile.PackageManager {
+			return fmt.Errorf("user data key %q requires %s, but image %s uses %s", key, packageManager, profile.Name, profile.PackageManager)
+		}
+	}
+
+	return nil
+}
+
+// merge сливает пользовательские настройки с настройками по умолчанию
+func merge(base, override map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(base)+len(override))
+	for key, value := range base {
+		result[key] = value
+	}
+
+	for key, value := range override {
+		existing, exists := result[key]
+		if !exists {
+			result[key] = value
+			continue
+		}
+
+		switch existingValue := existing.(type) {
+		case map[string]interface{}:
+			if overrideMap, ok := value.(map[string]interface{}); ok {
+				result[key] = merge(existingValue, overrideMap)
+				continue
+			}
+		case []interface{}:
+			if overrideList, ok := value.([]interface{}); ok {
+				result[key] = append(append([]interface{}{}, existingValue...), overrideList...)
+				continue
+			}
+		}
+
+		result[key] = value
+	}
+
+	return result
+}
+
+// toList преобразует список строк в список YAML
+func toList(values []string) []interface{} {
+	list := make([]interface{}, len(values))
+	for i, value := range values {
+		list[i] = value
+	}
+	return list
+}
@@ -0,0 +1,161 @@
+package cloudinit
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	// passwordLength определяет длину генерируемого пароля
+	passwordLength = 20
+
+	// saltLength определяет длину соли sha512-crypt
+	saltLength = 16
+
+	// sha512Rounds - число раундов sha512-crypt по умолчанию, в хэше не указывается
+	sha512Rounds = 5000
+)
+
+// passwordAlphabet содержит символы пароля; похожие символы исключены, так как
+// пароль вводится вручную в консоли VM
+const passwordAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// cryptAlphabet - алфавит base64, который использует crypt(3)
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// GeneratePassword создает случайный пароль
+func GeneratePassword() (string, error) {
+	return randomString(passwordAlphabet, passwordLength)
+}
+
+// HashPassword возвращает хэш пароля в формате sha512-crypt ($6$), который
+// понимает поле passwd в cloud-init
+func HashPassword(password string) (string, error) {
+	salt, err := randomString(cryptAlphabet, saltLength)
+	if err != nil {
+		return "", err
+	}
+
+	return sha512Crypt(password, salt), nil
+}
+
+// randomString создает строку из случайных символов алфавита
+func randomString(alphabet string, length int) (string, error) {
+	max := big.NewInt(int64(len(alphabet)))
+
+	var b strings.Builder
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate random string: %v", err)
+		}
+		b.WriteByte(alphabet[n.Int64()])
+	}
+
+	return b.String(), nil
+}
+
+// sha512Crypt реализует алгоритм SHA-crypt Ульриха Дреппера для SHA-512
+// с числом раундов по умолчанию
+func sha512Crypt(password, salt string) string {
+	p := []byte(password)
+	s := []byte(salt)
+	if len(s) > saltLength {
+		s = s[:saltLength]
+	}
+
+	// Промежуточный дайджест B
+	h := sha512.New()
+	h.Write(p)
+	h.Write(s)
+	h.Write(p)
+	b := h.Sum(nil)
+
+	// Дайджест A
+	h = sha512.New()
+	h.Write(p)
+	h.Write(s)
+	for i := len(p); i > 0; i -= sha512.Size {
+		h.Write(b[:min(i, sha512.Size)])
+	}
+	for i := len(p); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(p)
+		}
+	}
+	a := h.Sum(nil)
+
+	// Последовательность P
+	h = sha512.New()
+	for range p {
+		h.Write(p)
+	}
+	dp := h.Sum(nil)
+	pSeq := make([]byte, 0, len(p))
+	for i := len(p); i > 0; i -= sha512.Size {
+		pSeq = append(pSeq, dp[:min(i, sha512.Size)]...)
+	}
+
+	// Последовательность S
+	h = sha512.New()
+	for i := 0; i < 16+int(a[0]); i++ {
+		h.Write(s)
+	}
+	sSeq := h.Sum(nil)[:len(s)]
+
+	// Раунды
+	c := a
+	for round := 0; round < sha512Rounds; round++ {
+		h = sha512.New()
+		if round&1 != 0 {
+			h.Write(pSeq)
+		} else {
+			h.Write(c)
+		}
+		if round%3 != 0 {
+			h.Write(sSeq)
+		}
+		if round%7 != 0 {
+			h.Write(pSeq)
+		}
+		if round&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(pSeq)
+		}
+		c = h.Sum(nil)
+	}
+
+	// Кодирование с перестановкой байтов, заданной алгоритмом
+	var out strings.Builder
+	out.WriteString("$6$")
+	out.Write(s)
+	out.WriteByte('$')
+	for i := 0; i < 21; i++ {
+		x, y, z := c[i], c[i+21], c[i+42]
+		switch i % 3 {
+		case 1:
+			x, y, z = y, z, x
+		case 2:
+			x, y, z = z, x, y
+		}
+		encode24(&out, x, y, z, 4)
+	}
+	encode24(&out, 0, 0, c[63], 2)
+
+	return out.String()
+}
+
+// encode24 кодирует три байта в n символов алфавита crypt(3)
+func encode24(out *strings.Builder, b2, b1, b0 byte, n int) {
+	w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for i := 0; i < n; i++ {
+		out.WriteByte(cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
@@ -0,0 +1,64 @@
+package cloudinit
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSHA512Crypt(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		salt     string
+		want     string
+	}{
+		{
+			// Example from the SHA-crypt specification.
+			name:     "reference vector",
+			password: "Hello world!",
+			salt:     "saltstring",
+			want:     "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+		},
+		{
+			name:     "salt longer than 16 characters is truncated",
+			password: "a much longer password that exceeds sixty-four bytes of input, to exercise the long-key path",
+			salt:     "saltstringsaltstring",
+			want:     "$6$saltstringsaltst$SR.Nsj6YJY/IXhwwJhWFSb9xq/iNmSbOc.sInGgx0UixPk8A.cxA7poGy30eqZKnLFR0lkva8sH3MqsG5uS590",
+		},
+		{
+			name:     "full length salt",
+			password: "x",
+			salt:     "abcdefghijklmnop",
+			want:     "$6$abcdefghijklmnop$4MjOne1jVRKP4IuHRPFedC4vlQHNjvcBfQJVlfB8ciQ5YUzW2g81Pyw4sptw4femJ071/eApI0WDsBAOOCmTl0",
+		},
+		{
+			name:     "non-ASCII password",
+			password: "пароль",
+			salt:     "x",
+			want:     "$6$x$Jz/dKpXcojOJeaWukOwRf9YaKQxncbRwHJ0lYKYdQ9sNb3Qi9F8D596kBr/DdRE6yHWZCiSIHymXACZRh1aZV/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sha512Crypt(tt.password, tt.salt); got != tt.want {
+				t.Errorf("sha512Crypt() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[1] != "6" || len(parts[2]) != saltLength {
+		t.Fatalf("HashPassword() = %s, want $6$<%d character salt>$<hash>", hash, saltLength)
+	}
+	if want := sha512Crypt("secret", parts[2]); hash != want {
+		t.Errorf("HashPassword() = %s, want %s", hash, want)
+	}
+}
@@ -0,0 +1,94 @@
+package cloudinit
+
+import "strings"
+
+// Profile описывает настройки гостевой ОС, от которых зависит cloud-init
+type Profile struct {
+	// Name - имя профиля, совпадает с префиксом ID образа
+	Name string
+	// InstanceProfile - значение spec.instanceProfile VMInstance
+	InstanceProfile string
+	// DefaultUser - пользователь, которого создает cloud-init
+	DefaultUser string
+	// Groups - группы пользователя с правами администратора
+	Groups []string
+	// PackageManager - менеджер пакетов образа
+	PackageManager string
+	// Packages - пакеты, которые устанавливаются при первом запуске
+	Packages []string
+}
+
+// profiles содержит профили известных семейств образов
+var profiles = []Profile{
+	{
+		Name:            "ubuntu",
+		InstanceProfile: "ubuntu",
+		DefaultUser:     "ubuntu",
+		Groups:          []string{"users", "admin"},
+		PackageManager:  "apt",
+		Packages:        []string{"qemu-guest-agent", "htop", "curl", "net-tools", "ca-certificates"},
+	},
+	{
+		Name:            "debian",
+		InstanceProfile: "debian",
+		DefaultUser:     "debian",
+		Groups:          []string{"users", "sudo"},
+		PackageManager:  "apt",
+		Packages:        []string{"qemu-guest-agent", "htop", "curl", "net-tools", "ca-certificates"},
+	},
+	{
+		Name:            "centos",
+		InstanceProfile: "centos.stream9",
+		DefaultUser:     "cloud-user",
+		Groups:          []string{"wheel"},
+		PackageManager:  "dnf",
+		Packages:        []string{"qemu-guest-agent", "curl", "net-tools", "ca-certificates"},
+	},
+	{
+		Name:            "almalinux",
+		InstanceProfile: "rhel.9",
+		DefaultUser:     "almalinux",
+		Groups:          []string{"wheel"},
+		PackageManager:  "dnf",
+		Packages:        []string{"qemu-guest-agent", "curl", "net-tools", "ca-certificates"},
+	},
+	{
+		Name:            "fedora",
+		InstanceProfile: "fedora",
+		DefaultUser:     "fedora",
+		Groups:          []string{"wheel"},
+		PackageManager:  "dnf",
+		Packages:        []string{"qemu-guest-agent", "htop", "curl", "net-tools", "ca-certificates"},
+	},
+	{
+		Name:            "opensuse",
+		InstanceProfile: "opensuse.leap",
+		DefaultUser:     "opensuse",
+		Groups:          []string{"wheel"},
+		PackageManager:  "zypper",
+		Packages:        []string{"qemu-guest-agent", "htop", "curl", "net-tools", "ca-certificates"},
+	},
+}
+
+// ProfileFor возвращает профиль по ID образа. Для неизвестных образов
+// используется профиль Ubuntu
+func ProfileFor(imageID string) Profile {
+	for _, profile := range profiles {
+		if strings.HasPrefix(imageID, profile.Name) {
+			return profile
+		}
+	}
+
+	return profiles[0]
+}
+
+// LookupProfile возвращает профиль по имени
+func LookupProfile(name string) (Profile, bool) {
+	for _, profile := range profiles {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+
+	return Profile{}, false
+}
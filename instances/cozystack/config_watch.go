@@ -0,0 +1,120 @@
+package cozystack
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// configReloadInterval определяет, как часто проверяются файлы каталогов
+const configReloadInterval = 30 * time.Second
+
+// watchConfigFile загружает файл и перечитывает его каждые configReloadInterval.
+// Ошибка возвращается только для первой загрузки; ошибки перечитывания
+// логируются, и load должен сохранить последнее корректное содержимое
+func watchConfigFile(path string, stopCh <-chan struct{}, load func([]byte) error) error {
+	loadFile := func() error {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		return load(content)
+	}
+
+	if err := loadFile(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(configReloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				if err := loadFile(); err != nil {
+					fmt.Printf("Warning: failed to reload %s: %v\n", path, err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// watchConfigMap загружает ключ ConfigMap и перечитывает его при изменении
+// ConfigMap. Как и для файла, ошибкой завершается только первая загрузка:
+// ConfigMap с опечаткой в имени не должен молча оставить встроенный каталог или
+// снять квоты. Если ConfigMap удален позже, остается последнее загруженное содержимое
+func watchConfigMap(client kubernetes.Interface, namespace, name, key string, stopCh <-chan struct{}, load func([]byte) error) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(client, defaultResyncPeriod,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+	informer := factory.Core().V1().ConfigMaps().Informer()
+
+	onChanged := func(obj interface{}) {
+		configMap, ok := obj.(*corev1.ConfigMap)
+		if !ok {
+			return
+		}
+
+		content, exists := configMap.Data[key]
+		if !exists {
+			fmt.Printf("Warning: ConfigMap %s has no %s key, keeping current content\n", name, key)
+			return
+		}
+
+		if err := load([]byte(content)); err != nil {
+			fmt.Printf("Warning: failed to reload ConfigMap %s: %v\n", name, err)
+		}
+	}
+
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    onChanged,
+		UpdateFunc: func(_, newObj interface{}) { onChanged(newObj) },
+		DeleteFunc: func(interface{}) {
+			fmt.Printf("Warning: ConfigMap %s was deleted, keeping current content\n", name)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error registering ConfigMap %s handler: %v", name, err)
+	}
+
+	factory.Start(stopCh)
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSyncTimeout)
+	defer cancel()
+
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return fmt.Errorf("timed out waiting for ConfigMap %s to sync", name)
+	}
+
+	obj, exists, err := informer.GetStore().GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return fmt.Errorf("ConfigMap %s not found in namespace %s", name, namespace)
+	}
+	configMap, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return fmt.Errorf("unexpected object for ConfigMap %s", name)
+	}
+	content, exists := configMap.Data[key]
+	if !exists {
+		return fmt.Errorf("ConfigMap %s has no %s key", name, key)
+	}
+
+	// Содержимое уже загружено обработчиком, повторная загрузка вернет его ошибку
+	return load([]byte(content))
+}
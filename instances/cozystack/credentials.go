@@ -0,0 +1,53 @@
+package cozystack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// credentialsSecretName возвращает имя Secret с паролем пользователя VM
+func credentialsSecretName(instanceID string) string {
+	return instanceID + "-credentials"
+}
+
+// createCredentials сохраняет пароль пользователя VM в Secret в namespace VM
+func (m *InstanceManager) createCredentials(ctx context.Context, namespace, projectID, instanceID, username, password string) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      credentialsSecretName(instanceID),
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app":         "cozystack-vm",
+				"created-by":  "graphql-api",
+				"resource":    "credentials",
+				"project-id":  projectID,
+				"instance-id": instanceID,
+			},
+		},
+		Type: corev1.SecretTypeBasicAuth,
+		StringData: map[string]string{
+			corev1.BasicAuthUsernameKey: username,
+			corev1.BasicAuthPasswordKey: password,
+		},
+	}
+
+	_, err := m.k8sClient.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to save instance credentials: %v", err)
+	}
+
+	return nil
+}
+
+// deleteCredentials удаляет Secret с паролем пользователя VM
+func (m *InstanceManager) deleteCredentials(ctx context.Context, namespace, instanceID string) {
+	err := m.k8sClient.CoreV1().Secrets(namespace).Delete(ctx, credentialsSecretName(instanceID), metav1.DeleteOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		// Логируем ошибку, но продолжаем
+		fmt.Printf("Warning: failed to delete credentials of %s: %v\n", instanceID, err)
+	}
+}
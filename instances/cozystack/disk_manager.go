@@ -3,118 +3,156 @@ package cozystack
 import (
 	"context"
 	"fmt"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"gqlfed/instances/events"
 	"gqlfed/instances/graph/model"
+	"gqlfed/instances/requirements"
 
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/retry"
 )
 
 // DiskManager управляет виртуальными дисками в CozyStack
 type DiskManager struct {
-	namespace     string
+	tenants       *TenantMap
 	dynamicClient dynamic.Interface
+	store         *ResourceStore
+	events        *events.Broker
 	diskCache     map[string]*model.Disk
 	cacheMutex    sync.RWMutex
-	imageURLs     map[string]string
+	images        *ImageManager
 }
 
-// NewDiskManager создает новый менеджер дисков
-func NewDiskManager(kubeconfigPath, namespace string) (*DiskManager, error) {
-	// Создаем конфигурацию клиента Kubernetes
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfigPath)
-	if err != nil {
-		return nil, fmt.Errorf("error building kubeconfig: %v", err)
-	}
-
-	// Создаем динамический клиент для работы с кастомными ресурсами
-	dynamicClient, err := dynamic.NewForConfig(config)
-	if err != nil {
-		return nil, fmt.Errorf("error creating dynamic client: %v", err)
-	}
-
+// newDiskManager создает менеджер дисков поверх уже созданных клиента, хранилища ресурсов,
+// брокера событий и каталога образов
+func newDiskManager(dynamicClient dynamic.Interface, tenants *TenantMap, store *ResourceStore, broker *events.Broker, images *ImageManager) (*DiskManager, error) {
 	manager := &DiskManager{
-		namespace:     namespace,
+		tenants:       tenants,
 		dynamicClient: dynamicClient,
+		store:         store,
+		events:        broker,
 		diskCache:     make(map[string]*model.Disk),
-		imageURLs:     initializeImageURLs(),
+		images:        images,
 	}
 
-	// Инициализируем кэш
-	err = manager.refreshDiskCache(context.Background())
+	// Кэш дисков обновляется событиями информера
+	err := store.AddDiskHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { manager.onDiskChanged(obj, model.EventTypeAdded) },
+		UpdateFunc: func(_, newObj interface{}) { manager.onDiskChanged(newObj, model.EventTypeModified) },
+		DeleteFunc: manager.onDiskDeleted,
+	})
 	if err != nil {
-		// Логируем ошибку, но продолжаем работу
-		fmt.Printf("Warning: Failed to initialize disk cache: %v\n", err)
+		return nil, fmt.Errorf("error registering disk event handler: %v", err)
 	}
 
-	// Запускаем горутину для периодического обновления кэша
-	go manager.periodicCacheRefresh()
-
 	return manager, nil
 }
 
-// VMDiskGVR определяет GroupVersionResource для VMDisk
-var VMDiskGVR = schema.GroupVersionResource{
-	Group:    "apps.cozystack.io",
-	Version:  "v1alpha1",
-	Resource: "vmdisks",
-}
-
 // CreateDisk создает новый виртуальный диск
-func (m *DiskManager) CreateDisk(ctx context.Context, diskID string, sizeGB int, imageID string) (*model.Disk, error) {
+func (m *DiskManager) CreateDisk(ctx context.Context, projectID, diskID string, sizeGB int, imageID string) (*model.Disk, error) {
+	namespace, err := m.tenants.Namespace(projectID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Проверяем, существует ли диск с таким ID
-	_, err := m.dynamicClient.Resource(VMDiskGVR).Namespace(m.namespace).Get(ctx, diskID, metav1.GetOptions{})
+	_, err = m.dynamicClient.Resource(VMDiskGVR).Namespace(namespace).Get(ctx, diskID, metav1.GetOptions{})
 	if err == nil {
 		return nil, fmt.Errorf("disk with ID %s already exists", diskID)
 	}
 
-	// Получаем URL образа для выбранного imageID
-	imageURL, ok := m.imageURLs[imageID]
-	if !ok {
-		// Если образ не найден, используем образ Ubuntu по умолчанию
-		imageURL = "https://cloud-images.ubuntu.com/noble/current/noble-server-cloudimg-amd64.img"
+	// Без образа создается пустой диск
+	if imageID == "" {
+		return m.createDiskObject(ctx, m.newDiskObject(namespace, projectID, diskID, sizeGB, map[string]interface{}{}))
 	}
 
-	// Создаем объект ресурса VMDisk
-	diskObject := &unstructured.Unstructured{
+	image, err := m.images.Resolve(projectID, imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = requirements.CheckDisk(image.Image, int32(sizeGB))
+	if err != nil {
+		return nil, err
+	}
+
+	// Готовый golden-диск клонируется, иначе образ загружается по HTTP.
+	// Клон не может быть меньше исходного диска
+	var source map[string]interface{}
+	switch {
+	case image.Golden != nil && sizeGB >= image.Golden.SizeGB:
+		source = map[string]interface{}{
+			"disk": map[string]interface{}{
+				"name":      image.Golden.Name,
+				"namespace": image.Golden.Namespace,
+			},
+		}
+	case image.ProjectID != "":
+		// Пользовательский образ существует только как диск, загрузить его заново нельзя
+		if image.Golden == nil {
+			return nil, fmt.Errorf("image %s is not ready yet", imageID)
+		}
+		return nil, fmt.Errorf("disk for image %s must be at least %d GB", imageID, image.Golden.SizeGB)
+	default:
+		source = map[string]interface{}{
+			"http": map[string]interface{}{
+				"url": image.URL,
+			},
+		}
+	}
+
+	diskObject := m.newDiskObject(namespace, projectID, diskID, sizeGB, source)
+	labels := diskObject.GetLabels()
+	labels["image-id"] = image.VersionID
+	diskObject.SetLabels(labels)
+
+	// Диски из ISO подключаются как CD-ROM
+	if image.Optical {
+		if err := unstructured.SetNestedField(diskObject.Object, true, "spec", "optical"); err != nil {
+			return nil, fmt.Errorf("failed to mark disk as optical: %v", err)
+		}
+	}
+
+	return m.createDiskObject(ctx, diskObject)
+}
+
+// newDiskObject создает объект ресурса VMDisk в namespace тенанта проекта
+func (m *DiskManager) newDiskObject(namespace, projectID, diskID string, sizeGB int, source map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
 		Object: map[string]interface{}{
 			"apiVersion": "apps.cozystack.io/v1alpha1",
 			"kind":       "VMDisk",
 			"metadata": map[string]interface{}{
 				"name":      diskID,
-				"namespace": m.namespace,
+				"namespace": namespace,
 				"labels": map[string]interface{}{
 					"app":        "cozystack-vm",
 					"created-by": "graphql-api",
+					"project-id": projectID,
 				},
 			},
 			"spec": map[string]interface{}{
-				"optical": false,
-				"source": map[string]interface{}{
-					"http": map[string]interface{}{
-						"url": imageURL,
-					},
-				},
+				"optical":      false,
+				"source":       source,
 				"storage":      fmt.Sprintf("%dGi", sizeGB),
 				"storageClass": "replicated",
 			},
 		},
 	}
+}
 
+// createDiskObject создает VMDisk в кластере, добавляет его в кэш и отслеживает готовность
+func (m *DiskManager) createDiskObject(ctx context.Context, diskObject *unstructured.Unstructured) (*model.Disk, error) {
 	// Используем retry для повышения надежности создания ресурса
-	var createdDisk *unstructured.Unstructured
-
-	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		var createErr error
-		createdDisk, createErr = m.dynamicClient.Resource(VMDiskGVR).Namespace(m.namespace).Create(ctx, diskObject, metav1.CreateOptions{})
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		_, createErr := m.dynamicClient.Resource(VMDiskGVR).Namespace(diskObject.GetNamespace()).Create(ctx, diskObject, metav1.CreateOptions{})
 		return createErr
 	})
 
@@ -123,29 +161,37 @@ func (m *DiskManager) CreateDisk(ctx context.Context, diskID string, sizeGB int,
 	}
 
 	// Создаем модель диска
-	disk := &model.Disk{
-		DiskID:   diskID,
-		SizeGb:   sizeGB,
-		Bootable: true,
-		Status:   "CREATING",
+	disk, err := m.convertToDiskModel(diskObject)
+	if err != nil {
+		return nil, err
 	}
+	disk.Status = "CREATING"
 
 	// Сохраняем в кэш
 	m.cacheMutex.Lock()
-	m.diskCache[diskID] = disk
+	m.diskCache[disk.DiskID] = disk
 	m.cacheMutex.Unlock()
 
 	// Запускаем горутину для отслеживания создания диска
-	go m.waitForDiskReady(ctx, diskID)
+	go m.waitForDiskReady(ctx, disk.DiskID)
 
 	return disk, nil
 }
 
 // DeleteDisk удаляет виртуальный диск
 func (m *DiskManager) DeleteDisk(ctx context.Context, diskID string) error {
+	namespace, err := m.diskNamespace(ctx, diskID)
+	if err != nil {
+		// Диска нет ни в кэше, ни у тенантов, удалять нечего
+		if strings.Contains(err.Error(), "not found") {
+			return nil
+		}
+		return err
+	}
+
 	// Используем retry для повышения надежности удаления ресурса
 	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		err := m.dynamicClient.Resource(VMDiskGVR).Namespace(m.namespace).Delete(ctx, diskID, metav1.DeleteOptions{})
+		err := m.dynamicClient.Resource(VMDiskGVR).Namespace(namespace).Delete(ctx, diskID, metav1.DeleteOptions{})
 		if err != nil {
 			// Если диск не найден, считаем операцию успешной
 			if strings.Contains(err.Error(), "not found") {
@@ -178,10 +224,10 @@ func (m *DiskManager) GetDisk(ctx context.Context, diskID string) (*model.Disk,
 		return cachedDisk, nil
 	}
 
-	// Получаем диск через API Kubernetes
-	diskObj, err := m.dynamicClient.Resource(VMDiskGVR).Namespace(m.namespace).Get(ctx, diskID, metav1.GetOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to get disk: %v", err)
+	// Ищем диск в хранилище информера
+	diskObj, exists := m.store.GetDisk(diskID)
+	if !exists {
+		return nil, fmt.Errorf("disk not found: %s", diskID)
 	}
 
 	// Конвертируем в модель диска
@@ -198,20 +244,29 @@ func (m *DiskManager) GetDisk(ctx context.Context, diskID string) (*model.Disk,
 	return disk, nil
 }
 
-// ListDisks возвращает список всех дисков
-func (m *DiskManager) ListDisks(ctx context.Context) ([]*model.Disk, error) {
-	// Обновляем кэш
-	err := m.refreshDiskCache(ctx)
+// diskNamespace возвращает namespace тенанта, в котором находится диск
+func (m *DiskManager) diskNamespace(ctx context.Context, diskID string) (string, error) {
+	disk, err := m.GetDisk(ctx, diskID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to refresh disk cache: %v", err)
+		return "", err
 	}
 
-	// Возвращаем список дисков из кэша
+	return m.tenants.Namespace(disk.ProjectID)
+}
+
+// ListDisks возвращает список дисков проекта; пустой projectID означает все диски
+func (m *DiskManager) ListDisks(ctx context.Context, projectID string) ([]*model.Disk, error) {
+	// Кэш поддерживается информером, поэтому обращения к API не требуется.
+	// Проект берется из модели в кэше: только что созданный диск еще не попал
+	// в хранилище информера, но уже должен учитываться в списке и в квотах
 	m.cacheMutex.RLock()
 	defer m.cacheMutex.RUnlock()
 
 	disks := make([]*model.Disk, 0, len(m.diskCache))
 	for _, disk := range m.diskCache {
+		if projectID != "" && disk.ProjectID != projectID {
+			continue
+		}
 		disks = append(disks, disk)
 	}
 
@@ -231,16 +286,15 @@ func (m *DiskManager) ResizeDisk(ctx context.Context, diskID string, newSizeGB i
 		return nil, fmt.Errorf("new size (%d GB) must be greater than current size (%d GB)", newSizeGB, currentDisk.SizeGb)
 	}
 
-	// Получаем текущий ресурс диска
-	diskObj, err := m.dynamicClient.Resource(VMDiskGVR).Namespace(m.namespace).Get(ctx, diskID, metav1.GetOptions{})
+	namespace, err := m.tenants.Namespace(currentDisk.ProjectID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get disk for resizing: %v", err)
+		return nil, err
 	}
 
 	// Обновляем спецификацию диска
 	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// Получаем актуальную версию ресурса перед каждой попыткой
-		diskObj, err := m.dynamicClient.Resource(VMDiskGVR).Namespace(m.namespace).Get(ctx, diskID, metav1.GetOptions{})
+		diskObj, err := m.dynamicClient.Resource(VMDiskGVR).Namespace(namespace).Get(ctx, diskID, metav1.GetOptions{})
 		if err != nil {
 			return err
 		}
@@ -258,7 +312,7 @@ func (m *DiskManager) ResizeDisk(ctx context.Context, diskID string, newSizeGB i
 		}
 
 		// Применяем изменения
-		_, err = m.dynamicClient.Resource(VMDiskGVR).Namespace(m.namespace).Update(ctx, diskObj, metav1.UpdateOptions{})
+		_, err = m.dynamicClient.Resource(VMDiskGVR).Namespace(namespace).Update(ctx, diskObj, metav1.UpdateOptions{})
 		return err
 	})
 
@@ -267,11 +321,9 @@ func (m *DiskManager) ResizeDisk(ctx context.Context, diskID string, newSizeGB i
 	}
 
 	// Обновляем кэш
-	m.cacheMutex.Lock()
-	if disk, exists := m.diskCache[diskID]; exists {
-		disk.SizeGb = newSizeGB
-	}
-	m.cacheMutex.Unlock()
+	m.updateCachedDisk(diskID, func(disk *model.Disk) {
+		disk.SizeGb = int32(newSizeGB)
+	})
 
 	// Возвращаем обновленную информацию о диске
 	return m.GetDisk(ctx, diskID)
@@ -288,17 +340,15 @@ func (m *DiskManager) waitForDiskReady(ctx context.Context, diskID string) {
 		select {
 		case <-timeout:
 			// Превышен таймаут ожидания
-			m.cacheMutex.Lock()
-			if disk, exists := m.diskCache[diskID]; exists {
+			m.updateCachedDisk(diskID, func(disk *model.Disk) {
 				disk.Status = "ERROR"
-			}
-			m.cacheMutex.Unlock()
+			})
 			return
 		case <-ticker.C:
-			// Получаем обновленную информацию о диске
-			diskObj, err := m.dynamicClient.Resource(VMDiskGVR).Namespace(m.namespace).Get(ctx, diskID, metav1.GetOptions{})
-			if err != nil {
-				// Возможно диск был удален
+			// Получаем обновленную информацию о диске из хранилища информера
+			diskObj, exists := m.store.GetDisk(diskID)
+			if !exists {
+				// Возможно диск еще не попал в хранилище или был удален
 				continue
 			}
 
@@ -316,8 +366,7 @@ func (m *DiskManager) waitForDiskReady(ctx context.Context, diskID string) {
 			phaseStr := phase.(string)
 
 			// Обновляем статус в кэше
-			m.cacheMutex.Lock()
-			if disk, exists := m.diskCache[diskID]; exists {
+			m.updateCachedDisk(diskID, func(disk *model.Disk) {
 				if phaseStr == "Ready" {
 					disk.Status = "ACTIVE"
 				} else if phaseStr == "Failed" {
@@ -325,8 +374,7 @@ func (m *DiskManager) waitForDiskReady(ctx context.Context, diskID string) {
 				} else {
 					disk.Status = "CREATING"
 				}
-			}
-			m.cacheMutex.Unlock()
+			})
 
 			// Если диск готов или произошла ошибка, выходим из цикла
 			if phaseStr == "Ready" || phaseStr == "Failed" {
@@ -336,52 +384,76 @@ func (m *DiskManager) waitForDiskReady(ctx context.Context, diskID string) {
 	}
 }
 
-// refreshDiskCache обновляет кэш дисков
-func (m *DiskManager) refreshDiskCache(ctx context.Context) error {
-	// Получаем список дисков через API Kubernetes
-	diskList, err := m.dynamicClient.Resource(VMDiskGVR).Namespace(m.namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to list disks: %v", err)
-	}
+// updateCachedDisk применяет update к копии диска из кэша и заменяет ею
+// запись. Выданные ранее диски не меняются, поэтому их можно читать без
+// блокировки
+func (m *DiskManager) updateCachedDisk(diskID string, update func(disk *model.Disk)) {
+	m.cacheMutex.Lock()
+	defer m.cacheMutex.Unlock()
 
-	// Создаем новый кэш
-	newCache := make(map[string]*model.Disk)
+	cached, exists := m.diskCache[diskID]
+	if !exists {
+		return
+	}
+	disk := *cached
+	update(&disk)
+	m.diskCache[diskID] = &disk
+}
 
-	// Обрабатываем каждый диск
-	for _, diskObj := range diskList.Items {
-		disk, err := m.convertToDiskModel(&diskObj)
-		if err != nil {
-			// Логируем ошибку и продолжаем
-			fmt.Printf("Warning: Failed to convert disk to model: %v\n", err)
-			continue
-		}
+// onDiskChanged обновляет диск в кэше по событию информера и сообщает
+// подписчикам об изменившихся полях
+func (m *DiskManager) onDiskChanged(obj interface{}, eventType model.EventType) {
+	diskObj, ok := objectFromEvent(obj)
+	if !ok || isImageDisk(diskObj) {
+		return
+	}
 
-		newCache[disk.DiskID] = disk
+	disk, err := m.convertToDiskModel(diskObj)
+	if err != nil {
+		// Логируем ошибку и продолжаем
+		fmt.Printf("Warning: Failed to convert disk to model: %v\n", err)
+		return
 	}
 
-	// Обновляем кэш
 	m.cacheMutex.Lock()
-	m.diskCache = newCache
+	previous, existed := m.diskCache[disk.DiskID]
+	m.diskCache[disk.DiskID] = disk
 	m.cacheMutex.Unlock()
 
-	return nil
+	var changedFields []string
+	if eventType == model.EventTypeModified {
+		if !existed {
+			eventType = model.EventTypeAdded
+		} else {
+			changedFields = events.DiskChangedFields(previous, disk)
+			if len(changedFields) == 0 {
+				// Пересинхронизация информера без фактических изменений
+				return
+			}
+		}
+	}
+
+	m.events.Publish(events.NewDiskEvent(eventType, diskObj.GetLabels()["project-id"], disk, changedFields))
 }
 
-// periodicCacheRefresh периодически обновляет кэш дисков
-func (m *DiskManager) periodicCacheRefresh() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
+// onDiskDeleted удаляет диск из кэша по событию информера
+func (m *DiskManager) onDiskDeleted(obj interface{}) {
+	diskObj, ok := objectFromEvent(obj)
+	if !ok || isImageDisk(diskObj) {
+		return
+	}
 
-	for {
-		select {
-		case <-ticker.C:
-			ctx := context.Background()
-			err := m.refreshDiskCache(ctx)
-			if err != nil {
-				fmt.Printf("Warning: Failed to refresh disk cache: %v\n", err)
-			}
-		}
+	m.cacheMutex.Lock()
+	delete(m.diskCache, diskObj.GetName())
+	m.cacheMutex.Unlock()
+
+	// Отправляем уведомление с последним известным состоянием диска
+	disk, err := m.convertToDiskModel(diskObj)
+	if err != nil {
+		return
 	}
+
+	m.events.Publish(events.NewDiskEvent(model.EventTypeDeleted, diskObj.GetLabels()["project-id"], disk, nil))
 }
 
 // convertToDiskModel преобразует Kubernetes ресурс в модель диска
@@ -403,15 +475,16 @@ func (m *DiskManager) convertToDiskModel(diskObj *unstructured.Unstructured) (*m
 	// Извлекаем имя диска
 	diskID := metadata["name"].(string)
 
-	// Извлекаем размер диска
+	// Извлекаем размер диска, округляя его до гигабайт вверх. Размер, который
+	// не удалось разобрать, остается нулевым, а не подменяется значением по умолчанию
 	storageStr, found, _ := unstructured.NestedString(spec, "storage")
-	sizeGB := 20 // Размер по умолчанию
-
-	if found && len(storageStr) > 2 && storageStr[len(storageStr)-2:] == "Gi" {
-		sizeStr := storageStr[:len(storageStr)-2]
-		size, err := strconv.Atoi(sizeStr)
+	sizeGB := 0
+	if found {
+		storage, err := resource.ParseQuantity(storageStr)
 		if err == nil {
-			sizeGB = size
+			sizeGB = int((storage.Value() + (1 << 30) - 1) >> 30)
+		} else {
+			fmt.Printf("Warning: disk %s has invalid storage %q\n", diskID, storageStr)
 		}
 	}
 
@@ -438,26 +511,26 @@ func (m *DiskManager) convertToDiskModel(diskObj *unstructured.Unstructured) (*m
 		}
 	}
 
+	// Загрузочными считаем диски, созданные из образа; пустые диски источника не имеют
+	source, _, _ := unstructured.NestedMap(spec, "source")
+
 	// Создаем модель диска
 	disk := &model.Disk{
-		DiskID:   diskID,
-		SizeGb:   sizeGB,
-		Bootable: true, // Предполагаем, что все диски загрузочные
-		Status:   diskStatus,
+		DiskID:    diskID,
+		ProjectID: diskObj.GetLabels()["project-id"],
+		SizeGb:    int32(sizeGB),
+		Bootable:  len(source) > 0,
+		Status:    diskStatus,
 	}
 
-	return disk, nil
-}
-
-// initializeImageURLs инициализирует маппинг ID образов на URL
-func initializeImageURLs() map[string]string {
-	return map[string]string{
-		"ubuntu-24-04-noble": "https://cloud-images.ubuntu.com/noble/current/noble-server-cloudimg-amd64.img",
-		"ubuntu-22-04-jammy": "https://cloud-images.ubuntu.com/jammy/current/jammy-server-cloudimg-amd64.img",
-		"debian-12-bookworm": "https://cloud.debian.org/images/cloud/bookworm/latest/debian-12-generic-amd64.qcow2",
-		"centos-stream-9":    "https://cloud.centos.org/centos/9-stream/x86_64/images/CentOS-Stream-GenericCloud-9-latest.x86_64.qcow2",
-		"almalinux-9":        "https://repo.almalinux.org/almalinux/9/cloud/x86_64/images/AlmaLinux-9-GenericCloud-latest.x86_64.qcow2",
-		"fedora-38":          "https://download.fedoraproject.org/pub/fedora/linux/releases/38/Cloud/x86_64/images/Fedora-Cloud-Base-38-1.6.x86_64.qcow2",
-		"opensuse-leap-15-5": "https://download.opensuse.org/distribution/leap/15.5/appliances/openSUSE-Leap-15.5-JeOS.x86_64-15.5.0-OpenStack-Cloud.qcow2",
+	// Образ определяем по метке версии, а у дисков без метки - по URL, из которого загружен диск
+	if image, err := m.images.Resolve(diskObj.GetLabels()["project-id"], diskObj.GetLabels()["image-id"]); err == nil {
+		disk.Image = image.Image
+	} else if imageURL, found, _ := unstructured.NestedString(source, "http", "url"); found {
+		if image, ok := m.images.SourceForURL(imageURL); ok {
+			disk.Image = image.Image
+		}
 	}
+
+	return disk, nil
 }
@@ -0,0 +1,436 @@
+package cozystack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"gqlfed/instances/graph/model"
+	"gqlfed/instances/pricing"
+	"gqlfed/instances/requirements"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+)
+
+// flavorConfigKey - ключ ConfigMap, в котором лежит каталог flavor
+const flavorConfigKey = "flavors.yaml"
+
+// flavorCategories содержит категории, для которых в схеме GraphQL есть тип flavor
+var flavorCategories = map[string]bool{
+	"base":    true,
+	"hi-freq": true,
+	"premium": true,
+	"pro":     true,
+}
+
+// FlavorSpec описывает flavor в файле каталога
+type FlavorSpec struct {
+	Name     string      `json:"name"`
+	Category string      `json:"category"`
+	VCPUs    int         `json:"vcpus"`
+	RAM      string      `json:"ram"`
+	Price    json.Number `json:"rub_month"`
+}
+
+// flavorCatalogFile - формат файла каталога flavor. Рядом с flavor задаются
+// цены гигабайта диска и публичного адреса
+type flavorCatalogFile struct {
+	Flavors []FlavorSpec `json:"flavors"`
+	pricing.Prices
+}
+
+// FlavorManager хранит каталог flavor. Каталог загружается из файла или
+// ConfigMap и перечитывается при их изменении; без источника используется
+// встроенный каталог. Если включено обнаружение instancetype, каталог служит
+// прайс-листом: vCPU и RAM берутся из кластера, а предлагаются только flavor,
+// которые есть и в кластере, и в прайс-листе
+type FlavorManager struct {
+	mu      sync.RWMutex
+	catalog map[string]map[string]FlavorInfo
+	// categories хранит категорию каждого flavor по имени
+	categories map[string]string
+	// prices содержит цены дисков и публичных адресов
+	prices pricing.Prices
+	// content - содержимое последнего загруженного каталога
+	content []byte
+	// discovery включается после загрузки instancetype из кластера
+	discovery bool
+	// discovered хранит vCPU и RAM instancetype кластера по имени
+	discovered map[string]FlavorInfo
+	stopCh     chan struct{}
+}
+
+// NewFlavorManager создает менеджер со встроенным каталогом
+func NewFlavorManager() *FlavorManager {
+	manager := &FlavorManager{
+		discovered: make(map[string]FlavorInfo),
+		stopCh:     make(chan struct{}),
+	}
+
+	catalog, err := buildFlavorCatalog(builtinFlavors)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in flavor catalog: %v", err))
+	}
+	manager.setCatalog(catalog, builtinPrices, nil)
+
+	return manager
+}
+
+// Load заменяет каталог содержимым файла каталога в формате YAML или JSON
+func (m *FlavorManager) Load(content []byte) error {
+	m.mu.RLock()
+	unchanged := bytes.Equal(m.content, content)
+	m.mu.RUnlock()
+
+	if unchanged {
+		return nil
+	}
+
+	var file flavorCatalogFile
+	if err := yaml.UnmarshalStrict(content, &file); err != nil {
+		return fmt.Errorf("failed to parse flavor catalog: %v", err)
+	}
+
+	catalog, err := buildFlavorCatalog(file.Flavors)
+	if err != nil {
+		return err
+	}
+	if err := file.Prices.Validate(); err != nil {
+		return fmt.Errorf("invalid flavor catalog: %v", err)
+	}
+
+	m.setCatalog(catalog, file.Prices, content)
+
+	return nil
+}
+
+// WatchFile загружает каталог из файла и перечитывает его при изменении.
+// Ошибка возвращается только для первой загрузки; при ошибке перечитывания
+// остается последний корректный каталог
+func (m *FlavorManager) WatchFile(path string) error {
+	return watchConfigFile(path, m.stopCh, m.Load)
+}
+
+// WatchConfigMap загружает каталог из ConfigMap и перечитывает его при изменении
+func (m *FlavorManager) WatchConfigMap(client kubernetes.Interface, namespace, name string) error {
+	return watchConfigMap(client, namespace, name, flavorConfigKey, m.stopCh, m.Load)
+}
+
+// WatchInstancetypes загружает VirtualMachineClusterInstancetype из кластера и
+// отслеживает их изменения. Если загрузить instancetype не удалось, каталог
+// продолжает работать без обнаружения
+func (m *FlavorManager) WatchInstancetypes(dynamicClient dynamic.Interface) error {
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, defaultResyncPeriod)
+	informer := factory.ForResource(ClusterInstancetypeGVR).Informer()
+
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    m.onInstancetypeChanged,
+		UpdateFunc: func(_, newObj interface{}) { m.onInstancetypeChanged(newObj) },
+		DeleteFunc: m.onInstancetypeDeleted,
+	})
+	if err != nil {
+		return fmt.Errorf("error registering instancetype handler: %v", err)
+	}
+
+	// Информер останавливается вместе с менеджером или при неудачной загрузке
+	stopCh := make(chan struct{})
+	go func() {
+		select {
+		case <-m.stopCh:
+			close(stopCh)
+		case <-stopCh:
+		}
+	}()
+
+	factory.Start(stopCh)
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSyncTimeout)
+	defer cancel()
+
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		close(stopCh)
+		return fmt.Errorf("timed out waiting for instancetypes to sync")
+	}
+
+	m.mu.Lock()
+	m.discovery = true
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Available сообщает, может ли кластер запустить flavor
+func (m *FlavorManager) Available(instanceType string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.discovery {
+		return true
+	}
+
+	_, exists := m.discovered[instanceType]
+	return exists
+}
+
+// Stop прекращает отслеживание источника каталога
+func (m *FlavorManager) Stop() {
+	close(m.stopCh)
+}
+
+// Resolve возвращает категорию и параметры flavor из каталога. Flavor, которого
+// нет в каталоге, не находится
+func (m *FlavorManager) Resolve(instanceType string) (string, FlavorInfo, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	category, exists := m.categories[instanceType]
+	if !exists {
+		return "", FlavorInfo{}, false
+	}
+
+	return category, m.withDiscovered(instanceType, m.catalog[category][instanceType]), true
+}
+
+// Prices возвращает цены дисков и публичных адресов
+func (m *FlavorManager) Prices() pricing.Prices {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.prices
+}
+
+// Flavor возвращает GraphQL-модель flavor по имени. Для flavor, которого нет
+// в каталоге, возвращается модель без цены с vCPU и RAM instancetype кластера,
+// если они известны, чтобы существующий инстанс можно было показать
+func (m *FlavorManager) Flavor(instanceType string) (model.Flavor, bool) {
+	category, info, exists := m.Resolve(instanceType)
+	if !exists {
+		m.mu.RLock()
+		info = m.withDiscovered(instanceType, FlavorInfo{})
+		m.mu.RUnlock()
+		return newFlavorModel("base", instanceType, info), false
+	}
+
+	return newFlavorModel(category, instanceType, info), true
+}
+
+// Require возвращает flavor для нового инстанса. Flavor, которого нет в
+// каталоге, - ошибка запроса с кодом BAD_USER_INPUT
+func (m *FlavorManager) Require(instanceType string) (model.Flavor, error) {
+	flavor, exists := m.Flavor(instanceType)
+	if !exists {
+		return nil, requirements.UnknownFlavor(instanceType)
+	}
+	if !m.Available(instanceType) {
+		return nil, fmt.Errorf("instance type %s is not available in the cluster", instanceType)
+	}
+
+	return flavor, nil
+}
+
+// FlavorList возвращает каталог, сгруппированный по категориям
+func (m *FlavorManager) FlavorList() []*model.KVStringListOfFlavor {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	categories := make([]string, 0, len(m.catalog))
+	for category := range m.catalog {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	flavorList := make([]*model.KVStringListOfFlavor, 0, len(categories))
+	for _, category := range categories {
+		names := make([]string, 0, len(m.catalog[category]))
+		for name := range m.catalog[category] {
+			// Flavor, которых нет в кластере, не предлагаются
+			if m.discovery {
+				if _, exists := m.discovered[name]; !exists {
+					continue
+				}
+			}
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			continue
+		}
+		sort.Strings(names)
+
+		flavors := make([]model.Flavor, 0, len(names))
+		for _, name := range names {
+			flavors = append(flavors, newFlavorModel(category, name, m.withDiscovered(name, m.catalog[category][name])))
+		}
+
+		flavorList = append(flavorList, &model.KVStringListOfFlavor{
+			Key:   category,
+			Value: flavors,
+		})
+	}
+
+	return flavorList
+}
+
+// withDiscovered подставляет vCPU и RAM из instancetype кластера.
+// Вызывающий должен удерживать m.mu
+func (m *FlavorManager) withDiscovered(instanceType string, info FlavorInfo) FlavorInfo {
+	if discovered, exists := m.discovered[instanceType]; exists {
+		info.VCPUs = discovered.VCPUs
+		info.RAM = discovered.RAM
+	}
+
+	return info
+}
+
+// onInstancetypeChanged обновляет параметры instancetype по событию информера
+func (m *FlavorManager) onInstancetypeChanged(obj interface{}) {
+	instancetype, ok := objectFromEvent(obj)
+	if !ok {
+		return
+	}
+
+	cpu, found, err := unstructured.NestedInt64(instancetype.Object, "spec", "cpu", "guest")
+	if err != nil || !found {
+		fmt.Printf("Warning: instancetype %s has no spec.cpu.guest\n", instancetype.GetName())
+		return
+	}
+
+	memory, found, err := unstructured.NestedString(instancetype.Object, "spec", "memory", "guest")
+	if err != nil || !found {
+		fmt.Printf("Warning: instancetype %s has no spec.memory.guest\n", instancetype.GetName())
+		return
+	}
+
+	m.mu.Lock()
+	m.discovered[instancetype.GetName()] = FlavorInfo{
+		VCPUs: strconv.FormatInt(cpu, 10),
+		RAM:   memory,
+	}
+	m.mu.Unlock()
+}
+
+// onInstancetypeDeleted удаляет instancetype по событию информера
+func (m *FlavorManager) onInstancetypeDeleted(obj interface{}) {
+	instancetype, ok := objectFromEvent(obj)
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	delete(m.discovered, instancetype.GetName())
+	m.mu.Unlock()
+}
+
+// setCatalog заменяет каталог, цены и индекс категорий
+func (m *FlavorManager) setCatalog(catalog map[string]map[string]FlavorInfo, prices pricing.Prices, content []byte) {
+	categories := make(map[string]string)
+	for category, flavors := range catalog {
+		for name := range flavors {
+			categories[name] = category
+		}
+	}
+
+	m.mu.Lock()
+	m.catalog = catalog
+	m.categories = categories
+	m.prices = prices
+	m.content = content
+	m.mu.Unlock()
+}
+
+// buildFlavorCatalog проверяет flavor из файла и группирует их по категориям
+func buildFlavorCatalog(specs []FlavorSpec) (map[string]map[string]FlavorInfo, error) {
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("flavor catalog is empty")
+	}
+
+	catalog := make(map[string]map[string]FlavorInfo)
+	seen := make(map[string]bool)
+
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("flavor without a name")
+		}
+		if seen[spec.Name] {
+			return nil, fmt.Errorf("duplicate flavor %s", spec.Name)
+		}
+		seen[spec.Name] = true
+
+		if !flavorCategories[spec.Category] {
+			return nil, fmt.Errorf("flavor %s has unknown category %q", spec.Name, spec.Category)
+		}
+		if spec.VCPUs <= 0 {
+			return nil, fmt.Errorf("flavor %s must have at least one vCPU", spec.Name)
+		}
+		if _, err := resource.ParseQuantity(spec.RAM); err != nil {
+			return nil, fmt.Errorf("flavor %s has invalid ram %q: %v", spec.Name, spec.RAM, err)
+		}
+		if _, err := spec.Price.Float64(); err != nil {
+			return nil, fmt.Errorf("flavor %s has invalid price %q", spec.Name, spec.Price)
+		}
+
+		if catalog[spec.Category] == nil {
+			catalog[spec.Category] = make(map[string]FlavorInfo)
+		}
+		catalog[spec.Category][spec.Name] = FlavorInfo{
+			VCPUs: fmt.Sprintf("%d", spec.VCPUs),
+			RAM:   spec.RAM,
+			Price: spec.Price.String(),
+		}
+	}
+
+	return catalog, nil
+}
+
+// Структура для хранения информации о flavor
+type FlavorInfo struct {
+	VCPUs string
+	RAM   string
+	Price string
+}
+
+// builtinFlavors содержит каталог, который используется без файла каталога
+var builtinFlavors = []FlavorSpec{
+	{Name: "u1.xsmall", Category: "base", VCPUs: 1, RAM: "2Gi", Price: "500"},
+	{Name: "u1.small", Category: "base", VCPUs: 1, RAM: "4Gi", Price: "700"},
+	{Name: "u1.medium", Category: "base", VCPUs: 2, RAM: "4Gi", Price: "900"},
+	{Name: "u1.2xmedium", Category: "base", VCPUs: 2, RAM: "8Gi", Price: "1200"},
+	{Name: "u1.large", Category: "base", VCPUs: 4, RAM: "8Gi", Price: "1600"},
+	{Name: "uf1.small", Category: "hi-freq", VCPUs: 1, RAM: "4Gi", Price: "1000"},
+	{Name: "uf1.medium", Category: "hi-freq", VCPUs: 2, RAM: "8Gi", Price: "1800"},
+	{Name: "uf1.large", Category: "hi-freq", VCPUs: 4, RAM: "16Gi", Price: "3000"},
+	{Name: "p1.medium", Category: "premium", VCPUs: 2, RAM: "8Gi", Price: "2000"},
+	{Name: "p1.large", Category: "premium", VCPUs: 4, RAM: "16Gi", Price: "3500"},
+	{Name: "p1.xlarge", Category: "premium", VCPUs: 8, RAM: "32Gi", Price: "6000"},
+	{Name: "pro1.large", Category: "pro", VCPUs: 4, RAM: "32Gi", Price: "5000"},
+	{Name: "pro1.xlarge", Category: "pro", VCPUs: 8, RAM: "64Gi", Price: "9000"},
+}
+
+// builtinPrices содержит цены дисков и публичных адресов встроенного каталога
+var builtinPrices = pricing.Prices{
+	DiskGB:   10,
+	PublicIP: 200,
+}
+
+// newFlavorModel создает GraphQL-модель flavor для указанной категории
+func newFlavorModel(category, name string, info FlavorInfo) model.Flavor {
+	switch category {
+	case "hi-freq":
+		return &model.HiFreqFlavor{OriginalName: name, Vcpus: info.VCPUs, RAM: info.RAM, RubMonth: info.Price}
+	case "premium":
+		return &model.PremiumFlavor{OriginalName: name, Vcpus: info.VCPUs, RAM: info.RAM, RubMonth: info.Price}
+	case "pro":
+		return &model.ProFlavor{OriginalName: name, Vcpus: info.VCPUs, RAM: info.RAM, RubMonth: info.Price}
+	default:
+		return &model.BaseFlavor{OriginalName: name, Vcpus: info.VCPUs, RAM: info.RAM, RubMonth: info.Price}
+	}
+}
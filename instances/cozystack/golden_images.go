@@ -0,0 +1,212 @@
+package cozystack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// goldenDiskPrefix - префикс имени golden-диска
+	goldenDiskPrefix = "golden-"
+
+	// goldenSelector отбирает golden-диски, созданные API
+	goldenSelector = "created-by=graphql-api,resource=golden-image"
+
+	// imageURLAnnotation хранит URL, из которого импортирован golden-диск
+	imageURLAnnotation = "image-url"
+)
+
+// GoldenImageManager один раз импортирует каждую версию образа из каталога в
+// общий VMDisk в публичном namespace и отслеживает готовность этих дисков.
+// Загрузочные диски клонируются из готовых golden-дисков, а пока golden-диск
+// не готов, образ загружается по HTTP
+type GoldenImageManager struct {
+	namespace     string
+	dynamicClient dynamic.Interface
+	images        *ImageManager
+	informer      cache.SharedIndexInformer
+	// syncMutex не дает параллельно создавать одни и те же golden-диски
+	syncMutex sync.Mutex
+	stopCh    chan struct{}
+}
+
+// newGoldenImageManager создает менеджер golden-дисков в указанном namespace
+func newGoldenImageManager(dynamicClient dynamic.Interface, namespace string, images *ImageManager) *GoldenImageManager {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, defaultResyncPeriod, namespace,
+		func(options *metav1.ListOptions) {
+			options.LabelSelector = goldenSelector
+		})
+
+	return &GoldenImageManager{
+		namespace:     namespace,
+		dynamicClient: dynamicClient,
+		images:        images,
+		informer:      factory.ForResource(VMDiskGVR).Informer(),
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// Start загружает golden-диски, импортирует недостающие версии и
+// повторяет импорт после каждой перезагрузки каталога образов
+func (m *GoldenImageManager) Start() error {
+	_, err := m.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    m.onGoldenChanged,
+		UpdateFunc: func(_, newObj interface{}) { m.onGoldenChanged(newObj) },
+		DeleteFunc: m.onGoldenDeleted,
+	})
+	if err != nil {
+		return fmt.Errorf("error registering golden image handler: %v", err)
+	}
+
+	go m.informer.Run(m.stopCh)
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSyncTimeout)
+	defer cancel()
+
+	if !cache.WaitForCacheSync(ctx.Done(), m.informer.HasSynced) {
+		m.Stop()
+		return fmt.Errorf("timed out waiting for golden images to sync")
+	}
+
+	m.images.OnChange(func() { go m.sync() })
+	go m.sync()
+
+	return nil
+}
+
+// Stop останавливает отслеживание golden-дисков
+func (m *GoldenImageManager) Stop() {
+	close(m.stopCh)
+}
+
+// sync создает golden-диски для версий каталога, у которых их еще нет, и
+// удаляет golden-диски версий, которых больше нет в каталоге или у которых
+// сменился URL
+func (m *GoldenImageManager) sync() {
+	m.syncMutex.Lock()
+	defer m.syncMutex.Unlock()
+
+	ctx := context.Background()
+
+	wanted := make(map[string]bool)
+	for _, version := range m.images.Versions() {
+		name := goldenDiskName(version.VersionID, version.URL)
+		wanted[name] = true
+
+		if _, exists, _ := m.informer.GetStore().GetByKey(m.namespace + "/" + name); exists {
+			continue
+		}
+
+		_, err := m.dynamicClient.Resource(VMDiskGVR).Namespace(m.namespace).Create(ctx, m.newGoldenObject(name, version), metav1.CreateOptions{})
+		if err != nil && !strings.Contains(err.Error(), "already exists") {
+			fmt.Printf("Warning: failed to import golden image %s: %v\n", version.VersionID, err)
+		}
+	}
+
+	// Уже созданные клоны от golden-диска не зависят, поэтому устаревшие диски можно удалить
+	for _, obj := range m.informer.GetStore().List() {
+		diskObj, ok := obj.(*unstructured.Unstructured)
+		if !ok || wanted[diskObj.GetName()] {
+			continue
+		}
+
+		err := m.dynamicClient.Resource(VMDiskGVR).Namespace(m.namespace).Delete(ctx, diskObj.GetName(), metav1.DeleteOptions{})
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			fmt.Printf("Warning: failed to delete stale golden image %s: %v\n", diskObj.GetName(), err)
+		}
+	}
+}
+
+// newGoldenObject создает объект VMDisk, в который импортируется версия образа
+func (m *GoldenImageManager) newGoldenObject(name string, version *ImageSource) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "apps.cozystack.io/v1alpha1",
+			"kind":       "VMDisk",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": m.namespace,
+				"labels": map[string]interface{}{
+					"app":        "cozystack-vm",
+					"created-by": "graphql-api",
+					"resource":   "golden-image",
+					"image-id":   version.VersionID,
+				},
+				"annotations": map[string]interface{}{
+					imageURLAnnotation: version.URL,
+				},
+			},
+			"spec": map[string]interface{}{
+				"optical": false,
+				"source": map[string]interface{}{
+					"http": map[string]interface{}{
+						"url": version.URL,
+					},
+				},
+				"storage":      fmt.Sprintf("%dGi", version.Image.DiskGb.Min),
+				"storageClass": "replicated",
+			},
+		},
+	}
+}
+
+// onGoldenChanged обновляет готовность golden-диска по событию информера
+func (m *GoldenImageManager) onGoldenChanged(obj interface{}) {
+	diskObj, ok := objectFromEvent(obj)
+	if !ok {
+		return
+	}
+
+	versionID := diskObj.GetLabels()["image-id"]
+	if versionID == "" {
+		return
+	}
+
+	phase, _, _ := unstructured.NestedString(diskObj.Object, "status", "phase")
+	if phase != "Ready" && phase != "Succeeded" {
+		m.images.ClearGolden(versionID, diskObj.GetName())
+		return
+	}
+
+	storage, _, _ := unstructured.NestedString(diskObj.Object, "spec", "storage")
+	size, err := resource.ParseQuantity(storage)
+	if err != nil {
+		fmt.Printf("Warning: golden image %s has invalid storage %q\n", diskObj.GetName(), storage)
+		return
+	}
+
+	m.images.SetGolden(versionID, GoldenDisk{
+		Name:      diskObj.GetName(),
+		Namespace: diskObj.GetNamespace(),
+		SizeGB:    int((size.Value() + (1 << 30) - 1) >> 30),
+		URL:       diskObj.GetAnnotations()[imageURLAnnotation],
+	})
+}
+
+// onGoldenDeleted снимает отметку о готовности удаленного golden-диска
+func (m *GoldenImageManager) onGoldenDeleted(obj interface{}) {
+	diskObj, ok := objectFromEvent(obj)
+	if !ok {
+		return
+	}
+
+	m.images.ClearGolden(diskObj.GetLabels()["image-id"], diskObj.GetName())
+}
+
+// goldenDiskName возвращает имя golden-диска версии образа. Хэш URL в имени
+// позволяет импортировать образ заново при смене URL
+func goldenDiskName(versionID, imageURL string) string {
+	sum := sha256.Sum256([]byte(imageURL))
+	return goldenDiskPrefix + versionID + "-" + hex.EncodeToString(sum[:])[:8]
+}
@@ -0,0 +1,666 @@
+package cozystack
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gqlfed/instances/cloudinit"
+	"gqlfed/instances/diskimage"
+	"gqlfed/instances/events"
+	"gqlfed/instances/graph/model"
+
+	"github.com/99designs/gqlgen/graphql"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// imageDiskResource - значение метки resource у дисков пользовательских образов
+	imageDiskResource = "custom-image"
+
+	// Аннотации, в которых хранится описание пользовательского образа
+	imageLabelAnnotation     = "image-label"
+	imageOSVersionAnnotation = "image-os-version"
+
+	// defaultImportSizeGB - размер диска для импорта по URL, если он не указан
+	defaultImportSizeGB = 20
+
+	// uploadReadyTimeout определяет, сколько ждать готовности DataVolume к загрузке
+	uploadReadyTimeout = 5 * time.Minute
+
+	// importTimeout определяет, сколько ждать завершения импорта образа
+	importTimeout = 2 * time.Hour
+)
+
+// Статусы импорта образа
+const (
+	importStatusPending   = "PENDING"
+	importStatusUploading = "UPLOADING"
+	importStatusImporting = "IMPORTING"
+	importStatusReady     = "READY"
+	importStatusError     = "ERROR"
+)
+
+// ImageImportManager импортирует пользовательские образы в VMDisk проекта:
+// по URL через импорт CDI или потоковой загрузкой через upload proxy CDI.
+// Готовые образы регистрируются в каталоге, и новые диски клонируются из них
+type ImageImportManager struct {
+	tenants        *TenantMap
+	dynamicClient  dynamic.Interface
+	images         *ImageManager
+	events         *events.Broker
+	uploadProxyURL string
+	httpClient     *http.Client
+	importCache    map[string]*model.ImageImport
+	// tracking содержит импорты, за которыми уже следит горутина
+	tracking   map[string]bool
+	cacheMutex sync.RWMutex
+}
+
+// newImageImportManager создает менеджер импорта образов и подписывает его на
+// изменения VMDisk
+func newImageImportManager(dynamicClient dynamic.Interface, tenants *TenantMap, store *ResourceStore, images *ImageManager,
+	broker *events.Broker, uploadProxyURL string, uploadProxyInsecure bool) (*ImageImportManager, error) {
+	manager := &ImageImportManager{
+		tenants:        tenants,
+		dynamicClient:  dynamicClient,
+		images:         images,
+		events:         broker,
+		uploadProxyURL: strings.TrimSuffix(uploadProxyURL, "/"),
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				// Upload proxy CDI по умолчанию использует самоподписанный сертификат
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: uploadProxyInsecure},
+			},
+		},
+		importCache: make(map[string]*model.ImageImport),
+		tracking:    make(map[string]bool),
+	}
+
+	err := store.AddDiskHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    manager.onImageDiskChanged,
+		UpdateFunc: func(_, newObj interface{}) { manager.onImageDiskChanged(newObj) },
+		DeleteFunc: manager.onImageDiskDeleted,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error registering image import event handler: %v", err)
+	}
+
+	return manager, nil
+}
+
+// ListImports возвращает импорты образов проекта
+func (m *ImageImportManager) ListImports(projectID string) []*model.ImageImport {
+	m.cacheMutex.RLock()
+	defer m.cacheMutex.RUnlock()
+
+	imports := make([]*model.ImageImport, 0)
+	for _, imageImport := range m.importCache {
+		if imageImport.ProjectID == projectID {
+			imports = append(imports, copyImageImport(imageImport))
+		}
+	}
+	sort.Slice(imports, func(i, j int) bool { return imports[i].ImportID < imports[j].ImportID })
+
+	return imports
+}
+
+// ImportURL начинает импорт образа по URL и возвращается, не дожидаясь его завершения
+func (m *ImageImportManager) ImportURL(ctx context.Context, projectID, imageURL, label, osVersion string, sizeGB int) (*model.ImageImport, error) {
+	if err := validateImportInput(label, osVersion); err != nil {
+		return nil, err
+	}
+	if sizeGB <= 0 {
+		return nil, fmt.Errorf("image size must be positive, got %d GB", sizeGB)
+	}
+
+	parsed, err := url.Parse(imageURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("image url must be an http or https url, got %q", imageURL)
+	}
+
+	namespace, err := m.tenants.Namespace(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Формат образа по URL определяет CDI; ISO узнаем по расширению
+	optical := strings.EqualFold(path.Ext(parsed.Path), ".iso")
+
+	source := map[string]interface{}{
+		"http": map[string]interface{}{
+			"url": imageURL,
+		},
+	}
+	imageObject := m.newImageObject(namespace, projectID, label, osVersion, sizeGB, optical, source)
+	imageObject.SetAnnotations(mergeStringMaps(imageObject.GetAnnotations(), map[string]string{imageURLAnnotation: imageURL}))
+
+	imageImport, err := m.createImageDisk(ctx, imageObject, importStatusImporting)
+	if err != nil {
+		return nil, err
+	}
+
+	m.startTracking(namespace, imageImport.ImportID)
+
+	return imageImport, nil
+}
+
+// Upload загружает файл образа в новый VMDisk и возвращается после окончания
+// загрузки. Прогресс публикуется событиями импорта
+func (m *ImageImportManager) Upload(ctx context.Context, projectID string, file graphql.Upload, label, osVersion string) (*model.ImageImport, error) {
+	if err := validateImportInput(label, osVersion); err != nil {
+		return nil, err
+	}
+
+	namespace, err := m.tenants.Namespace(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	info, reader, err := diskimage.Detect(file.File, file.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	imageObject := m.newImageObject(namespace, projectID, label, osVersion, info.SizeGB(), info.Optical(), map[string]interface{}{
+		"upload": map[string]interface{}{},
+	})
+
+	imageImport, err := m.createImageDisk(ctx, imageObject, importStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	imageID := imageImport.ImportID
+
+	// Неудачная загрузка не оставляет после себя диск
+	err = m.upload(ctx, namespace, imageID, reader, file.Size)
+	if err != nil {
+		m.deleteImageDisk(namespace, imageID)
+		return nil, err
+	}
+
+	// После загрузки CDI еще конвертирует образ, готовность отслеживаем отдельно
+	m.updateImport(imageID, func(imageImport *model.ImageImport) {
+		imageImport.Status = importStatusImporting
+	})
+	m.startTracking(namespace, imageID)
+
+	return m.getImport(imageID)
+}
+
+// upload ждет готовности DataVolume и передает образ в upload proxy CDI
+func (m *ImageImportManager) upload(ctx context.Context, namespace, imageID string, reader io.Reader, size int64) error {
+	volumeName := diskVolumePrefix + imageID
+
+	err := m.waitForUploadReady(ctx, namespace, volumeName)
+	if err != nil {
+		return err
+	}
+
+	token, err := m.uploadToken(ctx, namespace, volumeName)
+	if err != nil {
+		return err
+	}
+
+	m.updateImport(imageID, func(imageImport *model.ImageImport) {
+		imageImport.Status = importStatusUploading
+	})
+
+	body := diskimage.NewProgressReader(reader, size, func(percent int32) {
+		m.updateImport(imageID, func(imageImport *model.ImageImport) {
+			imageImport.Progress = percent
+		})
+	})
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, m.uploadProxyURL+"/v1beta1/upload", body)
+	if err != nil {
+		return fmt.Errorf("failed to create upload request: %v", err)
+	}
+	request.ContentLength = size
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("Content-Type", "application/octet-stream")
+
+	response, err := m.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to upload image: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		return fmt.Errorf("upload proxy rejected image: %s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
+
+// waitForUploadReady ждет, пока DataVolume перейдет в фазу UploadReady
+func (m *ImageImportManager) waitForUploadReady(ctx context.Context, namespace, volumeName string) error {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	timeout := time.After(uploadReadyTimeout)
+
+	for {
+		dataVolume, err := m.dynamicClient.Resource(DataVolumeGVR).Namespace(namespace).Get(ctx, volumeName, metav1.GetOptions{})
+		if err == nil {
+			phase, _, _ := unstructured.NestedString(dataVolume.Object, "status", "phase")
+			switch phase {
+			case "UploadReady":
+				return nil
+			case "Failed":
+				return fmt.Errorf("volume %s failed before upload", volumeName)
+			}
+		} else if !strings.Contains(err.Error(), "not found") {
+			return fmt.Errorf("failed to get volume %s: %v", volumeName, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for volume %s to accept the upload", volumeName)
+		case <-ticker.C:
+		}
+	}
+}
+
+// uploadToken запрашивает у CDI токен для загрузки в PVC
+func (m *ImageImportManager) uploadToken(ctx context.Context, namespace, volumeName string) (string, error) {
+	tokenRequest := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "upload.cdi.kubevirt.io/v1beta1",
+			"kind":       "UploadTokenRequest",
+			"metadata": map[string]interface{}{
+				"name":      volumeName,
+				"namespace": namespace,
+			},
+			"spec": map[string]interface{}{
+				"pvcName": volumeName,
+			},
+		},
+	}
+
+	created, err := m.dynamicClient.Resource(UploadTokenRequestGVR).Namespace(namespace).Create(ctx, tokenRequest, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to request upload token: %v", err)
+	}
+
+	token, found, _ := unstructured.NestedString(created.Object, "status", "token")
+	if !found || token == "" {
+		return "", fmt.Errorf("upload token request returned no token")
+	}
+
+	return token, nil
+}
+
+// newImageObject создает объект VMDisk пользовательского образа в namespace тенанта
+func (m *ImageImportManager) newImageObject(namespace, projectID, label, osVersion string, sizeGB int, optical bool, source map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "apps.cozystack.io/v1alpha1",
+			"kind":       "VMDisk",
+			"metadata": map[string]interface{}{
+				"name":      "img-" + rand.String(8),
+				"namespace": namespace,
+				"labels": map[string]interface{}{
+					"app":        "cozystack-vm",
+					"created-by": "graphql-api",
+					"resource":   imageDiskResource,
+					"project-id": projectID,
+				},
+				"annotations": map[string]interface{}{
+					imageLabelAnnotation:     label,
+					imageOSVersionAnnotation: osVersion,
+				},
+			},
+			"spec": map[string]interface{}{
+				"optical":      optical,
+				"source":       source,
+				"storage":      fmt.Sprintf("%dGi", sizeGB),
+				"storageClass": "replicated",
+			},
+		},
+	}
+}
+
+// createImageDisk добавляет импорт в кэш и создает VMDisk образа. Импорт
+// попадает в кэш до создания диска, чтобы информер не принял его за импорт,
+// начатый до перезапуска сервиса
+func (m *ImageImportManager) createImageDisk(ctx context.Context, imageObject *unstructured.Unstructured, status string) (*model.ImageImport, error) {
+	imageImport := convertToImageImport(imageObject)
+	imageImport.Status = status
+	imageID := imageImport.ImportID
+
+	m.cacheMutex.Lock()
+	m.importCache[imageID] = imageImport
+	m.cacheMutex.Unlock()
+
+	_, err := m.dynamicClient.Resource(VMDiskGVR).Namespace(imageObject.GetNamespace()).Create(ctx, imageObject, metav1.CreateOptions{})
+	if err != nil {
+		m.cacheMutex.Lock()
+		delete(m.importCache, imageID)
+		m.cacheMutex.Unlock()
+		return nil, fmt.Errorf("failed to create image disk: %v", err)
+	}
+
+	m.registerImage(imageImport)
+	m.events.Publish(events.NewImageImportEvent(model.EventTypeAdded, imageImport, nil))
+
+	return copyImageImport(imageImport), nil
+}
+
+// deleteImageDisk удаляет VMDisk образа после неудачной загрузки
+func (m *ImageImportManager) deleteImageDisk(namespace, imageID string) {
+	err := m.dynamicClient.Resource(VMDiskGVR).Namespace(namespace).Delete(context.Background(), imageID, metav1.DeleteOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		// Логируем ошибку, но продолжаем
+		fmt.Printf("Warning: failed to delete image disk %s: %v\n", imageID, err)
+	}
+}
+
+// getImport возвращает копию импорта из кэша
+func (m *ImageImportManager) getImport(imageID string) (*model.ImageImport, error) {
+	m.cacheMutex.RLock()
+	defer m.cacheMutex.RUnlock()
+
+	imageImport, exists := m.importCache[imageID]
+	if !exists {
+		return nil, fmt.Errorf("image import not found: %s", imageID)
+	}
+
+	return copyImageImport(imageImport), nil
+}
+
+// updateImport изменяет импорт в кэше и сообщает подписчикам об изменившихся полях.
+// Готовый образ регистрируется в каталоге
+func (m *ImageImportManager) updateImport(imageID string, update func(*model.ImageImport)) {
+	m.cacheMutex.Lock()
+	previous, exists := m.importCache[imageID]
+	if !exists {
+		m.cacheMutex.Unlock()
+		return
+	}
+	imageImport := copyImageImport(previous)
+	update(imageImport)
+	m.importCache[imageID] = imageImport
+	m.cacheMutex.Unlock()
+
+	m.registerImage(imageImport)
+
+	changedFields := events.ImageImportChangedFields(previous, imageImport)
+	if len(changedFields) == 0 {
+		return
+	}
+
+	m.events.Publish(events.NewImageImportEvent(model.EventTypeModified, imageImport, changedFields))
+}
+
+// registerImage добавляет образ в каталог; клонировать можно только готовый образ
+func (m *ImageImportManager) registerImage(imageImport *model.ImageImport) {
+	source := ImageSource{
+		Image:     imageImport.Image,
+		VersionID: imageImport.ImportID,
+		Profile:   cloudinit.ProfileFor(strings.ToLower(imageImport.Image.Label)),
+		ProjectID: imageImport.ProjectID,
+		Optical:   imageImport.Optical,
+	}
+	// Диски проекта клонируются из образа в namespace того же тенанта
+	namespace, err := m.tenants.Namespace(imageImport.ProjectID)
+	if imageImport.Status == importStatusReady && err == nil {
+		source.Golden = &GoldenDisk{
+			Name:      imageImport.ImportID,
+			Namespace: namespace,
+			SizeGB:    int(imageImport.SizeGb),
+		}
+	}
+
+	m.images.SetCustom(source)
+}
+
+// startTracking запускает отслеживание импорта, если за ним еще никто не следит
+func (m *ImageImportManager) startTracking(namespace, imageID string) {
+	m.cacheMutex.Lock()
+	defer m.cacheMutex.Unlock()
+
+	if m.tracking[imageID] {
+		return
+	}
+	m.tracking[imageID] = true
+
+	go m.trackImport(namespace, imageID)
+}
+
+// trackImport переносит прогресс DataVolume в импорт, пока образ не будет
+// импортирован или пока импорт не завершится ошибкой
+func (m *ImageImportManager) trackImport(namespace, imageID string) {
+	defer func() {
+		m.cacheMutex.Lock()
+		delete(m.tracking, imageID)
+		m.cacheMutex.Unlock()
+	}()
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	timeout := time.After(importTimeout)
+	volumeName := diskVolumePrefix + imageID
+
+	for {
+		select {
+		case <-timeout:
+			m.updateImport(imageID, func(imageImport *model.ImageImport) {
+				imageImport.Status = importStatusError
+				imageImport.Error = stringPtr("timed out waiting for the image import to finish")
+			})
+			return
+		case <-ticker.C:
+			imageImport, err := m.getImport(imageID)
+			if err != nil {
+				// Образ удален
+				return
+			}
+			if imageImport.Status == importStatusReady || imageImport.Status == importStatusError {
+				return
+			}
+
+			dataVolume, err := m.dynamicClient.Resource(DataVolumeGVR).Namespace(namespace).Get(context.Background(), volumeName, metav1.GetOptions{})
+			if err != nil {
+				continue
+			}
+
+			phase, _, _ := unstructured.NestedString(dataVolume.Object, "status", "phase")
+			progressStr, _, _ := unstructured.NestedString(dataVolume.Object, "status", "progress")
+
+			m.updateImport(imageID, func(imageImport *model.ImageImport) {
+				if progress, ok := parseProgress(progressStr); ok {
+					imageImport.Progress = progress
+				}
+				switch phase {
+				case "Succeeded":
+					imageImport.Status = importStatusReady
+					imageImport.Progress = 100
+				case "Failed":
+					imageImport.Status = importStatusError
+					imageImport.Error = stringPtr("image import failed")
+				}
+			})
+		}
+	}
+}
+
+// onImageDiskChanged обновляет импорт по событию информера
+func (m *ImageImportManager) onImageDiskChanged(obj interface{}) {
+	diskObj, ok := objectFromEvent(obj)
+	if !ok || !isImageDisk(diskObj) {
+		return
+	}
+
+	imageImport := convertToImageImport(diskObj)
+	imageID := imageImport.ImportID
+
+	m.cacheMutex.Lock()
+	_, exists := m.importCache[imageID]
+	if !exists {
+		// Импорт, начатый до перезапуска сервиса
+		m.importCache[imageID] = imageImport
+	}
+	m.cacheMutex.Unlock()
+
+	if !exists {
+		if imageImport.Status == importStatusImporting {
+			// Прерванную загрузку продолжить нельзя, а импорт по URL CDI продолжает сам
+			if _, found, _ := unstructured.NestedMap(diskObj.Object, "spec", "source", "upload"); found {
+				imageImport.Status = importStatusError
+				imageImport.Error = stringPtr("image upload was interrupted")
+			} else {
+				m.startTracking(diskObj.GetNamespace(), imageID)
+			}
+		}
+		m.registerImage(imageImport)
+		m.events.Publish(events.NewImageImportEvent(model.EventTypeAdded, imageImport, nil))
+		return
+	}
+
+	// Фаза VMDisk подтверждает окончание импорта
+	switch imageImport.Status {
+	case importStatusReady, importStatusError:
+		m.updateImport(imageID, func(cached *model.ImageImport) {
+			cached.Status = imageImport.Status
+			cached.Error = imageImport.Error
+			if imageImport.Status == importStatusReady {
+				cached.Progress = 100
+			}
+		})
+	}
+}
+
+// onImageDiskDeleted удаляет импорт по событию информера
+func (m *ImageImportManager) onImageDiskDeleted(obj interface{}) {
+	diskObj, ok := objectFromEvent(obj)
+	if !ok || !isImageDisk(diskObj) {
+		return
+	}
+
+	m.cacheMutex.Lock()
+	imageImport, exists := m.importCache[diskObj.GetName()]
+	delete(m.importCache, diskObj.GetName())
+	m.cacheMutex.Unlock()
+
+	m.images.RemoveCustom(diskObj.GetName())
+
+	if exists {
+		m.events.Publish(events.NewImageImportEvent(model.EventTypeDeleted, imageImport, nil))
+	}
+}
+
+// convertToImageImport преобразует VMDisk пользовательского образа в модель импорта
+func convertToImageImport(diskObj *unstructured.Unstructured) *model.ImageImport {
+	imageID := diskObj.GetName()
+	annotations := diskObj.GetAnnotations()
+
+	sizeGB := int32(defaultImportSizeGB)
+	storage, _, _ := unstructured.NestedString(diskObj.Object, "spec", "storage")
+	if size, err := resource.ParseQuantity(storage); err == nil {
+		sizeGB = int32((size.Value() + (1 << 30) - 1) >> 30)
+	}
+
+	optical, _, _ := unstructured.NestedBool(diskObj.Object, "spec", "optical")
+
+	imageImport := &model.ImageImport{
+		ImportID:  imageID,
+		ProjectID: diskObj.GetLabels()["project-id"],
+		Image: &model.Image{
+			ImageID: imageID,
+			Label:   annotations[imageLabelAnnotation],
+			OsVersions: []*model.ImageVersion{{
+				VersionName: annotations[imageOSVersionAnnotation],
+				ImageVerID:  imageID,
+			}},
+			CPU:    &model.MinRec{Min: 1, Rec: 1},
+			RAMGb:  &model.MinRec{Min: 1, Rec: 1},
+			DiskGb: &model.MinRec{Min: sizeGB, Rec: sizeGB},
+		},
+		SizeGb:  sizeGB,
+		Optical: optical,
+		Status:  importStatusImporting,
+	}
+
+	phase, _, _ := unstructured.NestedString(diskObj.Object, "status", "phase")
+	switch phase {
+	case "Ready", "Succeeded":
+		imageImport.Status = importStatusReady
+		imageImport.Progress = 100
+	case "Failed":
+		imageImport.Status = importStatusError
+		imageImport.Error = stringPtr("image import failed")
+	}
+
+	return imageImport
+}
+
+// isImageDisk сообщает, хранит ли VMDisk пользовательский образ
+func isImageDisk(diskObj *unstructured.Unstructured) bool {
+	return diskObj.GetLabels()["resource"] == imageDiskResource
+}
+
+// validateImportInput проверяет описание импортируемого образа
+func validateImportInput(label, osVersion string) error {
+	if strings.TrimSpace(label) == "" {
+		return fmt.Errorf("image label must not be empty")
+	}
+	if strings.TrimSpace(osVersion) == "" {
+		return fmt.Errorf("image os version must not be empty")
+	}
+
+	return nil
+}
+
+// parseProgress разбирает прогресс DataVolume вида "45.12%"
+func parseProgress(progress string) (int32, bool) {
+	value, err := strconv.ParseFloat(strings.TrimSuffix(progress, "%"), 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return int32(value), true
+}
+
+// copyImageImport возвращает копию импорта, которую можно отдавать наружу
+func copyImageImport(imageImport *model.ImageImport) *model.ImageImport {
+	imported := *imageImport
+	return &imported
+}
+
+// mergeStringMaps возвращает объединение словарей; значения второго имеют приоритет
+func mergeStringMaps(base, override map[string]string) map[string]string {
+	result := make(map[string]string, len(base)+len(override))
+	for key, value := range base {
+		result[key] = value
+	}
+	for key, value := range override {
+		result[key] = value
+	}
+
+	return result
+}
+
+// stringPtr возвращает указатель на строку
+func stringPtr(value string) *string {
+	return &value
+}
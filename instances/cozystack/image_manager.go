@@ -0,0 +1,509 @@
+package cozystack
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"gqlfed/instances/cloudinit"
+	"gqlfed/instances/graph/model"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// imageConfigKey - ключ ConfigMap, в котором лежит каталог образов
+const imageConfigKey = "images.yaml"
+
+// ImageSpec описывает семейство образов в файле каталога
+type ImageSpec struct {
+	ID    string `json:"image_id"`
+	Label string `json:"label"`
+	// Profile - профиль cloud-init; по умолчанию совпадает с ID
+	Profile  string             `json:"profile,omitempty"`
+	CPU      MinRecSpec         `json:"cpu"`
+	RAMGb    MinRecSpec         `json:"ram_gb"`
+	DiskGb   MinRecSpec         `json:"disk_gb"`
+	Versions []ImageVersionSpec `json:"versions"`
+}
+
+// MinRecSpec описывает минимальное и рекомендуемое значение ресурса
+type MinRecSpec struct {
+	Min int32 `json:"min"`
+	Rec int32 `json:"rec"`
+}
+
+// ImageVersionSpec описывает версию образа и URL, из которого он загружается
+type ImageVersionSpec struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// imageCatalogFile - формат файла каталога образов
+type imageCatalogFile struct {
+	Images []ImageSpec `json:"images"`
+}
+
+// ImageSource описывает версию образа, из которой создается диск
+type ImageSource struct {
+	Image     *model.Image
+	VersionID string
+	URL       string
+	Profile   cloudinit.Profile
+	// Golden - готовый диск с образом, из которого клонируются новые диски;
+	// nil, если образ нужно загружать по URL
+	Golden *GoldenDisk
+	// ProjectID - проект, в который импортирован пользовательский образ;
+	// пустой для образов каталога
+	ProjectID string
+	// Optical - образ ISO, диски из него подключаются как CD-ROM
+	Optical bool
+}
+
+// GoldenDisk описывает VMDisk, в который заранее импортирована версия образа
+type GoldenDisk struct {
+	Name      string
+	Namespace string
+	SizeGB    int
+	// URL - адрес, из которого импортирован диск
+	URL string
+}
+
+// imageVersionRef связывает версию образа с ее семейством
+type imageVersionRef struct {
+	image   *ImageSpec
+	version ImageVersionSpec
+}
+
+// defaultImageCatalog - встроенный каталог образов
+var defaultImageCatalog = []ImageSpec{
+	{
+		ID:     "ubuntu",
+		Label:  "Ubuntu",
+		CPU:    MinRecSpec{Min: 1, Rec: 2},
+		RAMGb:  MinRecSpec{Min: 1, Rec: 2},
+		DiskGb: MinRecSpec{Min: 10, Rec: 20},
+		Versions: []ImageVersionSpec{
+			{ID: "ubuntu-24-04-noble", Name: "24.04 LTS", URL: "https://cloud-images.ubuntu.com/noble/current/noble-server-cloudimg-amd64.img"},
+			{ID: "ubuntu-22-04-jammy", Name: "22.04 LTS", URL: "https://cloud-images.ubuntu.com/jammy/current/jammy-server-cloudimg-amd64.img"},
+		},
+	},
+	{
+		ID:     "debian",
+		Label:  "Debian",
+		CPU:    MinRecSpec{Min: 1, Rec: 2},
+		RAMGb:  MinRecSpec{Min: 1, Rec: 2},
+		DiskGb: MinRecSpec{Min: 10, Rec: 20},
+		Versions: []ImageVersionSpec{
+			{ID: "debian-12-bookworm", Name: "12", URL: "https://cloud.debian.org/images/cloud/bookworm/latest/debian-12-generic-amd64.qcow2"},
+		},
+	},
+	{
+		ID:     "centos",
+		Label:  "CentOS Stream",
+		CPU:    MinRecSpec{Min: 1, Rec: 2},
+		RAMGb:  MinRecSpec{Min: 2, Rec: 4},
+		DiskGb: MinRecSpec{Min: 10, Rec: 20},
+		Versions: []ImageVersionSpec{
+			{ID: "centos-stream-9", Name: "Stream 9", URL: "https://cloud.centos.org/centos/9-stream/x86_64/images/CentOS-Stream-GenericCloud-9-latest.x86_64.qcow2"},
+		},
+	},
+	{
+		ID:     "almalinux",
+		Label:  "AlmaLinux",
+		CPU:    MinRecSpec{Min: 1, Rec: 2},
+		RAMGb:  MinRecSpec{Min: 2, Rec: 4},
+		DiskGb: MinRecSpec{Min: 10, Rec: 20},
+		Versions: []ImageVersionSpec{
+			{ID: "almalinux-9", Name: "9", URL: "https://repo.almalinux.org/almalinux/9/cloud/x86_64/images/AlmaLinux-9-GenericCloud-latest.x86_64.qcow2"},
+		},
+	},
+	{
+		ID:     "fedora",
+		Label:  "Fedora",
+		CPU:    MinRecSpec{Min: 1, Rec: 2},
+		RAMGb:  MinRecSpec{Min: 2, Rec: 4},
+		DiskGb: MinRecSpec{Min: 10, Rec: 20},
+		Versions: []ImageVersionSpec{
+			{ID: "fedora-38", Name: "38", URL: "https://download.fedoraproject.org/pub/fedora/linux/releases/38/Cloud/x86_64/images/Fedora-Cloud-Base-38-1.6.x86_64.qcow2"},
+		},
+	},
+	{
+		ID:     "opensuse",
+		Label:  "openSUSE Leap",
+		CPU:    MinRecSpec{Min: 1, Rec: 2},
+		RAMGb:  MinRecSpec{Min: 1, Rec: 2},
+		DiskGb: MinRecSpec{Min: 10, Rec: 20},
+		Versions: []ImageVersionSpec{
+			{ID: "opensuse-leap-15-5", Name: "15.5", URL: "https://download.opensuse.org/distribution/leap/15.5/appliances/openSUSE-Leap-15.5-JeOS.x86_64-15.5.0-OpenStack-Cloud.qcow2"},
+		},
+	},
+}
+
+// ImageManager хранит каталог образов. Каталог загружается из файла или
+// ConfigMap и перечитывается при их изменении; без источника используется
+// встроенный каталог
+type ImageManager struct {
+	mu       sync.RWMutex
+	images   []ImageSpec
+	byID     map[string]*ImageSpec
+	versions map[string]imageVersionRef
+	byURL    map[string]imageVersionRef
+	// golden хранит готовые golden-диски по ID версии
+	golden map[string]GoldenDisk
+	// custom хранит пользовательские образы по ID
+	custom map[string]ImageSource
+	// onChange вызываются после замены каталога
+	onChange []func()
+	// content - содержимое последнего загруженного каталога
+	content []byte
+	stopCh  chan struct{}
+}
+
+// NewImageManager создает менеджер со встроенным каталогом
+func NewImageManager() *ImageManager {
+	manager := &ImageManager{
+		golden: make(map[string]GoldenDisk),
+		custom: make(map[string]ImageSource),
+		stopCh: make(chan struct{}),
+	}
+
+	if err := manager.setCatalog(defaultImageCatalog, nil); err != nil {
+		panic(fmt.Sprintf("invalid built-in image catalog: %v", err))
+	}
+
+	return manager
+}
+
+// Load заменяет каталог содержимым файла каталога в формате YAML или JSON
+func (m *ImageManager) Load(content []byte) error {
+	m.mu.RLock()
+	unchanged := bytes.Equal(m.content, content)
+	m.mu.RUnlock()
+
+	if unchanged {
+		return nil
+	}
+
+	var file imageCatalogFile
+	if err := yaml.UnmarshalStrict(content, &file); err != nil {
+		return fmt.Errorf("failed to parse image catalog: %v", err)
+	}
+
+	if err := m.setCatalog(file.Images, content); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	onChange := m.onChange
+	m.mu.RUnlock()
+
+	for _, fn := range onChange {
+		fn()
+	}
+
+	return nil
+}
+
+// OnChange регистрирует функцию, которая вызывается после перезагрузки каталога
+func (m *ImageManager) OnChange(fn func()) {
+	m.mu.Lock()
+	m.onChange = append(m.onChange, fn)
+	m.mu.Unlock()
+}
+
+// WatchFile загружает каталог из файла и перечитывает его при изменении
+func (m *ImageManager) WatchFile(path string) error {
+	return watchConfigFile(path, m.stopCh, m.Load)
+}
+
+// WatchConfigMap загружает каталог из ConfigMap и перечитывает его при изменении
+func (m *ImageManager) WatchConfigMap(client kubernetes.Interface, namespace, name string) error {
+	return watchConfigMap(client, namespace, name, imageConfigKey, m.stopCh, m.Load)
+}
+
+// Stop прекращает отслеживание источника каталога
+func (m *ImageManager) Stop() {
+	close(m.stopCh)
+}
+
+// Resolve находит версию образа по ID версии или по ID семейства; для
+// семейства выбирается первая версия в каталоге. Пользовательские образы
+// доступны только своему проекту. Неизвестный ID - ошибка
+func (m *ImageManager) Resolve(projectID, imageID string) (*ImageSource, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if custom, exists := m.custom[imageID]; exists && custom.ProjectID == projectID {
+		return &custom, nil
+	}
+
+	ref, exists := m.versions[imageID]
+	if !exists {
+		image, exists := m.byID[imageID]
+		if !exists {
+			return nil, fmt.Errorf("unknown image %s", imageID)
+		}
+		ref = imageVersionRef{image: image, version: image.Versions[0]}
+	}
+
+	return m.newImageSource(ref), nil
+}
+
+// SetCustom добавляет или обновляет пользовательский образ
+func (m *ImageManager) SetCustom(source ImageSource) {
+	m.mu.Lock()
+	m.custom[source.Image.ImageID] = source
+	m.mu.Unlock()
+}
+
+// RemoveCustom удаляет пользовательский образ
+func (m *ImageManager) RemoveCustom(imageID string) {
+	m.mu.Lock()
+	delete(m.custom, imageID)
+	m.mu.Unlock()
+}
+
+// SourceForURL возвращает версию образа, из которой загружен диск
+func (m *ImageManager) SourceForURL(imageURL string) (*ImageSource, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ref, exists := m.byURL[imageURL]
+	if !exists {
+		return nil, false
+	}
+
+	return m.newImageSource(ref), true
+}
+
+// Versions возвращает все версии образов каталога
+func (m *ImageManager) Versions() []*ImageSource {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var sources []*ImageSource
+	for i := range m.images {
+		for _, version := range m.images[i].Versions {
+			sources = append(sources, m.newImageSource(imageVersionRef{image: &m.images[i], version: version}))
+		}
+	}
+
+	return sources
+}
+
+// SetGolden отмечает golden-диск версии образа готовым
+func (m *ImageManager) SetGolden(versionID string, disk GoldenDisk) {
+	m.mu.Lock()
+	m.golden[versionID] = disk
+	m.mu.Unlock()
+}
+
+// ClearGolden снимает отметку о готовности, если она относится к указанному диску
+func (m *ImageManager) ClearGolden(versionID, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if golden, exists := m.golden[versionID]; exists && golden.Name == name {
+		delete(m.golden, versionID)
+	}
+}
+
+// ImageList возвращает каталог образов и готовые пользовательские образы проекта
+func (m *ImageManager) ImageList(projectID string) []*model.Image {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	images := make([]*model.Image, 0, len(m.images))
+	for i := range m.images {
+		images = append(images, newImageModel(&m.images[i]))
+	}
+
+	if projectID == "" {
+		return images
+	}
+
+	custom := make([]*model.Image, 0)
+	for _, source := range m.custom {
+		if source.ProjectID == projectID && source.Golden != nil {
+			custom = append(custom, source.Image)
+		}
+	}
+	sort.Slice(custom, func(i, j int) bool { return custom[i].ImageID < custom[j].ImageID })
+
+	return append(images, custom...)
+}
+
+// Images возвращает образы по ID семейства в том же порядке, nil для
+// неизвестных ID. Пользовательские образы находятся в любом проекте, но
+// только когда готовы
+func (m *ImageManager) Images(imageIDs []string) []*model.Image {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	images := make([]*model.Image, len(imageIDs))
+	for i, imageID := range imageIDs {
+		if image, exists := m.byID[imageID]; exists {
+			images[i] = newImageModel(image)
+		} else if custom, exists := m.custom[imageID]; exists && custom.Golden != nil {
+			images[i] = custom.Image
+		}
+	}
+
+	return images
+}
+
+// Project возвращает проект пользовательского образа, пустую строку для
+// образов каталога и неизвестных ID
+func (m *ImageManager) Project(imageID string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if custom, exists := m.custom[imageID]; exists {
+		return custom.ProjectID
+	}
+
+	return ""
+}
+
+// setCatalog проверяет каталог и заменяет им текущий
+func (m *ImageManager) setCatalog(images []ImageSpec, content []byte) error {
+	if len(images) == 0 {
+		return fmt.Errorf("image catalog is empty")
+	}
+
+	// Копируем каталог, чтобы индексы ссылались на собственные данные
+	images = append([]ImageSpec(nil), images...)
+
+	byID := make(map[string]*ImageSpec, len(images))
+	versions := make(map[string]imageVersionRef)
+	byURL := make(map[string]imageVersionRef)
+
+	for i := range images {
+		image := &images[i]
+		if err := validateImageSpec(image); err != nil {
+			return err
+		}
+		if _, exists := byID[image.ID]; exists {
+			return fmt.Errorf("duplicate image %s", image.ID)
+		}
+		byID[image.ID] = image
+
+		for _, version := range image.Versions {
+			if _, exists := versions[version.ID]; exists {
+				return fmt.Errorf("duplicate image version %s", version.ID)
+			}
+			ref := imageVersionRef{image: image, version: version}
+			versions[version.ID] = ref
+			byURL[version.URL] = ref
+		}
+	}
+
+	// ID семейства и ID версии не должны совпадать, иначе Resolve неоднозначен
+	for id := range byID {
+		if ref, exists := versions[id]; exists && ref.image.ID != id {
+			return fmt.Errorf("image id %s is also a version of %s", id, ref.image.ID)
+		}
+	}
+
+	m.mu.Lock()
+	m.images = images
+	m.byID = byID
+	m.versions = versions
+	m.byURL = byURL
+	m.content = content
+	m.mu.Unlock()
+
+	return nil
+}
+
+// validateImageSpec проверяет описание образа из каталога
+func validateImageSpec(image *ImageSpec) error {
+	if image.ID == "" {
+		return fmt.Errorf("image without an image_id")
+	}
+	if image.Label == "" {
+		image.Label = image.ID
+	}
+	if image.Profile == "" {
+		image.Profile = image.ID
+	}
+	if _, exists := cloudinit.LookupProfile(image.Profile); !exists {
+		return fmt.Errorf("image %s has unknown profile %q", image.ID, image.Profile)
+	}
+	if len(image.Versions) == 0 {
+		return fmt.Errorf("image %s has no versions", image.ID)
+	}
+
+	for name, minRec := range map[string]MinRecSpec{"cpu": image.CPU, "ram_gb": image.RAMGb, "disk_gb": image.DiskGb} {
+		if minRec.Min <= 0 || minRec.Rec < minRec.Min {
+			return fmt.Errorf("image %s has invalid %s requirements: min %d, rec %d", image.ID, name, minRec.Min, minRec.Rec)
+		}
+	}
+
+	for i := range image.Versions {
+		version := &image.Versions[i]
+		if version.ID == "" {
+			return fmt.Errorf("image %s has a version without an id", image.ID)
+		}
+		// ID версии попадает в метки и имена golden-дисков
+		if errs := validation.IsDNS1123Label(version.ID); len(errs) > 0 {
+			return fmt.Errorf("image version %s has invalid id: %s", version.ID, strings.Join(errs, ", "))
+		}
+		if version.Name == "" {
+			version.Name = version.ID
+		}
+
+		parsed, err := url.Parse(version.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("image version %s has invalid url %q", version.ID, version.URL)
+		}
+	}
+
+	return nil
+}
+
+// newImageSource создает описание источника диска. Golden-диск подставляется,
+// только если он импортирован из текущего URL версии.
+// Вызывающий должен удерживать m.mu
+func (m *ImageManager) newImageSource(ref imageVersionRef) *ImageSource {
+	profile, _ := cloudinit.LookupProfile(ref.image.Profile)
+
+	source := &ImageSource{
+		Image:     newImageModel(ref.image),
+		VersionID: ref.version.ID,
+		URL:       ref.version.URL,
+		Profile:   profile,
+	}
+
+	if golden, exists := m.golden[ref.version.ID]; exists && golden.URL == ref.version.URL {
+		source.Golden = &golden
+	}
+
+	return source
+}
+
+// newImageModel создает GraphQL-модель образа
+func newImageModel(image *ImageSpec) *model.Image {
+	versions := make([]*model.ImageVersion, 0, len(image.Versions))
+	for _, version := range image.Versions {
+		versions = append(versions, &model.ImageVersion{
+			VersionName: version.Name,
+			ImageVerID:  version.ID,
+		})
+	}
+
+	return &model.Image{
+		ImageID:    image.ID,
+		Label:      image.Label,
+		OsVersions: versions,
+		CPU:        &model.MinRec{Min: image.CPU.Min, Rec: image.CPU.Rec},
+		RAMGb:      &model.MinRec{Min: image.RAMGb.Min, Rec: image.RAMGb.Rec},
+		DiskGb:     &model.MinRec{Min: image.DiskGb.Min, Rec: image.DiskGb.Rec},
+	}
+}
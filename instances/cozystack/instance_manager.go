@@ -7,37 +7,93 @@ import (
 	"sync"
 	"time"
 
+	"gqlfed/instances/cloudinit"
+	"gqlfed/instances/events"
 	"gqlfed/instances/graph/model"
+	"gqlfed/instances/network"
+	"gqlfed/instances/pricing"
+	"gqlfed/instances/quota"
+	"gqlfed/instances/requirements"
+	"gqlfed/instances/usage"
 
+	"github.com/99designs/gqlgen/graphql"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/retry"
 )
 
-// VMInstanceGVR определяет GroupVersionResource для VMInstance
-var VMInstanceGVR = schema.GroupVersionResource{
-	Group:    "apps.cozystack.io",
-	Version:  "v1alpha1",
-	Resource: "vminstances",
-}
+// powerActionSettleTime определяет, через сколько после перезагрузки модель
+// инстанса пересобирается из актуального состояния
+const powerActionSettleTime = 30 * time.Second
 
 // InstanceManager управляет виртуальными машинами в CozyStack
 type InstanceManager struct {
-	namespace       string
-	k8sClient       *kubernetes.Clientset
-	dynamicClient   dynamic.Interface
-	diskManager     *DiskManager
-	instanceCache   map[string]*model.Instance
-	cacheMutex      sync.RWMutex
-	stateChangeChan chan interface{}
+	namespace     string
+	tenants       *TenantMap
+	k8sClient     *kubernetes.Clientset
+	dynamicClient dynamic.Interface
+	store         *ResourceStore
+	diskManager   *DiskManager
+	snapshots     *SnapshotManager
+	keys          *KeyManager
+	flavors       *FlavorManager
+	quotas        *QuotaManager
+	images        *ImageManager
+	imports       *ImageImportManager
+	networks      *NetworkManager
+	roles         *RoleManager
+	instanceCache map[string]*model.Instance
+	cacheMutex    sync.RWMutex
+	events        *events.Broker
+}
+
+// Options содержит необязательные настройки менеджера виртуальных машин
+type Options struct {
+	// FlavorConfigPath - путь к файлу каталога flavor
+	FlavorConfigPath string
+	// FlavorConfigMap - имя ConfigMap с каталогом flavor в namespace менеджера
+	FlavorConfigMap string
+	// DiscoverFlavors включает загрузку vCPU и RAM flavor из instancetype кластера
+	DiscoverFlavors bool
+	// QuotaConfigPath - путь к файлу квот проектов
+	QuotaConfigPath string
+	// QuotaConfigMap - имя ConfigMap с квотами проектов в namespace менеджера
+	QuotaConfigMap string
+	// ImageConfigPath - путь к файлу каталога образов
+	ImageConfigPath string
+	// ImageConfigMap - имя ConfigMap с каталогом образов в namespace менеджера
+	ImageConfigMap string
+	// GoldenImageNamespace - публичный namespace, в который образы импортируются
+	// один раз; загрузочные диски клонируются из них. Пустое значение отключает
+	// golden-образы
+	GoldenImageNamespace string
+	// UploadProxyURL - адрес upload proxy CDI, через который загружаются образы
+	UploadProxyURL string
+	// UploadProxyInsecure отключает проверку сертификата upload proxy
+	UploadProxyInsecure bool
+	// TenantNamespacePrefix - префикс namespace тенантов: ресурсы проекта
+	// создаются в namespace префикс+ID проекта. Пустое значение без
+	// TenantNamespaces оставляет все проекты в namespace менеджера
+	TenantNamespacePrefix string
+	// TenantNamespaces явно сопоставляет проектам namespace тенантов
+	TenantNamespaces map[string]string
 }
 
-// NewInstanceManager создает новый менеджер виртуальных машин
-func NewInstanceManager(kubeconfigPath, namespace string) (*InstanceManager, error) {
+// NewInstanceManager создает новый менеджер виртуальных машин. Привязки
+// ролей, SSH-ключи и каталоги хранятся в namespace, ресурсы проектов - в
+// namespace их тенантов
+func NewInstanceManager(kubeconfigPath, namespace string, options Options) (*InstanceManager, error) {
+	tenants, err := NewTenantMap(namespace, options.TenantNamespacePrefix, options.TenantNamespaces)
+	if err != nil {
+		return nil, err
+	}
+
 	// Создаем конфигурацию клиента Kubernetes
 	config, err := clientcmd.BuildConfigFromFlags("", kubeconfigPath)
 	if err != nil {
@@ -56,58 +112,324 @@ func NewInstanceManager(kubeconfigPath, namespace string) (*InstanceManager, err
 		return nil, fmt.Errorf("error creating dynamic client: %v", err)
 	}
 
+	// Информеры VMInstance и VMDisk общие для менеджера инстансов и менеджера дисков
+	store := NewResourceStore(dynamicClient, tenants, defaultResyncPeriod)
+
+	// События инстансов и дисков рассылаются через общий брокер
+	broker := events.NewBroker(events.DefaultBufferSize)
+
+	// Каталог образов общий для менеджера инстансов и менеджера дисков
+	images := NewImageManager()
+
 	// Создаем менеджер дисков
-	diskManager, err := NewDiskManager(kubeconfigPath, namespace)
+	diskManager, err := newDiskManager(dynamicClient, tenants, store, broker, images)
 	if err != nil {
 		return nil, fmt.Errorf("error creating disk manager: %v", err)
 	}
 
+	imports, err := newImageImportManager(dynamicClient, tenants, store, images, broker, options.UploadProxyURL, options.UploadProxyInsecure)
+	if err != nil {
+		return nil, fmt.Errorf("error creating image import manager: %v", err)
+	}
+
 	manager := &InstanceManager{
-		namespace:       namespace,
-		k8sClient:       clientset,
-		dynamicClient:   dynamicClient,
-		diskManager:     diskManager,
-		instanceCache:   make(map[string]*model.Instance),
-		stateChangeChan: make(chan interface{}, 100),
+		namespace:     namespace,
+		tenants:       tenants,
+		k8sClient:     clientset,
+		dynamicClient: dynamicClient,
+		store:         store,
+		diskManager:   diskManager,
+		snapshots:     newSnapshotManager(dynamicClient, tenants, diskManager),
+		keys:          newKeyManager(clientset, namespace),
+		flavors:       NewFlavorManager(),
+		quotas:        NewQuotaManager(),
+		images:        images,
+		imports:       imports,
+		networks:      newNetworkManager(clientset, dynamicClient, tenants, store),
+		roles:         newRoleManager(clientset, namespace),
+		instanceCache: make(map[string]*model.Instance),
+		events:        broker,
+	}
+
+	// Без привязок ролей участники проектов из токенов получили бы права
+	// администратора, поэтому работа без них невозможна
+	if err := manager.roles.Start(); err != nil {
+		return nil, fmt.Errorf("error loading role bindings: %v", err)
 	}
 
-	// Инициализируем кэш
-	err = manager.refreshInstanceCache(context.Background())
+	// Каталог flavor нужен до запуска информеров, так как по нему строятся модели инстансов
+	switch {
+	case options.FlavorConfigPath != "":
+		err = manager.flavors.WatchFile(options.FlavorConfigPath)
+	case options.FlavorConfigMap != "":
+		err = manager.flavors.WatchConfigMap(clientset, namespace, options.FlavorConfigMap)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading flavor catalog: %v", err)
+	}
+
+	// Без квот проекты не ограничены, поэтому ошибка загрузки квот фатальна
+	switch {
+	case options.QuotaConfigPath != "":
+		err = manager.quotas.WatchFile(options.QuotaConfigPath)
+	case options.QuotaConfigMap != "":
+		err = manager.quotas.WatchConfigMap(clientset, namespace, options.QuotaConfigMap)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading quotas: %v", err)
+	}
+
+	// Каталог образов нужен до запуска информеров, так как по нему определяются образы дисков
+	switch {
+	case options.ImageConfigPath != "":
+		err = images.WatchFile(options.ImageConfigPath)
+	case options.ImageConfigMap != "":
+		err = images.WatchConfigMap(clientset, namespace, options.ImageConfigMap)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading image catalog: %v", err)
+	}
+
+	if options.GoldenImageNamespace != "" {
+		err = newGoldenImageManager(dynamicClient, options.GoldenImageNamespace, images).Start()
+		if err != nil {
+			// Логируем ошибку, но продолжаем работу с загрузкой образов по HTTP
+			fmt.Printf("Warning: Failed to start golden images: %v\n", err)
+		}
+	}
+
+	if options.DiscoverFlavors {
+		err = manager.flavors.WatchInstancetypes(dynamicClient)
+		if err != nil {
+			// Логируем ошибку, но продолжаем работу с прайс-листом
+			fmt.Printf("Warning: Failed to discover instancetypes: %v\n", err)
+		}
+	}
+
+	// Адреса VM в сетях меняются после запуска VM, поэтому инстанс пересобирается
+	// при изменении VirtualMachineInstance
+	err = manager.networks.AddVMIHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    manager.onVMIChanged,
+		UpdateFunc: func(_, newObj interface{}) { manager.onVMIChanged(newObj) },
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error registering vmi event handler: %v", err)
+	}
+
+	// Сети нужны до запуска информеров, так как по ним строятся модели инстансов
+	err = manager.networks.Start()
+	if err != nil {
+		// Логируем ошибку, но продолжаем работу без сетей
+		fmt.Printf("Warning: Failed to start network manager: %v\n", err)
+	}
+
+	// Подписываемся на изменения инстансов и их дисков
+	err = store.AddInstanceHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { manager.onInstanceChanged(obj, model.EventTypeAdded) },
+		UpdateFunc: func(_, newObj interface{}) { manager.onInstanceChanged(newObj, model.EventTypeModified) },
+		DeleteFunc: manager.onInstanceDeleted,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error registering instance event handler: %v", err)
+	}
+
+	err = store.AddDiskHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    manager.onDiskChanged,
+		UpdateFunc: func(_, newObj interface{}) { manager.onDiskChanged(newObj) },
+		DeleteFunc: manager.onDiskChanged,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error registering disk event handler: %v", err)
+	}
+
+	// Запускаем информеры, они заполнят кэш
+	err = store.Start(defaultSyncTimeout)
 	if err != nil {
 		// Логируем ошибку, но продолжаем работу
 		fmt.Printf("Warning: Failed to initialize instance cache: %v\n", err)
 	}
 
-	// Запускаем горутину для отслеживания изменений
-	go manager.watchInstances()
+	// Состав групп безопасности мог измениться, пока сервис не работал
+	go manager.networks.SyncSecurityGroups(context.Background())
 
 	return manager, nil
 }
 
+// GetProjects возвращает ресурсы и потребление проектов в том же порядке.
+// Проекты ведет другой подграф, поэтому проект без ресурсов тоже находится
+func (m *InstanceManager) GetProjects(ctx context.Context, projectIDs []string) ([]*model.Project, error) {
+	projects := make([]*model.Project, len(projectIDs))
+	for i, projectID := range projectIDs {
+		// Пустой ID выбрал бы ресурсы всех проектов
+		if projectID == "" {
+			continue
+		}
+
+		instances, err := m.GetInstanceList(ctx, projectID)
+		if err != nil {
+			return nil, err
+		}
+		if instances == nil {
+			instances = []*model.Instance{}
+		}
+
+		disks, err := m.GetDiskList(ctx, projectID)
+		if err != nil {
+			return nil, err
+		}
+
+		projects[i] = &model.Project{
+			ProjectID:   projectID,
+			Instances:   instances,
+			Disks:       disks,
+			Networks:    m.networks.ListNetworks(&projectID),
+			Usage:       usage.Compute(instances, disks),
+			MonthlyCost: pricing.ProjectMonthly(m.flavors.Prices(), instances, disks),
+		}
+	}
+
+	return projects, nil
+}
+
+// GetProjectQuota возвращает квоты проекта вместе с его текущим потреблением
+func (m *InstanceManager) GetProjectQuota(ctx context.Context, projectID string) (*model.ProjectQuota, error) {
+	if projectID == "" {
+		return nil, fmt.Errorf("project_id must not be empty")
+	}
+
+	used, err := m.projectUsage(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.ProjectQuota{
+		ProjectID: projectID,
+		Limits:    m.quotas.Limits(projectID),
+		Usage:     used,
+	}, nil
+}
+
 // CreateInstance создает новую виртуальную машину
 func (m *InstanceManager) CreateInstance(ctx context.Context, input model.NewInstanceInput) (*model.Instance, error) {
-	// Генерируем имена ресурсов
-	diskID := fmt.Sprintf("vmd-%s", input.ID)
-	instanceID := fmt.Sprintf("vmi-%s", input.ID)
+	if input.ProjectID == "" {
+		return nil, fmt.Errorf("project_id must not be empty")
+	}
+
+	namespace, err := m.tenants.Namespace(input.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Генерируем имена ресурсов; диск и инстанс получают общий суффикс
+	suffix := rand.String(8)
+	diskID := fmt.Sprintf("vmd-%s", suffix)
+	instanceID := fmt.Sprintf("vmi-%s", suffix)
 
 	// Проверяем, существует ли VM с таким ID
-	_, err := m.dynamicClient.Resource(VMInstanceGVR).Namespace(m.namespace).Get(ctx, instanceID, metav1.GetOptions{})
+	_, err = m.dynamicClient.Resource(VMInstanceGVR).Namespace(namespace).Get(ctx, instanceID, metav1.GetOptions{})
 	if err == nil {
 		return nil, fmt.Errorf("instance with ID %s already exists", instanceID)
 	}
 
+	// Неизвестный flavor не подменяется flavor по умолчанию
+	instanceFlavor, err := m.flavors.Require(input.InstanceType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Проверяем образ до создания ресурсов
+	image, diskGB, err := m.bootDisk(input)
+	if err != nil {
+		return nil, err
+	}
+
+	err = requirements.Check(image.Image, instanceFlavor, diskGB)
+	if err != nil {
+		return nil, err
+	}
+
+	networks, err := m.networks.ResolveNetworks(input.ProjectID, input.Region, input.NetworkIds)
+	if err != nil {
+		return nil, err
+	}
+
+	externalMethod, externalPorts, err := network.InstanceExternal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	subnets := make([]interface{}, 0, len(networks))
+	for _, attached := range networks {
+		subnets = append(subnets, map[string]interface{}{"name": attached.NetworkID})
+	}
+
+	// Находим выбранные SSH-ключи до создания ресурсов
+	keys, err := m.keys.ResolveKeys(ctx, input.ProjectID, input.KeyNames)
+	if err != nil {
+		return nil, err
+	}
+	keyNames := strings.Join(input.KeyNames, ",")
+
+	publicKeys := make([]string, 0, len(keys))
+	fingerprints := make([]string, 0, len(keys))
+	for _, key := range keys {
+		publicKeys = append(publicKeys, key.PublicKey)
+		fingerprints = append(fingerprints, key.Fingerprint)
+	}
+
+	// Собираем cloud-init до создания ресурсов, чтобы ошибка в userData
+	// не оставила после себя диск
+	profile := image.Profile
+
+	password, err := cloudinit.GeneratePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := cloudinit.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	cloudInit, err := cloudinit.Compose(cloudinit.Config{
+		Hostname:     input.Hostname,
+		Profile:      profile,
+		SSHKeys:      publicKeys,
+		PasswordHash: passwordHash,
+		UserData:     valueOrEmpty(input.UserData),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Квота проверяется последней, и блокировка держится до появления инстанса в кэше
+	unlock := m.quotas.Lock(input.ProjectID)
+	defer unlock()
+
+	requested := usage.Compute(
+		[]*model.Instance{{Flavor: instanceFlavor, ExternalMethod: externalMethod, ExternalPorts: externalPorts}},
+		[]*model.Disk{{SizeGb: diskGB}},
+	)
+	if err := m.checkQuota(ctx, input.ProjectID, requested); err != nil {
+		return nil, err
+	}
+
 	// Создаем виртуальный диск
-	_, err = m.diskManager.CreateDisk(ctx, diskID, 20, input.ImageID)
+	_, err = m.diskManager.CreateDisk(ctx, input.ProjectID, diskID, int(diskGB), image.VersionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create disk: %v", err)
 	}
 
+	// Пароль хранится в Secret, в VMInstance попадает только его хэш
+	err = m.createCredentials(ctx, namespace, input.ProjectID, instanceID, profile.DefaultUser, password)
+	if err != nil {
+		m.diskManager.DeleteDisk(ctx, diskID)
+		return nil, err
+	}
+
 	// Ждем некоторое время, чтобы диск начал создаваться
 	time.Sleep(2 * time.Second)
 
-	// Создаем базовый cloud-init конфиг
-	cloudInit := generateCloudInit(input.Hostname)
-
 	// Создаем объект ресурса VMInstance
 	instanceObject := &unstructured.Unstructured{
 		Object: map[string]interface{}{
@@ -115,416 +437,1103 @@ func (m *InstanceManager) CreateInstance(ctx context.Context, input model.NewIns
 			"kind":       "VMInstance",
 			"metadata": map[string]interface{}{
 				"name":      instanceID,
-				"namespace": m.namespace,
+				"namespace": namespace,
 				"labels": map[string]interface{}{
 					"app":        "cozystack-vm",
 					"created-by": "graphql-api",
-					"project-id": input.ID,
+					"project-id": input.ProjectID,
 					"hostname":   input.Hostname,
 					"region":     input.Region,
 				},
+				"annotations": map[string]interface{}{
+					sshKeysAnnotation:            keyNames,
+					sshKeyFingerprintsAnnotation: strings.Join(fingerprints, ","),
+				},
 			},
 			"spec": map[string]interface{}{
 				"cloudInit":       cloudInit,
 				"disks":           []interface{}{map[string]interface{}{"name": diskID}},
-				"external":        true,
-				"externalMethod":  "PortList",
-				"externalPorts":   []interface{}{22},
-				"instanceProfile": "ubuntu",
+				"instanceProfile": profile.InstanceProfile,
 				"instanceType":    input.InstanceType,
 				"running":         true,
+				"subnets":         subnets,
 			},
 		},
 	}
 
-	// Создаем инстанс через API Kubernetes с использованием retry для надежности
-	var createdInstance *unstructured.Unstructured
+	setExternalSpec(instanceObject, externalMethod, externalPorts)
 
+	// Создаем инстанс через API Kubernetes с использованием retry для надежности
 	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		var createErr error
-		createdInstance, createErr = m.dynamicClient.Resource(VMInstanceGVR).Namespace(m.namespace).Create(ctx, instanceObject, metav1.CreateOptions{})
+		_, createErr := m.dynamicClient.Resource(VMInstanceGVR).Namespace(namespace).Create(ctx, instanceObject, metav1.CreateOptions{})
 		return createErr
 	})
 
 	if err != nil {
-		// Если создание VM не удалось, удаляем созданный диск
-		m.diskManager.DeleteDisk(ctx, diskID)
-		return nil, fmt.Errorf("failed to create VM: %v", err)
+		// Если создание VM не удалось, удаляем созданный диск и пароль
+		m.diskManager.DeleteDisk(ctx, diskID)
+		m.deleteCredentials(ctx, namespace, instanceID)
+		return nil, fmt.Errorf("failed to create VM: %v", err)
+	}
+
+	// Получаем информацию о созданном диске
+	disk, err := m.diskManager.GetDisk(ctx, diskID)
+	if err != nil {
+		disk = &model.Disk{
+			DiskID:    diskID,
+			ProjectID: input.ProjectID,
+			SizeGb:    diskGB,
+			Bootable:  true,
+			Status:    "CREATING",
+		}
+	}
+
+	// Создаем модель инстанса
+	instance := &model.Instance{
+		InstanceID:        instanceID,
+		ProjectID:         input.ProjectID,
+		Name:              input.Hostname,
+		Status:            "PROVISIONING",
+		Created:           time.Now().Format(time.RFC3339),
+		Updated:           time.Now().Format(time.RFC3339),
+		KeyName:           keyNames,
+		Locked:            false,
+		Loading:           true,
+		PowerState:        model.PowerStateStarting,
+		IPV4:              "", // Будет заполнено позже
+		Flavor:            instanceFlavor,
+		AttachedDisks:     []*model.Disk{disk},
+		AttachedNetworks:  networks,
+		ExternalMethod:    externalMethod,
+		ExternalPorts:     externalPorts,
+		ExternalAddresses: []string{},
+	}
+	instance.Cost = pricing.Instance(m.flavors.Prices(), instance)
+
+	// Добавляем в кэш, дальнейшие изменения статуса придут через информер
+	m.cacheMutex.Lock()
+	m.instanceCache[instanceID] = instance
+	m.cacheMutex.Unlock()
+
+	return instance, nil
+}
+
+// EstimateInstanceCost считает стоимость инстанса, который CreateInstance
+// создал бы по тем же параметрам
+func (m *InstanceManager) EstimateInstanceCost(ctx context.Context, input model.NewInstanceInput) (*model.Cost, error) {
+	if input.ProjectID == "" {
+		return nil, fmt.Errorf("project_id must not be empty")
+	}
+	// Цена неизвестного flavor не показывается: заказать его нельзя
+	instanceFlavor, err := m.flavors.Require(input.InstanceType)
+	if err != nil {
+		return nil, err
+	}
+
+	_, diskGB, err := m.bootDisk(input)
+	if err != nil {
+		return nil, err
+	}
+
+	externalMethod, externalPorts, err := network.InstanceExternal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return pricing.Instance(m.flavors.Prices(), &model.Instance{
+		Flavor:         instanceFlavor,
+		AttachedDisks:  []*model.Disk{{SizeGb: diskGB}},
+		ExternalMethod: externalMethod,
+		ExternalPorts:  externalPorts,
+	}), nil
+}
+
+// bootDisk возвращает образ нового инстанса и размер его загрузочного диска.
+// Неизвестные образы не подменяются образом по умолчанию; без указанного
+// размера диск создается рекомендуемого для образа размера
+func (m *InstanceManager) bootDisk(input model.NewInstanceInput) (*ImageSource, int32, error) {
+	image, err := m.images.Resolve(input.ProjectID, input.ImageID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if image.Optical {
+		return nil, 0, fmt.Errorf("image %s is an ISO and cannot be used as a boot disk", input.ImageID)
+	}
+
+	diskGB := image.Image.DiskGb.Rec
+	if input.DiskGb != nil {
+		diskGB = *input.DiskGb
+		if err := requirements.CheckDiskSize(diskGB, "disk_gb"); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return image, diskGB, nil
+}
+
+// DeleteInstance удаляет виртуальную машину вместе с ее загрузочным диском
+func (m *InstanceManager) DeleteInstance(ctx context.Context, instanceID string) (bool, error) {
+	// Находим информацию об инстансе
+	instance, err := m.GetInstanceItem(ctx, instanceID)
+	if err != nil {
+		return false, fmt.Errorf("failed to find instance: %v", err)
+	}
+
+	namespace, err := m.tenants.Namespace(instance.ProjectID)
+	if err != nil {
+		return false, err
+	}
+
+	// Вместе с VM удаляется только загрузочный (первый) диск. Остальные диски
+	// с данными пользователя отключаются и остаются в проекте
+	bootDiskID := ""
+	if len(instance.AttachedDisks) > 0 {
+		bootDiskID = instance.AttachedDisks[0].DiskID
+	}
+
+	// Удаляем VMInstance через API Kubernetes с использованием retry
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		return m.dynamicClient.Resource(VMInstanceGVR).Namespace(namespace).Delete(ctx, instanceID, metav1.DeleteOptions{})
+	})
+
+	if err != nil {
+		// Если инстанс не найден, считаем операцию успешной
+		if strings.Contains(err.Error(), "not found") {
+			// Удаляем из кэша
+			m.cacheMutex.Lock()
+			delete(m.instanceCache, instanceID)
+			m.cacheMutex.Unlock()
+
+			// Продолжаем с удалением загрузочного диска
+		} else {
+			return false, fmt.Errorf("failed to delete VM: %v", err)
+		}
+	}
+
+	m.deleteCredentials(ctx, namespace, instanceID)
+
+	if bootDiskID != "" {
+		if err := m.diskManager.DeleteDisk(ctx, bootDiskID); err != nil {
+			// Логируем ошибку, но продолжаем
+			fmt.Printf("Warning: failed to delete disk %s: %v\n", bootDiskID, err)
+		}
+	}
+
+	// Удаляем из кэша, уведомление подписчикам отправит обработчик информера
+	m.cacheMutex.Lock()
+	delete(m.instanceCache, instanceID)
+	m.cacheMutex.Unlock()
+
+	return true, nil
+}
+
+// StartInstance включает виртуальную машину
+func (m *InstanceManager) StartInstance(ctx context.Context, instanceID string) (*model.Instance, error) {
+	return m.setRunning(ctx, instanceID, true)
+}
+
+// StopInstance выключает виртуальную машину
+func (m *InstanceManager) StopInstance(ctx context.Context, instanceID string) (*model.Instance, error) {
+	return m.setRunning(ctx, instanceID, false)
+}
+
+// RebootInstance перезагружает виртуальную машину через subresource restart KubeVirt
+func (m *InstanceManager) RebootInstance(ctx context.Context, instanceID string) (*model.Instance, error) {
+	instance, err := m.requireRunning(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, err := m.tenants.Namespace(instance.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.k8sClient.CoreV1().RESTClient().Put().
+		AbsPath("/apis/subresources.kubevirt.io/v1/namespaces", namespace, "virtualmachines", kubevirtVMPrefix+instanceID, "restart").
+		Do(ctx).
+		Error()
+	if err != nil {
+		return nil, fmt.Errorf("failed to reboot VM: %v", err)
+	}
+
+	return m.markRebooting(instance), nil
+}
+
+// ResetInstance выполняет жесткий сброс: VirtualMachineInstance удаляется без
+// ожидания завершения гостевой ОС, и KubeVirt сразу запускает VM заново
+func (m *InstanceManager) ResetInstance(ctx context.Context, instanceID string) (*model.Instance, error) {
+	instance, err := m.requireRunning(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, err := m.tenants.Namespace(instance.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	gracePeriod := int64(0)
+	err = m.dynamicClient.Resource(KubeVirtVMIGVR).Namespace(namespace).Delete(ctx, kubevirtVMPrefix+instanceID, metav1.DeleteOptions{
+		GracePeriodSeconds: &gracePeriod,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reset VM: %v", err)
+	}
+
+	return m.markRebooting(instance), nil
+}
+
+// setRunning меняет spec.running у VMInstance
+func (m *InstanceManager) setRunning(ctx context.Context, instanceID string, running bool) (*model.Instance, error) {
+	namespace, err := m.instanceNamespace(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := []byte(fmt.Sprintf(`{"spec":{"running":%t}}`, running))
+
+	vmObj, err := m.dynamicClient.Resource(VMInstanceGVR).Namespace(namespace).Patch(ctx, instanceID, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update VM power state: %v", err)
+	}
+
+	// Обновляем кэш сразу, не дожидаясь информера; повторное событие от
+	// информера не изменит полей и не будет разослано
+	m.onInstanceChanged(vmObj, model.EventTypeModified)
+
+	return m.GetInstanceItem(ctx, instanceID)
+}
+
+// instanceNamespace возвращает namespace тенанта, в котором находится инстанс
+func (m *InstanceManager) instanceNamespace(ctx context.Context, instanceID string) (string, error) {
+	instance, err := m.GetInstanceItem(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+
+	return m.tenants.Namespace(instance.ProjectID)
+}
+
+// requireRunning возвращает инстанс, если виртуальная машина включена
+func (m *InstanceManager) requireRunning(ctx context.Context, instanceID string) (*model.Instance, error) {
+	instance, err := m.GetInstanceItem(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if instance.PowerState != model.PowerStateActive {
+		return nil, fmt.Errorf("instance %s is not running (power state %s)", instanceID, instance.PowerState)
+	}
+
+	return instance, nil
+}
+
+// markRebooting помечает инстанс как перезагружающийся. Статус VMInstance во
+// время перезагрузки не меняется, поэтому через powerActionSettleTime
+// модель пересобирается из хранилища информера
+func (m *InstanceManager) markRebooting(instance *model.Instance) *model.Instance {
+	rebooting := *instance
+	rebooting.PowerState = model.PowerStateRebooting
+	rebooting.Loading = true
+	rebooting.Updated = time.Now().Format(time.RFC3339)
+
+	m.cacheMutex.Lock()
+	m.instanceCache[rebooting.InstanceID] = &rebooting
+	m.cacheMutex.Unlock()
+
+	m.events.Publish(events.NewInstanceEvent(model.EventTypeModified, &rebooting, events.InstanceChangedFields(instance, &rebooting)))
+
+	time.AfterFunc(powerActionSettleTime, func() {
+		if vmObj, exists := m.store.GetInstance(rebooting.InstanceID); exists {
+			m.onInstanceChanged(vmObj, model.EventTypeModified)
+		}
+	})
+
+	return &rebooting
+}
+
+// GetInstanceList возвращает список виртуальных машин для проекта
+func (m *InstanceManager) GetInstanceList(ctx context.Context, projectID string) ([]*model.Instance, error) {
+	// Кэш поддерживается информером, поэтому обращения к API не требуется
+	m.cacheMutex.RLock()
+	defer m.cacheMutex.RUnlock()
+
+	var instances []*model.Instance
+	for _, instance := range m.instanceCache {
+		if projectID == "" || instance.ProjectID == projectID {
+			instances = append(instances, instance)
+		}
+	}
+
+	return instances, nil
+}
+
+// GetInstanceItem возвращает информацию о конкретной виртуальной машине
+func (m *InstanceManager) GetInstanceItem(ctx context.Context, instanceID string) (*model.Instance, error) {
+	m.cacheMutex.RLock()
+	defer m.cacheMutex.RUnlock()
+
+	instance, exists := m.instanceCache[instanceID]
+	if !exists {
+		return nil, fmt.Errorf("instance not found: %s", instanceID)
+	}
+
+	return instance, nil
+}
+
+// GetInstancesByIDs возвращает инстансы по ID в том же порядке, nil для неизвестных ID
+func (m *InstanceManager) GetInstancesByIDs(ctx context.Context, instanceIDs []string) ([]*model.Instance, error) {
+	m.cacheMutex.RLock()
+	defer m.cacheMutex.RUnlock()
+
+	instances := make([]*model.Instance, len(instanceIDs))
+	for i, instanceID := range instanceIDs {
+		instances[i] = m.instanceCache[instanceID]
+	}
+
+	return instances, nil
+}
+
+// Subscribe подписывает клиента на изменения состояния инстансов
+func (m *InstanceManager) Subscribe(ctx context.Context) <-chan events.Event {
+	return m.events.Subscribe(ctx)
+}
+
+// GetDiskList возвращает диски проекта вместе с инстансами, к которым они подключены
+func (m *InstanceManager) GetDiskList(ctx context.Context, projectID string) ([]*model.Disk, error) {
+	disks, err := m.diskManager.ListDisks(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.Disk, 0, len(disks))
+	for _, disk := range disks {
+		result = append(result, m.withInstances(disk))
+	}
+
+	return result, nil
+}
+
+// GetDisk возвращает диск вместе с инстансом, к которому он подключен
+func (m *InstanceManager) GetDisk(ctx context.Context, diskID string) (*model.Disk, error) {
+	disk, err := m.diskManager.GetDisk(ctx, diskID)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.withInstances(disk), nil
+}
+
+// GetDisksByIDs возвращает диски по ID в том же порядке, nil для неизвестных ID
+func (m *InstanceManager) GetDisksByIDs(ctx context.Context, diskIDs []string) ([]*model.Disk, error) {
+	disks := make([]*model.Disk, len(diskIDs))
+	for i, diskID := range diskIDs {
+		disk, err := m.diskManager.GetDisk(ctx, diskID)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				continue
+			}
+			return nil, err
+		}
+		disks[i] = m.withInstances(disk)
+	}
+
+	return disks, nil
+}
+
+// CreateDisk создает отдельный диск, который затем можно подключить к инстансу
+func (m *InstanceManager) CreateDisk(ctx context.Context, input model.NewDiskInput) (*model.Disk, error) {
+	if input.SizeGb <= 0 {
+		return nil, fmt.Errorf("disk size must be positive, got %d", input.SizeGb)
+	}
+
+	imageID := ""
+	if input.ImageID != nil {
+		imageID = *input.ImageID
+	}
+
+	diskID := fmt.Sprintf("vmd-%s", rand.String(8))
+
+	unlock := m.quotas.Lock(input.ProjectID)
+	defer unlock()
+
+	if err := m.checkQuota(ctx, input.ProjectID, &model.ProjectUsage{DiskGb: input.SizeGb}); err != nil {
+		return nil, err
+	}
+
+	disk, err := m.diskManager.CreateDisk(ctx, input.ProjectID, diskID, int(input.SizeGb), imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.withInstances(disk), nil
+}
+
+// ResizeDisk увеличивает размер диска
+func (m *InstanceManager) ResizeDisk(ctx context.Context, diskID string, sizeGB int32) (*model.Disk, error) {
+	current, err := m.diskManager.GetDisk(ctx, diskID)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := m.quotas.Lock(current.ProjectID)
+	defer unlock()
+
+	// Уменьшение диска отклонит менеджер дисков, квота проверяется только для прироста
+	if sizeGB > current.SizeGb {
+		err = m.checkQuota(ctx, current.ProjectID, &model.ProjectUsage{DiskGb: sizeGB - current.SizeGb})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	disk, err := m.diskManager.ResizeDisk(ctx, diskID, int(sizeGB))
+	if err != nil {
+		return nil, err
+	}
+
+	return m.withInstances(disk), nil
+}
+
+// DeleteDisk удаляет диск, если он не подключен ни к одному инстансу
+func (m *InstanceManager) DeleteDisk(ctx context.Context, diskID string) (bool, error) {
+	if _, err := m.diskManager.GetDisk(ctx, diskID); err != nil {
+		return false, err
+	}
+
+	if instance := m.diskOwner(diskID); instance != nil {
+		return false, fmt.Errorf("disk %s is attached to instance %s", diskID, instance.InstanceID)
+	}
+
+	if err := m.diskManager.DeleteDisk(ctx, diskID); err != nil {
+		return false, fmt.Errorf("failed to delete disk: %v", err)
+	}
+
+	return true, nil
+}
+
+// AttachDisk подключает диск к инстансу, добавляя его в spec.disks VMInstance
+func (m *InstanceManager) AttachDisk(ctx context.Context, instanceID, diskID string) (*model.Instance, error) {
+	instance, err := m.GetInstanceItem(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	disk, err := m.diskManager.GetDisk(ctx, diskID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Диск можно подключить только к инстансу своего проекта, даже если
+	// проекты делят один namespace
+	if disk.ProjectID != instance.ProjectID {
+		return nil, fmt.Errorf("disk %s belongs to another project than instance %s", diskID, instanceID)
+	}
+
+	if owner := m.diskOwner(diskID); owner != nil {
+		return nil, fmt.Errorf("disk %s is already attached to instance %s", diskID, owner.InstanceID)
+	}
+
+	return m.updateDisks(ctx, instanceID, func(disks []interface{}) ([]interface{}, error) {
+		return append(disks, map[string]interface{}{"name": diskID}), nil
+	})
+}
+
+// DetachDisk отключает диск от инстанса. Загрузочный (первый) диск отключить нельзя
+func (m *InstanceManager) DetachDisk(ctx context.Context, instanceID, diskID string) (*model.Instance, error) {
+	if _, err := m.GetInstanceItem(ctx, instanceID); err != nil {
+		return nil, err
+	}
+
+	return m.updateDisks(ctx, instanceID, func(disks []interface{}) ([]interface{}, error) {
+		for i, diskData := range disks {
+			diskRef, ok := diskData.(map[string]interface{})
+			if !ok || diskRef["name"] != diskID {
+				continue
+			}
+			if i == 0 {
+				return nil, fmt.Errorf("disk %s is the boot disk of instance %s", diskID, instanceID)
+			}
+			return append(disks[:i:i], disks[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("disk %s is not attached to instance %s", diskID, instanceID)
+	})
+}
+
+// ListSnapshots возвращает снимки дисков проекта
+func (m *InstanceManager) ListSnapshots(ctx context.Context, projectID string, diskID *string) ([]*model.Snapshot, error) {
+	filter := ""
+	if diskID != nil {
+		filter = *diskID
+	}
+
+	return m.snapshots.ListSnapshots(projectID, filter), nil
+}
+
+// CreateSnapshot создает снимок диска
+func (m *InstanceManager) CreateSnapshot(ctx context.Context, diskID string) (*model.Snapshot, error) {
+	return m.snapshots.CreateSnapshot(ctx, diskID)
+}
+
+// GetSnapshot возвращает снимок по ID
+func (m *InstanceManager) GetSnapshot(ctx context.Context, snapshotID string) (*model.Snapshot, error) {
+	return m.snapshots.GetSnapshot(snapshotID)
+}
+
+// DeleteSnapshot удаляет снимок диска
+func (m *InstanceManager) DeleteSnapshot(ctx context.Context, snapshotID string) (bool, error) {
+	if err := m.snapshots.DeleteSnapshot(ctx, snapshotID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// RestoreSnapshot возвращает диск к состоянию снимка. Диск пересоздается,
+// поэтому инстанс, к которому он подключен, должен быть выключен
+func (m *InstanceManager) RestoreSnapshot(ctx context.Context, snapshotID string) (*model.Disk, error) {
+	snapshot, err := m.snapshots.GetSnapshot(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	if owner := m.diskOwner(snapshot.DiskID); owner != nil && owner.PowerState != model.PowerStateStopped {
+		return nil, fmt.Errorf("instance %s must be stopped before restoring disk %s", owner.InstanceID, snapshot.DiskID)
+	}
+
+	disk, err := m.snapshots.RestoreSnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.withInstances(disk), nil
+}
+
+// CreateDiskFromSnapshot создает новый диск из снимка
+func (m *InstanceManager) CreateDiskFromSnapshot(ctx context.Context, snapshotID string, sizeGB *int32) (*model.Disk, error) {
+	snapshot, err := m.snapshots.GetSnapshot(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int(snapshot.SizeGb)
+	if sizeGB != nil {
+		size = int(*sizeGB)
+	}
+
+	diskID := fmt.Sprintf("vmd-%s", rand.String(8))
+
+	unlock := m.quotas.Lock(snapshot.ProjectID)
+	defer unlock()
+
+	if err := m.checkQuota(ctx, snapshot.ProjectID, &model.ProjectUsage{DiskGb: int32(size)}); err != nil {
+		return nil, err
+	}
+
+	disk, err := m.snapshots.CreateDiskFromSnapshot(ctx, snapshotID, diskID, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.withInstances(disk), nil
+}
+
+// SetInstancePorts меняет способ публикации инстанса и открытые наружу порты
+func (m *InstanceManager) SetInstancePorts(ctx context.Context, instanceID string, ports []int32, method model.ExternalMethod) (*model.Instance, error) {
+	instance, err := m.GetInstanceItem(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	ports, err = network.ExternalPorts(method, ports)
+	if err != nil {
+		return nil, err
 	}
 
-	// Определяем тип Flavor на основе instanceType
-	var instanceFlavor model.Flavor
-	flavorCategory, flavorDetails := parseFlavorType(input.InstanceType)
+	unlock := m.quotas.Lock(instance.ProjectID)
+	defer unlock()
 
-	switch flavorCategory {
-	case "base":
-		instanceFlavor = &model.BaseFlavor{
-			OriginalName: input.InstanceType,
-			Vcpus:        flavorDetails.VCPUs,
-			Ram:          flavorDetails.RAM,
-			RubMonth:     flavorDetails.Price,
-		}
-	case "hi-freq":
-		instanceFlavor = &model.HiFreqFlavor{
-			OriginalName: input.InstanceType,
-			Vcpus:        flavorDetails.VCPUs,
-			Ram:          flavorDetails.RAM,
-			RubMonth:     flavorDetails.Price,
-		}
-	case "premium":
-		instanceFlavor = &model.PremiumFlavor{
-			OriginalName: input.InstanceType,
-			Vcpus:        flavorDetails.VCPUs,
-			Ram:          flavorDetails.RAM,
-			RubMonth:     flavorDetails.Price,
-		}
-	case "pro":
-		instanceFlavor = &model.ProFlavor{
-			OriginalName: input.InstanceType,
-			Vcpus:        flavorDetails.VCPUs,
-			Ram:          flavorDetails.RAM,
-			RubMonth:     flavorDetails.Price,
+	// Публичный адрес занимается, только если инстанс еще не открыт наружу
+	if !usage.Exposed(instance) && usage.Exposed(&model.Instance{ExternalMethod: method, ExternalPorts: ports}) {
+		if err := m.checkQuota(ctx, instance.ProjectID, &model.ProjectUsage{PublicIps: 1}); err != nil {
+			return nil, err
 		}
 	}
 
-	// Получаем информацию о созданном диске
-	disk, err := m.diskManager.GetDisk(ctx, diskID)
+	err = m.updateInstance(ctx, instanceID, func(vmObj *unstructured.Unstructured) error {
+		setExternalSpec(vmObj, method, ports)
+		return nil
+	})
 	if err != nil {
-		disk = &model.Disk{
-			DiskID:   diskID,
-			SizeGb:   20,
-			Bootable: true,
-			Status:   "CREATING",
-		}
+		return nil, fmt.Errorf("failed to update VM ports: %v", err)
 	}
 
-	// Создаем базовую модель сети
-	network := &model.Network{
-		NetworkID:        "default-net",
-		NetworkName:      "Default Network",
-		Cidr:             "192.168.0.0/24",
-		GatewayIP:        "192.168.0.1",
-		IsPublic:         true,
-		IPv4:             "",
-		AvailabilityZone: input.Region,
-		Region:           input.Region,
-		SecurityGroupID:  "default-sg",
-	}
-
-	// Создаем модель инстанса
-	instance := &model.Instance{
-		InstanceID:       instanceID,
-		ProjectID:        input.ID,
-		Name:             input.Hostname,
-		Status:           "PROVISIONING",
-		Created:          time.Now().Format(time.RFC3339),
-		Updated:          time.Now().Format(time.RFC3339),
-		KeyName:          "", // Будет заполнено позже
-		Locked:           false,
-		Loading:          true,
-		PowerState:       "STARTING",
-		IPv4:             "", // Будет заполнено позже
-		Flavor:           instanceFlavor,
-		AttachedDisks:    []*model.Disk{disk},
-		AttachedNetworks: []*model.Network{network},
-	}
-
-	// Добавляем в кэш
-	m.cacheMutex.Lock()
-	m.instanceCache[instanceID] = instance
-	m.cacheMutex.Unlock()
-
-	// Запускаем горутину для отслеживания статуса
-	go m.monitorInstanceStatus(ctx, instanceID)
-
-	return instance, nil
+	return m.GetInstanceItem(ctx, instanceID)
 }
 
-// DeleteInstance удаляет виртуальную машину
-func (m *InstanceManager) DeleteInstance(ctx context.Context, instanceID string) (bool, error) {
-	// Находим информацию об инстансе
-	instance, err := m.GetInstanceItem(ctx, instanceID)
+// projectUsage считает ресурсы, занятые проектом, по кэшу информеров
+func (m *InstanceManager) projectUsage(ctx context.Context, projectID string) (*model.ProjectUsage, error) {
+	instances, err := m.GetInstanceList(ctx, projectID)
 	if err != nil {
-		return false, fmt.Errorf("failed to find instance: %v", err)
+		return nil, err
 	}
 
-	// Получаем список дисков для последующего удаления
-	var diskIDs []string
-	for _, disk := range instance.AttachedDisks {
-		diskIDs = append(diskIDs, disk.DiskID)
+	disks, err := m.GetDiskList(ctx, projectID)
+	if err != nil {
+		return nil, err
 	}
 
-	// Удаляем VMInstance через API Kubernetes с использованием retry
-	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		return m.dynamicClient.Resource(VMInstanceGVR).Namespace(m.namespace).Delete(ctx, instanceID, metav1.DeleteOptions{})
-	})
+	return usage.Compute(instances, disks), nil
+}
 
+// checkQuota отклоняет запрос, который выведет проект за его квоты.
+// Вызывающий должен удерживать блокировку проекта из m.quotas.Lock
+func (m *InstanceManager) checkQuota(ctx context.Context, projectID string, requested *model.ProjectUsage) error {
+	used, err := m.projectUsage(ctx, projectID)
 	if err != nil {
-		// Если инстанс не найден, считаем операцию успешной
-		if strings.Contains(err.Error(), "not found") {
-			// Удаляем из кэша
-			m.cacheMutex.Lock()
-			delete(m.instanceCache, instanceID)
-			m.cacheMutex.Unlock()
+		return err
+	}
 
-			// Продолжаем с удалением дисков
-		} else {
-			return false, fmt.Errorf("failed to delete VM: %v", err)
+	return quota.Check(m.quotas.Limits(projectID), used, requested)
+}
+
+// updateDisks изменяет spec.disks VMInstance и обновляет кэш
+func (m *InstanceManager) updateDisks(ctx context.Context, instanceID string, update func([]interface{}) ([]interface{}, error)) (*model.Instance, error) {
+	err := m.updateInstance(ctx, instanceID, func(vmObj *unstructured.Unstructured) error {
+		disks, _, err := unstructured.NestedSlice(vmObj.Object, "spec", "disks")
+		if err != nil {
+			return err
 		}
-	}
 
-	// Удаляем диски
-	for _, diskID := range diskIDs {
-		err := m.diskManager.DeleteDisk(ctx, diskID)
+		disks, err = update(disks)
 		if err != nil {
-			// Логируем ошибку, но продолжаем
-			fmt.Printf("Warning: failed to delete disk %s: %v\n", diskID, err)
+			return err
 		}
+
+		return unstructured.SetNestedSlice(vmObj.Object, disks, "spec", "disks")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update VM disks: %v", err)
 	}
 
-	// Удаляем из кэша
-	m.cacheMutex.Lock()
-	delete(m.instanceCache, instanceID)
-	m.cacheMutex.Unlock()
+	return m.GetInstanceItem(ctx, instanceID)
+}
 
-	// Отправляем уведомление об изменении
-	select {
-	case m.stateChangeChan <- struct{}{}:
-		// Уведомление отправлено
-	default:
-		// Канал переполнен, пропускаем
+// updateInstance изменяет VMInstance и обновляет кэш
+func (m *InstanceManager) updateInstance(ctx context.Context, instanceID string, update func(*unstructured.Unstructured) error) error {
+	namespace, err := m.instanceNamespace(ctx, instanceID)
+	if err != nil {
+		return err
 	}
 
-	return true, nil
-}
+	var updated *unstructured.Unstructured
 
-// GetInstanceList возвращает список виртуальных машин для проекта
-func (m *InstanceManager) GetInstanceList(ctx context.Context, projectID string) ([]*model.Instance, error) {
-	// Обновляем кэш
-	err := m.refreshInstanceCache(ctx)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Получаем актуальную версию ресурса перед каждой попыткой
+		vmObj, err := m.dynamicClient.Resource(VMInstanceGVR).Namespace(namespace).Get(ctx, instanceID, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if err := update(vmObj); err != nil {
+			return err
+		}
+
+		updated, err = m.dynamicClient.Resource(VMInstanceGVR).Namespace(namespace).Update(ctx, vmObj, metav1.UpdateOptions{})
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to refresh instance cache: %v", err)
+		return err
 	}
 
-	// Фильтруем инстансы по projectID
+	// Обновляем кэш сразу, не дожидаясь информера
+	m.onInstanceChanged(updated, model.EventTypeModified)
+
+	return nil
+}
+
+// diskOwner возвращает инстанс, к которому подключен диск
+func (m *InstanceManager) diskOwner(diskID string) *model.Instance {
 	m.cacheMutex.RLock()
 	defer m.cacheMutex.RUnlock()
 
-	var instances []*model.Instance
 	for _, instance := range m.instanceCache {
-		if projectID == "" || instance.ProjectID == projectID {
-			instances = append(instances, instance)
+		for _, disk := range instance.AttachedDisks {
+			if disk.DiskID == diskID {
+				return instance
+			}
 		}
 	}
 
-	return instances, nil
+	return nil
 }
 
-// GetInstanceItem возвращает информацию о конкретной виртуальной машине
-func (m *InstanceManager) GetInstanceItem(ctx context.Context, instanceID string) (*model.Instance, error) {
-	// Сначала проверяем кэш
-	m.cacheMutex.RLock()
-	cachedInstance, exists := m.instanceCache[instanceID]
-	m.cacheMutex.RUnlock()
-
-	if exists {
-		return cachedInstance, nil
+// withInstances возвращает копию диска с заполненным списком инстансов
+func (m *InstanceManager) withInstances(disk *model.Disk) *model.Disk {
+	result := *disk
+	result.Instances = []*model.Instance{}
+	if owner := m.diskOwner(disk.DiskID); owner != nil {
+		result.Instances = append(result.Instances, owner)
 	}
 
-	// Обновляем информацию об инстансе
-	err := m.refreshInstanceItem(ctx, instanceID)
+	return &result
+}
+
+// GetFlavorList возвращает доступные flavor, сгруппированные по категориям
+func (m *InstanceManager) GetFlavorList(ctx context.Context) ([]*model.KVStringListOfFlavor, error) {
+	return m.flavors.FlavorList(), nil
+}
+
+// GetCompatibleFlavors возвращает flavor, которые подходят для образа по vCPU и RAM
+func (m *InstanceManager) GetCompatibleFlavors(ctx context.Context, imageID string, projectID *string) ([]*model.KVStringListOfFlavor, error) {
+	image, err := m.images.Resolve(valueOrEmpty(projectID), imageID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to refresh instance info: %v", err)
+		return nil, err
 	}
 
-	// Проверяем кэш ещё раз
-	m.cacheMutex.RLock()
-	defer m.cacheMutex.RUnlock()
+	return requirements.CompatibleFlavors(image.Image, m.flavors.FlavorList()), nil
+}
 
-	instance, exists := m.instanceCache[instanceID]
-	if !exists {
-		return nil, fmt.Errorf("instance not found: %s", instanceID)
+// GetImageList возвращает каталог образов и готовые образы, импортированные в проект
+func (m *InstanceManager) GetImageList(ctx context.Context, projectID *string) ([]*model.Image, error) {
+	return m.images.ImageList(valueOrEmpty(projectID)), nil
+}
+
+// GetImagesByIDs возвращает образы по ID в том же порядке, nil для неизвестных ID
+func (m *InstanceManager) GetImagesByIDs(ctx context.Context, imageIDs []string) ([]*model.Image, error) {
+	return m.images.Images(imageIDs), nil
+}
+
+// GetImageProject возвращает проект, в который импортирован образ, пустую
+// строку для образов каталога
+func (m *InstanceManager) GetImageProject(ctx context.Context, imageID string) (string, error) {
+	return m.images.Project(imageID), nil
+}
+
+// GetImageImports возвращает образы, импортированные в проект
+func (m *InstanceManager) GetImageImports(ctx context.Context, projectID string) ([]*model.ImageImport, error) {
+	return m.imports.ListImports(projectID), nil
+}
+
+// ImportImage начинает импорт образа по URL
+func (m *InstanceManager) ImportImage(ctx context.Context, projectID, url, label, osVersion string, sizeGB *int32) (*model.ImageImport, error) {
+	size := defaultImportSizeGB
+	if sizeGB != nil {
+		size = int(*sizeGB)
 	}
 
-	return instance, nil
+	return m.imports.ImportURL(ctx, projectID, url, label, osVersion, size)
 }
 
-// GetStateChangeChan возвращает канал для подписки на изменения состояния
-func (m *InstanceManager) GetStateChangeChan() <-chan interface{} {
-	return m.stateChangeChan
+// UploadImage загружает файл образа в проект
+func (m *InstanceManager) UploadImage(ctx context.Context, projectID string, file graphql.Upload, label, osVersion string) (*model.ImageImport, error) {
+	return m.imports.Upload(ctx, projectID, file, label, osVersion)
 }
 
-// refreshInstanceCache обновляет кэш всех инстансов
-func (m *InstanceManager) refreshInstanceCache(ctx context.Context) error {
-	// Получаем список инстансов через API Kubernetes
-	vmList, err := m.dynamicClient.Resource(VMInstanceGVR).Namespace(m.namespace).List(ctx, metav1.ListOptions{})
+// GetSSHKeys возвращает SSH-ключи вместе с инстансами, в которые они были переданы
+func (m *InstanceManager) GetSSHKeys(ctx context.Context, userID, projectID *string) ([]*model.SSHKey, error) {
+	records, err := m.keys.ListKeys(ctx, userID, projectID)
 	if err != nil {
-		return fmt.Errorf("failed to list VMs: %v", err)
+		return nil, err
 	}
 
-	// Создаем новый кэш
-	newCache := make(map[string]*model.Instance)
+	vmObjects := m.store.ListInstances()
 
-	// Обновляем кэш дисков
-	disks, err := m.diskManager.ListDisks(ctx)
-	if err != nil {
-		fmt.Printf("Warning: Failed to refresh disk cache: %v\n", err)
-		disks = []*model.Disk{}
+	m.cacheMutex.RLock()
+	defer m.cacheMutex.RUnlock()
+
+	keys := make([]*model.SSHKey, 0, len(records))
+	for _, record := range records {
+		for _, vmObj := range vmObjects {
+			instance, exists := m.instanceCache[vmObj.GetName()]
+			if exists && keyInjected(vmObj, record) {
+				record.key.Instances = append(record.key.Instances, instance)
+			}
+		}
+		keys = append(keys, record.key)
 	}
 
-	// Создаем маппинг для быстрого доступа к дискам
-	diskMap := make(map[string]*model.Disk)
-	for _, disk := range disks {
-		diskMap[disk.DiskID] = disk
+	return keys, nil
+}
+
+// keyInjected сообщает, был ли ключ передан в VM. Ключ проекта ищется только
+// в VM этого проекта. VM, созданные до появления отпечатков в аннотациях,
+// сопоставляются с ключом по имени
+func keyInjected(vmObj *unstructured.Unstructured, record *keyRecord) bool {
+	if record.projectID != "" && vmObj.GetLabels()["project-id"] != record.projectID {
+		return false
 	}
 
-	// Обрабатываем каждый инстанс
-	for _, vmObj := range vmList.Items {
-		instance, err := m.convertToInstanceModel(ctx, &vmObj, diskMap)
-		if err != nil {
-			// Логируем ошибку и продолжаем
-			fmt.Printf("Warning: Failed to convert VM to model: %v\n", err)
-			continue
+	annotations := vmObj.GetAnnotations()
+
+	if fingerprints, exists := annotations[sshKeyFingerprintsAnnotation]; exists {
+		for _, fingerprint := range strings.Split(fingerprints, ",") {
+			if fingerprint == record.key.Fingerprint {
+				return true
+			}
 		}
+		return false
+	}
 
-		newCache[instance.InstanceID] = instance
+	for _, keyName := range strings.Split(annotations[sshKeysAnnotation], ",") {
+		if keyName == record.key.Name {
+			return true
+		}
 	}
 
-	// Обновляем кэш
-	m.cacheMutex.Lock()
-	m.instanceCache = newCache
-	m.cacheMutex.Unlock()
+	return false
+}
 
-	return nil
+// AddSSHKey сохраняет SSH-ключ
+func (m *InstanceManager) AddSSHKey(ctx context.Context, input model.NewSSHKeyInput) (*model.SSHKey, error) {
+	return m.keys.AddKey(ctx, input)
 }
 
-// refreshInstanceItem обновляет информацию о конкретном инстансе
-func (m *InstanceManager) refreshInstanceItem(ctx context.Context, instanceID string) error {
-	// Получаем инстанс через API Kubernetes
-	vmObj, err := m.dynamicClient.Resource(VMInstanceGVR).Namespace(m.namespace).Get(ctx, instanceID, metav1.GetOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to get VM: %v", err)
+// DeleteSSHKey удаляет SSH-ключ. Ключ остается в уже созданных VM
+func (m *InstanceManager) DeleteSSHKey(ctx context.Context, name string, userID, projectID *string) (bool, error) {
+	if err := m.keys.DeleteKey(ctx, name, userID, projectID); err != nil {
+		return false, err
 	}
 
-	// Получаем список дисков инстанса
-	spec, found, err := unstructured.NestedMap(vmObj.Object, "spec")
-	if err != nil || !found {
-		return fmt.Errorf("spec not found in VM object")
+	return true, nil
+}
+
+// GetNetworkList возвращает сети проекта
+func (m *InstanceManager) GetNetworkList(ctx context.Context, projectID *string) ([]*model.Network, error) {
+	return m.networks.ListNetworks(projectID), nil
+}
+
+// GetNetworksByIDs возвращает сети по ID в том же порядке, nil для неизвестных ID
+func (m *InstanceManager) GetNetworksByIDs(ctx context.Context, networkIDs []string) ([]*model.Network, error) {
+	networks := make([]*model.Network, len(networkIDs))
+	for i, networkID := range networkIDs {
+		if found, err := m.networks.GetNetwork(networkID); err == nil {
+			networks[i] = found
+		}
 	}
 
-	disksData, found, err := unstructured.NestedSlice(spec, "disks")
-	if err != nil || !found {
-		disksData = []interface{}{}
+	return networks, nil
+}
+
+// CreateNetwork создает приватную сеть проекта
+func (m *InstanceManager) CreateNetwork(ctx context.Context, input model.NewNetworkInput) (*model.Network, error) {
+	return m.networks.CreateNetwork(ctx, input)
+}
+
+// UpdateNetwork меняет имя сети и ее группу безопасности
+func (m *InstanceManager) UpdateNetwork(ctx context.Context, networkID string, networkName, securityGroupID *string) (*model.Network, error) {
+	return m.networks.UpdateNetwork(ctx, networkID, networkName, securityGroupID)
+}
+
+// DeleteNetwork удаляет сеть, если к ней не подключен ни один инстанс
+func (m *InstanceManager) DeleteNetwork(ctx context.Context, networkID string) (bool, error) {
+	if _, err := m.networks.GetNetwork(networkID); err != nil {
+		return false, err
 	}
 
-	// Создаем маппинг дисков
-	diskMap := make(map[string]*model.Disk)
+	if instance := m.networkOwner(networkID); instance != nil {
+		return false, fmt.Errorf("network %s is attached to instance %s", networkID, instance.InstanceID)
+	}
 
-	for _, diskData := range disksData {
-		diskMap, ok := diskData.(map[string]interface{})
-		if !ok {
-			continue
-		}
+	if err := m.networks.DeleteNetwork(ctx, networkID); err != nil {
+		return false, err
+	}
 
-		diskName, ok := diskMap["name"].(string)
-		if !ok {
-			continue
-		}
+	return true, nil
+}
 
-		// Получаем информацию о диске
-		disk, err := m.diskManager.GetDisk(ctx, diskName)
-		if err == nil {
-			diskMap[diskName] = disk
+// GetSecurityGroup возвращает группу безопасности по ID
+func (m *InstanceManager) GetSecurityGroup(ctx context.Context, securityGroupID string) (*model.SecurityGroup, error) {
+	return m.networks.GetSecurityGroup(ctx, securityGroupID)
+}
+
+// GetSecurityGroups возвращает группы безопасности проекта
+func (m *InstanceManager) GetSecurityGroups(ctx context.Context, projectID string) ([]*model.SecurityGroup, error) {
+	return m.networks.ListSecurityGroups(ctx, projectID)
+}
+
+// CreateSecurityGroup создает группу безопасности проекта
+func (m *InstanceManager) CreateSecurityGroup(ctx context.Context, input model.NewSecurityGroupInput) (*model.SecurityGroup, error) {
+	return m.networks.CreateSecurityGroup(ctx, input)
+}
+
+// UpdateSecurityGroup меняет имя группы безопасности и заменяет ее правила
+func (m *InstanceManager) UpdateSecurityGroup(ctx context.Context, securityGroupID string, name *string, rules []*model.SecurityGroupRuleInput) (*model.SecurityGroup, error) {
+	return m.networks.UpdateSecurityGroup(ctx, securityGroupID, name, rules)
+}
+
+// DeleteSecurityGroup удаляет группу безопасности, если ее не использует ни одна сеть
+func (m *InstanceManager) DeleteSecurityGroup(ctx context.Context, securityGroupID string) (bool, error) {
+	if err := m.networks.DeleteSecurityGroup(ctx, securityGroupID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetRoleBindings возвращает привязки ролей проекта
+func (m *InstanceManager) GetRoleBindings(ctx context.Context, projectID string) ([]*model.RoleBinding, error) {
+	return m.roles.ListBindings(projectID)
+}
+
+// SetRoleBinding назначает пользователю роль в проекте
+func (m *InstanceManager) SetRoleBinding(ctx context.Context, projectID, userID string, role model.Role) (*model.RoleBinding, error) {
+	return m.roles.SetBinding(ctx, projectID, userID, role)
+}
+
+// DeleteRoleBinding отзывает роль пользователя в проекте
+func (m *InstanceManager) DeleteRoleBinding(ctx context.Context, projectID, userID string) (bool, error) {
+	if err := m.roles.DeleteBinding(ctx, projectID, userID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// networkOwner возвращает инстанс, подключенный к сети
+func (m *InstanceManager) networkOwner(networkID string) *model.Instance {
+	m.cacheMutex.RLock()
+	defer m.cacheMutex.RUnlock()
+
+	for _, instance := range m.instanceCache {
+		for _, attached := range instance.AttachedNetworks {
+			if attached.NetworkID == networkID {
+				return instance
+			}
 		}
 	}
 
-	// Преобразуем в модель инстанса
-	instance, err := m.convertToInstanceModel(ctx, vmObj, diskMap)
+	return nil
+}
+
+// onInstanceChanged обновляет инстанс в кэше по событию информера и сообщает
+// подписчикам об изменившихся полях
+func (m *InstanceManager) onInstanceChanged(obj interface{}, eventType model.EventType) {
+	vmObj, ok := objectFromEvent(obj)
+	if !ok {
+		return
+	}
+
+	instance, err := m.convertToInstanceModel(vmObj)
 	if err != nil {
-		return fmt.Errorf("failed to convert VM to model: %v", err)
+		// Логируем ошибку и продолжаем
+		fmt.Printf("Warning: Failed to convert VM to model: %v\n", err)
+		return
 	}
 
-	// Обновляем кэш
 	m.cacheMutex.Lock()
-	m.instanceCache[instanceID] = instance
+	previous, existed := m.instanceCache[instance.InstanceID]
+	m.instanceCache[instance.InstanceID] = instance
 	m.cacheMutex.Unlock()
 
-	return nil
+	var changedFields []string
+	if eventType == model.EventTypeModified {
+		if !existed {
+			eventType = model.EventTypeAdded
+		} else {
+			changedFields = events.InstanceChangedFields(previous, instance)
+			if len(changedFields) == 0 {
+				// Пересинхронизация информера без фактических изменений
+				return
+			}
+		}
+	}
+
+	// Отправляем уведомление об изменении
+	m.events.Publish(events.NewInstanceEvent(eventType, instance, changedFields))
 }
 
-// monitorInstanceStatus отслеживает изменения статуса инстанса
-func (m *InstanceManager) monitorInstanceStatus(ctx context.Context, instanceID string) {
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
+// onInstanceDeleted удаляет инстанс из кэша по событию информера
+func (m *InstanceManager) onInstanceDeleted(obj interface{}) {
+	vmObj, ok := objectFromEvent(obj)
+	if !ok {
+		return
+	}
 
-	timeout := time.After(15 * time.Minute)
+	m.cacheMutex.Lock()
+	delete(m.instanceCache, vmObj.GetName())
+	m.cacheMutex.Unlock()
 
-	for {
-		select {
-		case <-timeout:
-			// Превышен таймаут ожидания
-			return
-		case <-ticker.C:
-			// Обновляем информацию об инстансе
-			err := m.refreshInstanceItem(ctx, instanceID)
-			if err != nil {
-				// Возможно инстанс удален, прекращаем мониторинг
-				return
-			}
+	// Отправляем уведомление с последним известным состоянием инстанса
+	instance, err := m.convertToInstanceModel(vmObj)
+	if err != nil {
+		return
+	}
 
-			// Проверяем статус инстанса
-			m.cacheMutex.RLock()
-			instance, exists := m.instanceCache[instanceID]
-			m.cacheMutex.RUnlock()
+	m.events.Publish(events.NewInstanceEvent(model.EventTypeDeleted, instance, nil))
+}
 
-			if !exists {
-				// Инстанс удален, прекращаем мониторинг
-				return
-			}
+// onDiskChanged пересобирает инстансы, к которым подключен измененный диск
+func (m *InstanceManager) onDiskChanged(obj interface{}) {
+	diskObj, ok := objectFromEvent(obj)
+	if !ok {
+		return
+	}
 
-			// Если инстанс больше не в состоянии PROVISIONING или STARTING,
-			// отправляем уведомление и завершаем мониторинг
-			if instance.Status != "PROVISIONING" && instance.PowerState != "STARTING" {
-				select {
-				case m.stateChangeChan <- instance:
-					// Уведомление отправлено
-				default:
-					// Канал переполнен, пропускаем
-				}
-				return
+	diskID := diskObj.GetName()
+
+	m.cacheMutex.RLock()
+	var affected []string
+	for instanceID, instance := range m.instanceCache {
+		for _, disk := range instance.AttachedDisks {
+			if disk.DiskID == diskID {
+				affected = append(affected, instanceID)
+				break
 			}
 		}
 	}
+	m.cacheMutex.RUnlock()
+
+	for _, instanceID := range affected {
+		if vmObj, exists := m.store.GetInstance(instanceID); exists {
+			m.onInstanceChanged(vmObj, model.EventTypeModified)
+		}
+	}
 }
 
-// watchInstances отслеживает изменения в инстансах
-func (m *InstanceManager) watchInstances() {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+// onVMIChanged пересобирает инстанс, у VirtualMachineInstance которого
+// изменились адреса в сетях
+func (m *InstanceManager) onVMIChanged(obj interface{}) {
+	vmiObj, ok := objectFromEvent(obj)
+	if !ok {
+		return
+	}
 
-	for {
-		select {
-		case <-ticker.C:
-			ctx := context.Background()
-			err := m.refreshInstanceCache(ctx)
-			if err != nil {
-				fmt.Printf("Failed to refresh instance cache: %v\n", err)
-				continue
-			}
+	// Одноименная VM другого тенанта не относится к инстансу
+	instanceID := strings.TrimPrefix(vmiObj.GetName(), kubevirtVMPrefix)
+	if vmObj, exists := m.store.GetInstance(instanceID); exists && vmObj.GetNamespace() == vmiObj.GetNamespace() {
+		m.onInstanceChanged(vmObj, model.EventTypeModified)
+	}
+}
 
-			// Отправляем уведомление об изменениях
-			select {
-			case m.stateChangeChan <- struct{}{}:
-				// Уведомление отправлено
-			default:
-				// Канал переполнен, пропускаем
-			}
+// lookupDisk возвращает модель диска по имени, не обращаясь к API. VM видит
+// только диски своего namespace, поэтому диски других тенантов не находятся
+func (m *InstanceManager) lookupDisk(namespace, diskName string) (*model.Disk, bool) {
+	// Хранилище информера содержит самую свежую версию диска
+	if diskObj, exists := m.store.GetDisk(diskName); exists {
+		if diskObj.GetNamespace() != namespace {
+			return nil, false
 		}
+		disk, err := m.diskManager.convertToDiskModel(diskObj)
+		if err == nil {
+			return disk, true
+		}
+	}
+
+	// Только что созданный диск может быть пока известен лишь кэшу
+	disk, err := m.diskManager.GetDisk(context.Background(), diskName)
+	if err != nil {
+		return nil, false
+	}
+	if diskNamespace, err := m.tenants.Namespace(disk.ProjectID); err != nil || diskNamespace != namespace {
+		return nil, false
 	}
+
+	return disk, true
 }
 
 // convertToInstanceModel преобразует Kubernetes ресурс в модель инстанса
-func (m *InstanceManager) convertToInstanceModel(ctx context.Context, vmObj *unstructured.Unstructured, diskMap map[string]*model.Disk) (*model.Instance, error) {
+func (m *InstanceManager) convertToInstanceModel(vmObj *unstructured.Unstructured) (*model.Instance, error) {
 	metadata := vmObj.Object["metadata"].(map[string]interface{})
 	spec, found, err := unstructured.NestedMap(vmObj.Object, "spec")
 	if err != nil || !found {
@@ -557,52 +1566,14 @@ func (m *InstanceManager) convertToInstanceModel(ctx context.Context, vmObj *uns
 		hostname = fmt.Sprintf("%v", name)
 	}
 
-	// Извлекаем регион
-	region := "default-region"
-	if reg, exists := labels["region"]; exists {
-		region = fmt.Sprintf("%v", reg)
-	}
-
 	// Извлекаем тип инстанса
 	instanceType, found, _ := unstructured.NestedString(spec, "instanceType")
 	if !found {
 		instanceType = "u1.small" // Значение по умолчанию
 	}
 
-	// Определяем flavor на основе типа инстанса
-	var instanceFlavor model.Flavor
-	flavorCategory, flavorDetails := parseFlavorType(instanceType)
-
-	switch flavorCategory {
-	case "base":
-		instanceFlavor = &model.BaseFlavor{
-			OriginalName: instanceType,
-			Vcpus:        flavorDetails.VCPUs,
-			Ram:          flavorDetails.RAM,
-			RubMonth:     flavorDetails.Price,
-		}
-	case "hi-freq":
-		instanceFlavor = &model.HiFreqFlavor{
-			OriginalName: instanceType,
-			Vcpus:        flavorDetails.VCPUs,
-			Ram:          flavorDetails.RAM,
-			RubMonth:     flavorDetails.Price,
-		}
-	case "premium":
-		instanceFlavor = &model.PremiumFlavor{
-			OriginalName: instanceType,
-			Vcpus:        flavorDetails.VCPUs,
-			Ram:          flavorDetails.RAM,
-			RubMonth:     flavorDetails.Price,
-		}
-	case "pro":
-		instanceFlavor = &model.ProFlavor{
-			OriginalName: instanceType,
-			Vcpus:        flavorDetails.VCPUs,
-			Ram:          flavorDetails.RAM,
-			RubMonth:     flavorDetails.Price,
-		}
-	}
+	// Flavor, которого уже нет в каталоге, показывается без цены
+	instanceFlavor, _ := m.flavors.Flavor(instanceType)
 
 	// Извлекаем статус VM
 	vmStatus := "UNKNOWN"
@@ -620,13 +1591,13 @@ func (m *InstanceManager) convertToInstanceModel(ctx context.Context, vmObj *uns
 		"Unknown":   "UNKNOWN",
 	}
 
-	powerStateMap := map[string]string{
-		"Creating":  "STARTING",
-		"Pending":   "STARTING",
-		"Running":   "ACTIVE",
-		"Succeeded": "ACTIVE",
-		"Failed":    "STOPPED",
-		"Unknown":   "UNKNOWN",
+	powerStateMap := map[string]model.PowerState{
+		"Creating":  model.PowerStateStarting,
+		"Pending":   model.PowerStateStarting,
+		"Running":   model.PowerStateActive,
+		"Succeeded": model.PowerStateActive,
+		"Failed":    model.PowerStateStopped,
+		"Unknown":   model.PowerStateUnknown,
 	}
 
 	apiStatus, ok := statusMap[vmStatus]
@@ -636,18 +1607,36 @@ func (m *InstanceManager) convertToInstanceModel(ctx context.Context, vmObj *uns
 
 	powerState, ok := powerStateMap[vmStatus]
 	if !ok {
-		powerState = "UNKNOWN"
+		powerState = model.PowerStateUnknown
 	}
 
-	// Извлекаем IP-адрес
-	ipv4 := ""
+	// Выключенная через spec.running машина находится в STOPPED,
+	// пока KubeVirt ее останавливает - в STOPPING
+	running, found, _ := unstructured.NestedBool(spec, "running")
+	if found && !running && apiStatus != "ERROR" {
+		if vmStatus == "Running" {
+			powerState = model.PowerStateStopping
+		} else {
+			apiStatus = "STOPPED"
+			powerState = model.PowerStateStopped
+		}
+	}
+
+	// Извлекаем внешние адреса, первый из них считается адресом инстанса
+	externalAddresses := []string{}
 	externalAccess, found, _ := unstructured.NestedMap(status, "externalAccess")
 	if found {
-		addresses, found, _ := unstructured.NestedSlice(externalAccess, "externalAddresses")
-		if found && len(addresses) > 0 {
-			ipv4 = fmt.Sprintf("%v", addresses[0])
+		addresses, _, _ := unstructured.NestedSlice(externalAccess, "externalAddresses")
+		for _, address := range addresses {
+			externalAddresses = append(externalAddresses, fmt.Sprintf("%v", address))
 		}
 	}
+	ipv4 := ""
+	if len(externalAddresses) > 0 {
+		ipv4 = externalAddresses[0]
+	}
+
+	externalMethod, externalPorts := externalSpec(spec)
 
 	// Извлекаем время создания и обновления
 	creationTime := metadata["creationTimestamp"].(string)
@@ -656,69 +1645,105 @@ func (m *InstanceManager) convertToInstanceModel(ctx context.Context, vmObj *uns
 	var attachedDisks []*model.Disk
 	disksData, found, _ := unstructured.NestedSlice(spec, "disks")
 	if found {
-		for _, diskData := range disksData {
-			diskMap, ok := diskData.(map[string]interface{})
+		for i, diskData := range disksData {
+			diskRef, ok := diskData.(map[string]interface{})
 			if !ok {
 				continue
 			}
 
-			diskName, ok := diskMap["name"].(string)
+			diskName, ok := diskRef["name"].(string)
 			if !ok {
 				continue
 			}
 
-			// Ищем диск в кэше
-			disk, exists := diskMap[diskName]
+			// Ищем диск в памяти
+			disk, exists := m.lookupDisk(vmObj.GetNamespace(), diskName)
 			if exists {
 				attachedDisks = append(attachedDisks, disk)
 			} else {
-				// Если диск не найден в кэше, запрашиваем его через API
-				disk, err := m.diskManager.GetDisk(ctx, diskName)
-				if err == nil {
-					attachedDisks = append(attachedDisks, disk)
-				} else {
-					// Если не удалось получить информацию о диске, создаем заглушку
-					attachedDisks = append(attachedDisks, &model.Disk{
-						DiskID:   diskName,
-						SizeGb:   20, // Значение по умолчанию
-						Bootable: true,
-						Status:   "UNKNOWN",
-					})
-				}
+				// Если не удалось получить информацию о диске, создаем заглушку.
+				// Размер диска неизвестен и не выдумывается, чтобы не искажать
+				// потребление и стоимость
+				attachedDisks = append(attachedDisks, &model.Disk{
+					DiskID:    diskName,
+					ProjectID: projectID,
+					Bootable:  i == 0,
+					Status:    "UNKNOWN",
+				})
 			}
 		}
 	}
 
-	// Создаем модель сети
-	network := &model.Network{
-		NetworkID:        "default-net",
-		NetworkName:      "Default Network",
-		Cidr:             "192.168.0.0/24",
-		GatewayIP:        "192.168.0.1",
-		IsPublic:         true,
-		IPv4:             ipv4,
-		AvailabilityZone: region,
-		Region:           region,
-		SecurityGroupID:  "default-sg",
-	}
-
 	// Создаем модель инстанса
 	instance := &model.Instance{
-		InstanceID:       instanceID,
-		ProjectID:        projectID,
-		Name:             hostname,
-		Status:           apiStatus,
-		Created:          creationTime,
-		Updated:          time.Now().Format(time.RFC3339),
-		KeyName:          "", // Не используется в CozyStack
-		Locked:           false,
-		Loading:          apiStatus == "PROVISIONING" || powerState == "STARTING",
-		PowerState:       powerState,
-		IPv4:             ipv4,
-		Flavor:           instanceFlavor,
-		AttachedDisks:    attachedDisks,
-		AttachedNetworks: []*model.Network{network},
+		InstanceID:        instanceID,
+		ProjectID:         projectID,
+		Name:              hostname,
+		Status:            apiStatus,
+		Created:           creationTime,
+		Updated:           time.Now().Format(time.RFC3339),
+		KeyName:           vmObj.GetAnnotations()[sshKeysAnnotation],
+		Locked:            false,
+		Loading:           apiStatus == "PROVISIONING" || powerState == model.PowerStateStarting,
+		PowerState:        powerState,
+		IPV4:              ipv4,
+		Flavor:            instanceFlavor,
+		AttachedDisks:     attachedDisks,
+		AttachedNetworks:  m.networks.InstanceNetworks(vmObj),
+		ExternalMethod:    externalMethod,
+		ExternalPorts:     externalPorts,
+		ExternalAddresses: externalAddresses,
 	}
+	instance.Cost = pricing.Instance(m.flavors.Prices(), instance)
 
 	return instance, nil
 }
+
+// externalMethods сопоставляет способы публикации GraphQL и значения externalMethod VMInstance
+var externalMethods = map[model.ExternalMethod]string{
+	model.ExternalMethodPortList: "PortList",
+	model.ExternalMethodWholeIP:  "WholeIP",
+}
+
+// setExternalSpec записывает способ публикации в spec VMInstance. Пустой
+// список портов в режиме PORT_LIST снимает внешний адрес
+func setExternalSpec(vmObj *unstructured.Unstructured, method model.ExternalMethod, ports []int32) {
+	externalPorts := make([]interface{}, 0, len(ports))
+	for _, port := range ports {
+		externalPorts = append(externalPorts, int64(port))
+	}
+
+	spec, _, _ := unstructured.NestedMap(vmObj.Object, "spec")
+	if spec == nil {
+		spec = make(map[string]interface{})
+	}
+	spec["external"] = method == model.ExternalMethodWholeIP || len(ports) > 0
+	spec["externalMethod"] = externalMethods[method]
+	spec["externalPorts"] = externalPorts
+	vmObj.Object["spec"] = spec
+}
+
+// externalSpec читает способ публикации и порты из spec VMInstance. Инстанс
+// без внешнего адреса считается публикующим пустой список портов
+func externalSpec(spec map[string]interface{}) (model.ExternalMethod, []int32) {
+	ports := []int32{}
+	if external, _ := spec["external"].(bool); !external {
+		return model.ExternalMethodPortList, ports
+	}
+
+	if method, _ := spec["externalMethod"].(string); method == externalMethods[model.ExternalMethodWholeIP] {
+		return model.ExternalMethodWholeIP, ports
+	}
+
+	externalPorts, _, _ := unstructured.NestedSlice(spec, "externalPorts")
+	for _, port := range externalPorts {
+		switch value := port.(type) {
+		case int64:
+			ports = append(ports, int32(value))
+		case float64:
+			ports = append(ports, int32(value))
+		}
+	}
+
+	return model.ExternalMethodPortList, ports
+}
@@ -0,0 +1,221 @@
+package cozystack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gqlfed/instances/auth"
+	"gqlfed/instances/graph/model"
+	"gqlfed/instances/sshkey"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// sshKeysAnnotation хранит через запятую имена SSH-ключей, переданных в VM
+	sshKeysAnnotation = "ssh-keys"
+	// sshKeyFingerprintsAnnotation хранит через запятую отпечатки этих ключей.
+	// Имена ключей разных владельцев могут совпадать, отпечаток однозначно
+	// определяет переданный ключ
+	sshKeyFingerprintsAnnotation = "ssh-key-fingerprints"
+)
+
+// KeyManager хранит SSH-ключи пользователей в Secret. Каждый ключ - отдельный
+// Secret с метками владельца, поэтому ключи с одинаковым именем могут
+// принадлежать разным пользователям и проектам
+type KeyManager struct {
+	namespace string
+	k8sClient kubernetes.Interface
+}
+
+// keyRecord описывает сохраненный SSH-ключ и его владельца
+type keyRecord struct {
+	userID    string
+	projectID string
+	key       *model.SSHKey
+}
+
+// newKeyManager создает менеджер SSH-ключей
+func newKeyManager(k8sClient kubernetes.Interface, namespace string) *KeyManager {
+	return &KeyManager{
+		namespace: namespace,
+		k8sClient: k8sClient,
+	}
+}
+
+// ListKeys возвращает ключи пользователя и проекта; пустой фильтр означает любого владельца
+func (m *KeyManager) ListKeys(ctx context.Context, userID, projectID *string) ([]*keyRecord, error) {
+	selector := labels.Set{
+		"app":      "cozystack-vm",
+		"resource": "ssh-key",
+	}
+	if userID != nil {
+		selector["user-id"] = *userID
+	}
+	if projectID != nil {
+		selector["project-id"] = *projectID
+	}
+
+	secrets, err := m.k8sClient.CoreV1().Secrets(m.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ssh keys: %v", err)
+	}
+
+	records := make([]*keyRecord, 0, len(secrets.Items))
+	for i := range secrets.Items {
+		records = append(records, convertToKeyRecord(&secrets.Items[i]))
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].key.Name < records[j].key.Name
+	})
+
+	return records, nil
+}
+
+// AddKey проверяет и сохраняет SSH-ключ
+func (m *KeyManager) AddKey(ctx context.Context, input model.NewSSHKeyInput) (*model.SSHKey, error) {
+	if input.Name == "" || strings.Contains(input.Name, ",") {
+		return nil, fmt.Errorf("invalid ssh key name %q", input.Name)
+	}
+
+	parsed, err := sshkey.Parse(input.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, projectID := valueOrEmpty(input.UserID), valueOrEmpty(input.ProjectID)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      keySecretName(userID, projectID, input.Name),
+			Namespace: m.namespace,
+			Labels: map[string]string{
+				"app":        "cozystack-vm",
+				"created-by": "graphql-api",
+				"resource":   "ssh-key",
+				"user-id":    userID,
+				"project-id": projectID,
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			"name":        input.Name,
+			"publicKey":   parsed.PublicKey,
+			"fingerprint": parsed.Fingerprint,
+		},
+	}
+
+	_, err = m.k8sClient.CoreV1().Secrets(m.namespace).Create(ctx, secret, metav1.CreateOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			return nil, fmt.Errorf("ssh key %s already exists", input.Name)
+		}
+		return nil, fmt.Errorf("failed to save ssh key: %v", err)
+	}
+
+	return &model.SSHKey{
+		Name:        input.Name,
+		PublicKey:   parsed.PublicKey,
+		Fingerprint: parsed.Fingerprint,
+		Instances:   []*model.Instance{},
+	}, nil
+}
+
+// DeleteKey удаляет SSH-ключ
+func (m *KeyManager) DeleteKey(ctx context.Context, name string, userID, projectID *string) error {
+	secretName := keySecretName(valueOrEmpty(userID), valueOrEmpty(projectID), name)
+
+	err := m.k8sClient.CoreV1().Secrets(m.namespace).Delete(ctx, secretName, metav1.DeleteOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return fmt.Errorf("ssh key not found: %s", name)
+		}
+		return fmt.Errorf("failed to delete ssh key: %v", err)
+	}
+
+	return nil
+}
+
+// ResolveKeys возвращает ключи по именам. Ищутся ключи проекта, а затем
+// личные ключи вызывающего без проекта; без claims подходит личный ключ
+// любого пользователя. Неизвестное или неоднозначное имя считается ошибкой
+func (m *KeyManager) ResolveKeys(ctx context.Context, projectID string, names []string) ([]*model.SSHKey, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	records, err := m.ListKeys(ctx, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := auth.UserIDFromContext(ctx)
+	projectKeys := make(map[string][]*model.SSHKey)
+	personalKeys := make(map[string][]*model.SSHKey)
+	for _, record := range records {
+		name := record.key.Name
+		if record.projectID == projectID {
+			projectKeys[name] = append(projectKeys[name], record.key)
+		} else if record.projectID == "" && (userID == nil || record.userID == *userID) {
+			personalKeys[name] = append(personalKeys[name], record.key)
+		}
+	}
+
+	keys := make([]*model.SSHKey, 0, len(names))
+	for _, name := range names {
+		// Ключ проекта имеет приоритет над личным ключом
+		candidates := projectKeys[name]
+		if len(candidates) == 0 {
+			candidates = personalKeys[name]
+		}
+		switch len(candidates) {
+		case 0:
+			return nil, fmt.Errorf("ssh key not found: %s", name)
+		case 1:
+			keys = append(keys, candidates[0])
+		default:
+			return nil, fmt.Errorf("ssh key name %s is ambiguous", name)
+		}
+	}
+
+	return keys, nil
+}
+
+// convertToKeyRecord преобразует Secret в модель SSH-ключа
+func convertToKeyRecord(secret *corev1.Secret) *keyRecord {
+	return &keyRecord{
+		userID:    secret.Labels["user-id"],
+		projectID: secret.Labels["project-id"],
+		key: &model.SSHKey{
+			Name:        string(secret.Data["name"]),
+			PublicKey:   string(secret.Data["publicKey"]),
+			Fingerprint: string(secret.Data["fingerprint"]),
+			Instances:   []*model.Instance{},
+		},
+	}
+}
+
+// keySecretName возвращает имя Secret для ключа. Имя ключа может содержать
+// символы, недопустимые в именах Kubernetes, поэтому используется хэш
+func keySecretName(userID, projectID, name string) string {
+	sum := sha256.Sum256([]byte(userID + "/" + projectID + "/" + name))
+	return "ssh-key-" + hex.EncodeToString(sum[:])[:16]
+}
+
+// valueOrEmpty возвращает значение необязательного аргумента
+func valueOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,986 @@
+package cozystack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"gqlfed/instances/graph/model"
+	"gqlfed/instances/network"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// networkSelector отбирает сети, созданные API
+	networkSelector = "created-by=graphql-api,resource=network"
+
+	// networkNameAnnotation хранит отображаемое имя сети
+	networkNameAnnotation = "network-name"
+	// networkCIDRAnnotation и networkGatewayAnnotation повторяют параметры
+	// подсети kube-ovn, чтобы модель сети строилась по одному объекту
+	networkCIDRAnnotation    = "network-cidr"
+	networkGatewayAnnotation = "network-gateway"
+
+	// securityGroupLabel связывает сеть с группой безопасности
+	securityGroupLabel = "security-group-id"
+	// securityGroupNameAnnotation хранит отображаемое имя группы безопасности
+	securityGroupNameAnnotation = "security-group-name"
+
+	// kubevirtVMLabel - метка, которой KubeVirt помечает pod виртуальной машины
+	kubevirtVMLabel = "vm.kubevirt.io/name"
+
+	// kubeOVNSocket - сокет демона kube-ovn, через который CNI подключает интерфейсы
+	kubeOVNSocket = "/run/openvswitch/kube-ovn-daemon.sock"
+)
+
+// NetworkManager управляет приватными сетями и группами безопасности проектов.
+// Сеть - это подсеть kube-ovn и NetworkAttachmentDefinition с тем же именем,
+// которое указывается в spec.subnets VMInstance. Группа безопасности - это
+// NetworkPolicy, которая применяется к VM, подключенным к сетям этой группы
+type NetworkManager struct {
+	tenants       *TenantMap
+	k8sClient     kubernetes.Interface
+	dynamicClient dynamic.Interface
+	store         *ResourceStore
+	networks      cache.SharedIndexInformer
+	// vmis содержит VirtualMachineInstance, из которых берутся адреса VM в сетях
+	vmis cache.SharedIndexInformer
+	// syncMutex не дает параллельно обновлять состав групп безопасности
+	syncMutex sync.Mutex
+	stopCh    chan struct{}
+}
+
+// newNetworkManager создает менеджер сетей для namespace тенантов
+func newNetworkManager(k8sClient kubernetes.Interface, dynamicClient dynamic.Interface, tenants *TenantMap, store *ResourceStore) *NetworkManager {
+	networkFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, defaultResyncPeriod, tenants.WatchNamespace(),
+		func(options *metav1.ListOptions) {
+			options.LabelSelector = networkSelector
+		})
+	vmiFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, defaultResyncPeriod, tenants.WatchNamespace(), nil)
+
+	manager := &NetworkManager{
+		tenants:       tenants,
+		k8sClient:     k8sClient,
+		dynamicClient: dynamicClient,
+		store:         store,
+		networks:      networkFactory.ForResource(NetworkAttachmentDefinitionGVR).Informer(),
+		vmis:          vmiFactory.ForResource(KubeVirtVMIGVR).Informer(),
+		stopCh:        make(chan struct{}),
+	}
+	addNameIndex(manager.networks)
+
+	return manager
+}
+
+// AddVMIHandler подписывает обработчик на изменения VirtualMachineInstance
+func (m *NetworkManager) AddVMIHandler(handler cache.ResourceEventHandler) error {
+	_, err := m.vmis.AddEventHandler(handler)
+	return err
+}
+
+// Start загружает сети и адреса VM и начинает обновлять состав групп
+// безопасности при создании и удалении VM и при смене группы сети
+func (m *NetworkManager) Start() error {
+	_, err := m.networks.AddEventHandler(m.tenants.ownedEvents(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: m.onNetworkChanged,
+		DeleteFunc: m.onNetworkDeleted,
+	}))
+	if err != nil {
+		return fmt.Errorf("error registering network handler: %v", err)
+	}
+
+	go m.networks.Run(m.stopCh)
+	go m.vmis.Run(m.stopCh)
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSyncTimeout)
+	defer cancel()
+
+	if !cache.WaitForCacheSync(ctx.Done(), m.networks.HasSynced, m.vmis.HasSynced) {
+		m.Stop()
+		return fmt.Errorf("timed out waiting for networks to sync")
+	}
+
+	// VM из первичной загрузки учитываются полной синхронизацией после запуска хранилища
+	err = m.store.AddInstanceHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if !isInInitialList {
+				go m.syncInstanceGroups(obj)
+			}
+		},
+		DeleteFunc: func(obj interface{}) { go m.syncInstanceGroups(obj) },
+	})
+	if err != nil {
+		return fmt.Errorf("error registering security group handler: %v", err)
+	}
+
+	return nil
+}
+
+// Stop останавливает отслеживание сетей
+func (m *NetworkManager) Stop() {
+	close(m.stopCh)
+}
+
+// ListNetworks возвращает сети проекта; nil означает сети всех проектов
+func (m *NetworkManager) ListNetworks(projectID *string) []*model.Network {
+	networks := []*model.Network{}
+	for _, networkObj := range m.tenants.listOwned(m.networks) {
+		networkModel := convertToNetworkModel(networkObj)
+		if projectID != nil && networkModel.ProjectID != *projectID {
+			continue
+		}
+		networks = append(networks, networkModel)
+	}
+
+	sort.Slice(networks, func(i, j int) bool {
+		if networks[i].NetworkName != networks[j].NetworkName {
+			return networks[i].NetworkName < networks[j].NetworkName
+		}
+		return networks[i].NetworkID < networks[j].NetworkID
+	})
+
+	return networks
+}
+
+// GetNetwork возвращает сеть из памяти
+func (m *NetworkManager) GetNetwork(networkID string) (*model.Network, error) {
+	networkObj, exists := m.getNetworkObject(networkID)
+	if !exists {
+		return nil, fmt.Errorf("network not found: %s", networkID)
+	}
+
+	return convertToNetworkModel(networkObj), nil
+}
+
+// CreateNetwork создает подсеть kube-ovn и NetworkAttachmentDefinition для нее
+func (m *NetworkManager) CreateNetwork(ctx context.Context, input model.NewNetworkInput) (*model.Network, error) {
+	name := strings.TrimSpace(input.NetworkName)
+	if name == "" {
+		return nil, fmt.Errorf("network name must not be empty")
+	}
+	if input.Region == "" {
+		return nil, fmt.Errorf("network region must not be empty")
+	}
+
+	subnet, err := network.ParseSubnet(input.Cidr, input.GatewayIP)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, err := m.tenants.Namespace(input.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Подсети kube-ovn находятся в одном VPC, поэтому не должны пересекаться
+	for _, existing := range m.ListNetworks(nil) {
+		if !network.Overlaps(existing.Cidr, subnet.CIDR) {
+			continue
+		}
+		// Сети других проектов не называются
+		if existing.ProjectID != input.ProjectID {
+			return nil, fmt.Errorf("cidr %s is already in use", subnet.CIDR)
+		}
+		return nil, fmt.Errorf("cidr %s overlaps network %s", subnet.CIDR, existing.NetworkID)
+	}
+
+	securityGroupID := valueOrEmpty(input.SecurityGroupID)
+	if securityGroupID != "" {
+		if _, err := m.getSecurityGroup(ctx, input.ProjectID, securityGroupID); err != nil {
+			return nil, err
+		}
+	}
+
+	networkID := fmt.Sprintf("net-%s", rand.String(8))
+
+	config, err := json.Marshal(map[string]interface{}{
+		"cniVersion":    "0.3.1",
+		"type":          "kube-ovn",
+		"server_socket": kubeOVNSocket,
+		"provider":      networkProvider(namespace, networkID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode network config: %v", err)
+	}
+
+	_, err = m.dynamicClient.Resource(KubeOVNSubnetGVR).Create(ctx, newSubnetObject(namespace, networkID, subnet), metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subnet: %v", err)
+	}
+
+	networkLabels := map[string]interface{}{
+		"app":        "cozystack-vm",
+		"created-by": "graphql-api",
+		"resource":   "network",
+		"project-id": input.ProjectID,
+		"region":     input.Region,
+	}
+	if securityGroupID != "" {
+		networkLabels[securityGroupLabel] = securityGroupID
+	}
+
+	networkObject := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "k8s.cni.cncf.io/v1",
+			"kind":       "NetworkAttachmentDefinition",
+			"metadata": map[string]interface{}{
+				"name":      networkID,
+				"namespace": namespace,
+				"labels":    networkLabels,
+				"annotations": map[string]interface{}{
+					networkNameAnnotation:    name,
+					networkCIDRAnnotation:    subnet.CIDR,
+					networkGatewayAnnotation: subnet.Gateway,
+				},
+			},
+			"spec": map[string]interface{}{
+				"config": string(config),
+			},
+		},
+	}
+
+	created, err := m.dynamicClient.Resource(NetworkAttachmentDefinitionGVR).Namespace(namespace).Create(ctx, networkObject, metav1.CreateOptions{})
+	if err != nil {
+		// Без NetworkAttachmentDefinition подсеть не нужна
+		m.deleteSubnet(ctx, namespace, networkID)
+		return nil, fmt.Errorf("failed to create network: %v", err)
+	}
+
+	return convertToNetworkModel(created), nil
+}
+
+// UpdateNetwork меняет имя сети и ее группу безопасности. Состав групп
+// обновит обработчик информера
+func (m *NetworkManager) UpdateNetwork(ctx context.Context, networkID string, networkName, securityGroupID *string) (*model.Network, error) {
+	current, err := m.GetNetwork(networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	if networkName != nil && strings.TrimSpace(*networkName) == "" {
+		return nil, fmt.Errorf("network name must not be empty")
+	}
+	if securityGroupID != nil && *securityGroupID != "" {
+		if _, err := m.getSecurityGroup(ctx, current.ProjectID, *securityGroupID); err != nil {
+			return nil, err
+		}
+	}
+
+	namespace, err := m.tenants.Namespace(current.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated *unstructured.Unstructured
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Получаем актуальную версию ресурса перед каждой попыткой
+		networkObj, err := m.dynamicClient.Resource(NetworkAttachmentDefinitionGVR).Namespace(namespace).Get(ctx, networkID, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if networkName != nil {
+			annotations := networkObj.GetAnnotations()
+			annotations[networkNameAnnotation] = strings.TrimSpace(*networkName)
+			networkObj.SetAnnotations(annotations)
+		}
+		if securityGroupID != nil {
+			networkLabels := networkObj.GetLabels()
+			if *securityGroupID == "" {
+				delete(networkLabels, securityGroupLabel)
+			} else {
+				networkLabels[securityGroupLabel] = *securityGroupID
+			}
+			networkObj.SetLabels(networkLabels)
+		}
+
+		updated, err = m.dynamicClient.Resource(NetworkAttachmentDefinitionGVR).Namespace(namespace).Update(ctx, networkObj, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update network: %v", err)
+	}
+
+	return convertToNetworkModel(updated), nil
+}
+
+// DeleteNetwork удаляет NetworkAttachmentDefinition и подсеть сети
+func (m *NetworkManager) DeleteNetwork(ctx context.Context, networkID string) error {
+	networkObj, exists := m.getNetworkObject(networkID)
+	if !exists {
+		return fmt.Errorf("network not found: %s", networkID)
+	}
+	namespace := networkObj.GetNamespace()
+
+	err := m.dynamicClient.Resource(NetworkAttachmentDefinitionGVR).Namespace(namespace).Delete(ctx, networkID, metav1.DeleteOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return fmt.Errorf("network not found: %s", networkID)
+		}
+		return fmt.Errorf("failed to delete network: %v", err)
+	}
+
+	m.deleteSubnet(ctx, namespace, networkID)
+
+	return nil
+}
+
+// ResolveNetworks находит сети, к которым подключается новый инстанс. Сети
+// должны принадлежать проекту и находиться в регионе инстанса
+func (m *NetworkManager) ResolveNetworks(projectID, region string, networkIDs []string) ([]*model.Network, error) {
+	networks := make([]*model.Network, 0, len(networkIDs))
+	seen := make(map[string]bool)
+	for _, networkID := range networkIDs {
+		if seen[networkID] {
+			continue
+		}
+		seen[networkID] = true
+
+		networkModel, err := m.GetNetwork(networkID)
+		if err != nil || networkModel.ProjectID != projectID {
+			return nil, fmt.Errorf("network not found: %s", networkID)
+		}
+		if networkModel.Region != region {
+			return nil, fmt.Errorf("network %s is in region %s, not %s", networkID, networkModel.Region, region)
+		}
+
+		networks = append(networks, networkModel)
+	}
+
+	return networks, nil
+}
+
+// InstanceNetworks возвращает сети из spec.subnets VMInstance вместе с
+// адресами, которые VM получила в этих сетях. VM подключается только к сетям
+// своего namespace, поэтому сети других тенантов не раскрываются
+func (m *NetworkManager) InstanceNetworks(vmObj *unstructured.Unstructured) []*model.Network {
+	addresses := m.instanceAddresses(vmObj)
+
+	networks := []*model.Network{}
+	for _, networkID := range instanceSubnets(vmObj) {
+		var networkModel *model.Network
+		if networkObj, exists := m.getNetworkObject(networkID); exists && networkObj.GetNamespace() == vmObj.GetNamespace() {
+			networkModel = convertToNetworkModel(networkObj)
+		} else {
+			// Сеть удалена или создана не через API
+			networkModel = &model.Network{
+				NetworkID:   networkID,
+				NetworkName: networkID,
+			}
+		}
+
+		networkModel.IPV4 = addresses[networkID]
+		networks = append(networks, networkModel)
+	}
+
+	return networks
+}
+
+// GetSecurityGroup возвращает группу безопасности по ID
+func (m *NetworkManager) GetSecurityGroup(ctx context.Context, securityGroupID string) (*model.SecurityGroup, error) {
+	policy, err := m.getSecurityGroup(ctx, "", securityGroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertToSecurityGroup(policy), nil
+}
+
+// ListSecurityGroups возвращает группы безопасности проекта
+func (m *NetworkManager) ListSecurityGroups(ctx context.Context, projectID string) ([]*model.SecurityGroup, error) {
+	namespace, err := m.tenants.Namespace(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	selector := labels.Set{
+		"app":        "cozystack-vm",
+		"resource":   "security-group",
+		"project-id": projectID,
+	}
+
+	policies, err := m.k8sClient.NetworkingV1().NetworkPolicies(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list security groups: %v", err)
+	}
+
+	groups := make([]*model.SecurityGroup, 0, len(policies.Items))
+	for i := range policies.Items {
+		groups = append(groups, convertToSecurityGroup(&policies.Items[i]))
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Name != groups[j].Name {
+			return groups[i].Name < groups[j].Name
+		}
+		return groups[i].SecurityGroupID < groups[j].SecurityGroupID
+	})
+
+	return groups, nil
+}
+
+// CreateSecurityGroup создает NetworkPolicy группы безопасности
+func (m *NetworkManager) CreateSecurityGroup(ctx context.Context, input model.NewSecurityGroupInput) (*model.SecurityGroup, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, fmt.Errorf("security group name must not be empty")
+	}
+
+	rules, err := network.Rules(input.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, err := m.tenants.Namespace(input.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	groupID := fmt.Sprintf("sg-%s", rand.String(8))
+
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      groupID,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app":        "cozystack-vm",
+				"created-by": "graphql-api",
+				"resource":   "security-group",
+				"project-id": input.ProjectID,
+			},
+			Annotations: map[string]string{
+				securityGroupNameAnnotation: name,
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			// Новая группа еще не назначена ни одной сети
+			PodSelector: memberSelector(groupID, nil),
+		},
+	}
+	setPolicyRules(&policy.Spec, rules)
+
+	created, err := m.k8sClient.NetworkingV1().NetworkPolicies(namespace).Create(ctx, policy, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create security group: %v", err)
+	}
+
+	return convertToSecurityGroup(created), nil
+}
+
+// UpdateSecurityGroup меняет имя группы безопасности и заменяет ее правила
+func (m *NetworkManager) UpdateSecurityGroup(ctx context.Context, securityGroupID string, name *string, ruleInput []*model.SecurityGroupRuleInput) (*model.SecurityGroup, error) {
+	if name != nil && strings.TrimSpace(*name) == "" {
+		return nil, fmt.Errorf("security group name must not be empty")
+	}
+
+	if _, err := m.getSecurityGroup(ctx, "", securityGroupID); err != nil {
+		return nil, err
+	}
+
+	var rules []*model.SecurityGroupRule
+	if ruleInput != nil {
+		var err error
+		rules, err = network.Rules(ruleInput)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var updated *networkingv1.NetworkPolicy
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Получаем актуальную версию ресурса перед каждой попыткой
+		policy, err := m.getSecurityGroup(ctx, "", securityGroupID)
+		if err != nil {
+			return err
+		}
+
+		if name != nil {
+			policy.Annotations[securityGroupNameAnnotation] = strings.TrimSpace(*name)
+		}
+		if ruleInput != nil {
+			setPolicyRules(&policy.Spec, rules)
+		}
+
+		updated, err = m.k8sClient.NetworkingV1().NetworkPolicies(policy.Namespace).Update(ctx, policy, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update security group: %v", err)
+	}
+
+	return convertToSecurityGroup(updated), nil
+}
+
+// DeleteSecurityGroup удаляет группу безопасности, если ее не использует ни одна сеть
+func (m *NetworkManager) DeleteSecurityGroup(ctx context.Context, securityGroupID string) error {
+	policy, err := m.getSecurityGroup(ctx, "", securityGroupID)
+	if err != nil {
+		return err
+	}
+
+	for _, networkModel := range m.ListNetworks(nil) {
+		if networkModel.SecurityGroupID == securityGroupID {
+			return fmt.Errorf("security group %s is used by network %s", securityGroupID, networkModel.NetworkID)
+		}
+	}
+
+	err = m.k8sClient.NetworkingV1().NetworkPolicies(policy.Namespace).Delete(ctx, securityGroupID, metav1.DeleteOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return fmt.Errorf("failed to delete security group: %v", err)
+	}
+
+	return nil
+}
+
+// SyncSecurityGroups выбирает в NetworkPolicy групп pod тех VM, которые
+// подключены к сетям этих групп. Без аргументов обновляются все группы
+func (m *NetworkManager) SyncSecurityGroups(ctx context.Context, securityGroupIDs ...string) {
+	m.syncMutex.Lock()
+	defer m.syncMutex.Unlock()
+
+	if len(securityGroupIDs) == 0 {
+		policies, err := m.k8sClient.NetworkingV1().NetworkPolicies(m.tenants.WatchNamespace()).List(ctx, metav1.ListOptions{
+			LabelSelector: "created-by=graphql-api,resource=security-group",
+		})
+		if err != nil {
+			fmt.Printf("Warning: failed to list security groups: %v\n", err)
+			return
+		}
+		for i := range policies.Items {
+			if m.tenants.Owns(&policies.Items[i]) {
+				securityGroupIDs = append(securityGroupIDs, policies.Items[i].Name)
+			}
+		}
+	}
+
+	members := m.groupMembers()
+
+	synced := make(map[string]bool)
+	for _, securityGroupID := range securityGroupIDs {
+		if securityGroupID == "" || synced[securityGroupID] {
+			continue
+		}
+		synced[securityGroupID] = true
+
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			policy, err := m.getSecurityGroup(ctx, "", securityGroupID)
+			if err != nil {
+				return err
+			}
+
+			selector := memberSelector(securityGroupID, members[securityGroupID])
+			if equality.Semantic.DeepEqual(policy.Spec.PodSelector, selector) {
+				return nil
+			}
+
+			policy.Spec.PodSelector = selector
+			_, err = m.k8sClient.NetworkingV1().NetworkPolicies(policy.Namespace).Update(ctx, policy, metav1.UpdateOptions{})
+			return err
+		})
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			fmt.Printf("Warning: failed to update members of security group %s: %v\n", securityGroupID, err)
+		}
+	}
+}
+
+// syncInstanceGroups обновляет группы сетей созданной или удаленной VM
+func (m *NetworkManager) syncInstanceGroups(obj interface{}) {
+	vmObj, ok := objectFromEvent(obj)
+	if !ok {
+		return
+	}
+
+	var securityGroupIDs []string
+	for _, networkID := range instanceSubnets(vmObj) {
+		if networkObj, exists := m.getNetworkObject(networkID); exists && networkObj.GetNamespace() == vmObj.GetNamespace() {
+			securityGroupIDs = append(securityGroupIDs, networkObj.GetLabels()[securityGroupLabel])
+		}
+	}
+
+	if len(securityGroupIDs) > 0 {
+		m.SyncSecurityGroups(context.Background(), securityGroupIDs...)
+	}
+}
+
+// onNetworkChanged обновляет состав групп при смене группы сети
+func (m *NetworkManager) onNetworkChanged(oldObj, newObj interface{}) {
+	oldNetwork, ok := objectFromEvent(oldObj)
+	if !ok {
+		return
+	}
+	newNetwork, ok := objectFromEvent(newObj)
+	if !ok {
+		return
+	}
+
+	oldGroup := oldNetwork.GetLabels()[securityGroupLabel]
+	newGroup := newNetwork.GetLabels()[securityGroupLabel]
+	if oldGroup != newGroup {
+		go m.SyncSecurityGroups(context.Background(), oldGroup, newGroup)
+	}
+}
+
+// onNetworkDeleted убирает VM удаленной сети из ее группы
+func (m *NetworkManager) onNetworkDeleted(obj interface{}) {
+	networkObj, ok := objectFromEvent(obj)
+	if !ok {
+		return
+	}
+
+	if securityGroupID := networkObj.GetLabels()[securityGroupLabel]; securityGroupID != "" {
+		go m.SyncSecurityGroups(context.Background(), securityGroupID)
+	}
+}
+
+// groupMembers возвращает имена VirtualMachine KubeVirt, подключенных к сетям
+// каждой группы. NetworkPolicy выбирает pod только своего namespace, поэтому
+// учитываются сети из namespace VM
+func (m *NetworkManager) groupMembers() map[string][]string {
+	memberSets := make(map[string]map[string]bool)
+	for _, vmObj := range m.store.ListInstances() {
+		for _, networkID := range instanceSubnets(vmObj) {
+			networkObj, exists := m.getNetworkObject(networkID)
+			if !exists || networkObj.GetNamespace() != vmObj.GetNamespace() {
+				continue
+			}
+
+			securityGroupID := networkObj.GetLabels()[securityGroupLabel]
+			if securityGroupID == "" {
+				continue
+			}
+			if memberSets[securityGroupID] == nil {
+				memberSets[securityGroupID] = make(map[string]bool)
+			}
+			memberSets[securityGroupID][kubevirtVMPrefix+vmObj.GetName()] = true
+		}
+	}
+
+	members := make(map[string][]string, len(memberSets))
+	for securityGroupID, set := range memberSets {
+		for vmName := range set {
+			members[securityGroupID] = append(members[securityGroupID], vmName)
+		}
+		sort.Strings(members[securityGroupID])
+	}
+
+	return members
+}
+
+// getSecurityGroup возвращает NetworkPolicy группы безопасности. Непустой
+// projectID проверяет, что группа принадлежит проекту; без него группа ищется
+// во всех namespace тенантов
+func (m *NetworkManager) getSecurityGroup(ctx context.Context, projectID, securityGroupID string) (*networkingv1.NetworkPolicy, error) {
+	var policy *networkingv1.NetworkPolicy
+	if projectID != "" {
+		namespace, err := m.tenants.Namespace(projectID)
+		if err != nil {
+			return nil, err
+		}
+
+		policy, err = m.k8sClient.NetworkingV1().NetworkPolicies(namespace).Get(ctx, securityGroupID, metav1.GetOptions{})
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				return nil, fmt.Errorf("security group not found: %s", securityGroupID)
+			}
+			return nil, fmt.Errorf("failed to get security group: %v", err)
+		}
+	} else {
+		var err error
+		policy, err = m.findSecurityGroup(ctx, securityGroupID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if policy.Labels["resource"] != "security-group" || !m.tenants.Owns(policy) ||
+		(projectID != "" && policy.Labels["project-id"] != projectID) {
+		return nil, fmt.Errorf("security group not found: %s", securityGroupID)
+	}
+	if policy.Annotations == nil {
+		policy.Annotations = make(map[string]string)
+	}
+
+	return policy, nil
+}
+
+// findSecurityGroup ищет NetworkPolicy группы безопасности по ID во всех
+// namespace тенантов. Одноименные группы разных тенантов не отличить по ID,
+// поэтому такая группа не находится
+func (m *NetworkManager) findSecurityGroup(ctx context.Context, securityGroupID string) (*networkingv1.NetworkPolicy, error) {
+	policies, err := m.k8sClient.NetworkingV1().NetworkPolicies(m.tenants.WatchNamespace()).List(ctx, metav1.ListOptions{
+		LabelSelector: "created-by=graphql-api,resource=security-group",
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", securityGroupID).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get security group: %v", err)
+	}
+
+	var found *networkingv1.NetworkPolicy
+	for i := range policies.Items {
+		if !m.tenants.Owns(&policies.Items[i]) {
+			continue
+		}
+		if found != nil {
+			fmt.Printf("Warning: security group %s exists in namespaces %s and %s, ignoring it\n", securityGroupID, found.Namespace, policies.Items[i].Namespace)
+			return nil, fmt.Errorf("security group not found: %s", securityGroupID)
+		}
+		found = &policies.Items[i]
+	}
+	if found == nil {
+		return nil, fmt.Errorf("security group not found: %s", securityGroupID)
+	}
+
+	return found, nil
+}
+
+// getNetworkObject ищет NetworkAttachmentDefinition сети тенанта в памяти
+func (m *NetworkManager) getNetworkObject(networkID string) (*unstructured.Unstructured, bool) {
+	return m.tenants.ownedByName(m.networks, networkID)
+}
+
+// instanceAddresses возвращает адреса VM по именам ее сетей
+func (m *NetworkManager) instanceAddresses(vmObj *unstructured.Unstructured) map[string]string {
+	addresses := make(map[string]string)
+
+	item, exists, err := m.vmis.GetStore().GetByKey(vmObj.GetNamespace() + "/" + kubevirtVMPrefix + vmObj.GetName())
+	if err != nil || !exists {
+		return addresses
+	}
+	vmiObj, ok := item.(*unstructured.Unstructured)
+	if !ok {
+		return addresses
+	}
+
+	interfaces, _, _ := unstructured.NestedSlice(vmiObj.Object, "status", "interfaces")
+	for _, interfaceData := range interfaces {
+		iface, ok := interfaceData.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _ := iface["name"].(string)
+		ipAddress, _ := iface["ipAddress"].(string)
+		if name != "" && ipAddress != "" {
+			addresses[name] = ipAddress
+		}
+	}
+
+	return addresses
+}
+
+// newSubnetObject создает подсеть kube-ovn для сети. Подсети kube-ovn
+// кластерные, поэтому в имя входит namespace
+func newSubnetObject(namespace, networkID string, subnet *network.Subnet) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "kubeovn.io/v1",
+			"kind":       "Subnet",
+			"metadata": map[string]interface{}{
+				"name": subnetName(namespace, networkID),
+				"labels": map[string]interface{}{
+					"app":        "cozystack-vm",
+					"created-by": "graphql-api",
+					"resource":   "network",
+					"network-id": networkID,
+				},
+			},
+			"spec": map[string]interface{}{
+				"protocol":  "IPv4",
+				"cidrBlock": subnet.CIDR,
+				"gateway":   subnet.Gateway,
+				"provider":  networkProvider(namespace, networkID),
+			},
+		},
+	}
+}
+
+// deleteSubnet удаляет подсеть kube-ovn сети
+func (m *NetworkManager) deleteSubnet(ctx context.Context, namespace, networkID string) {
+	err := m.dynamicClient.Resource(KubeOVNSubnetGVR).Delete(ctx, subnetName(namespace, networkID), metav1.DeleteOptions{})
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		fmt.Printf("Warning: failed to delete subnet of network %s: %v\n", networkID, err)
+	}
+}
+
+// subnetName возвращает имя подсети kube-ovn сети
+func subnetName(namespace, networkID string) string {
+	return namespace + "-" + networkID
+}
+
+// networkProvider возвращает provider kube-ovn, которым подсеть связана с NetworkAttachmentDefinition
+func networkProvider(namespace, networkID string) string {
+	return fmt.Sprintf("%s.%s.ovn", networkID, namespace)
+}
+
+// instanceSubnets возвращает имена сетей из spec.subnets VMInstance
+func instanceSubnets(vmObj *unstructured.Unstructured) []string {
+	subnets, _, _ := unstructured.NestedSlice(vmObj.Object, "spec", "subnets")
+
+	networkIDs := make([]string, 0, len(subnets))
+	for _, subnetData := range subnets {
+		subnetRef, ok := subnetData.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, ok := subnetRef["name"].(string); ok && name != "" {
+			networkIDs = append(networkIDs, name)
+		}
+	}
+
+	return networkIDs
+}
+
+// memberSelector выбирает pod VM группы безопасности. Оператор In не допускает
+// пустого списка, поэтому группа без VM выбирает pod по метке, которой нет ни у одного pod
+func memberSelector(securityGroupID string, vmNames []string) metav1.LabelSelector {
+	if len(vmNames) == 0 {
+		return metav1.LabelSelector{
+			MatchLabels: map[string]string{securityGroupLabel: securityGroupID},
+		}
+	}
+
+	return metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      kubevirtVMLabel,
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   vmNames,
+		}},
+	}
+}
+
+// setPolicyRules заменяет правила NetworkPolicy правилами группы. Входящий
+// трафик запрещен всегда, кроме разрешенного правилами; исходящий
+// ограничивается, только если у группы есть правила для него
+func setPolicyRules(spec *networkingv1.NetworkPolicySpec, rules []*model.SecurityGroupRule) {
+	spec.Ingress = nil
+	spec.Egress = nil
+	spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+
+	for _, rule := range rules {
+		peers := []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: rule.Cidr}}}
+
+		if rule.Direction == model.TrafficDirectionIngress {
+			spec.Ingress = append(spec.Ingress, networkingv1.NetworkPolicyIngressRule{From: peers, Ports: policyPorts(rule)})
+		} else {
+			spec.Egress = append(spec.Egress, networkingv1.NetworkPolicyEgressRule{To: peers, Ports: policyPorts(rule)})
+		}
+	}
+
+	if len(spec.Egress) > 0 {
+		spec.PolicyTypes = append(spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+	}
+}
+
+// policyPorts преобразует протокол и порты правила в порты NetworkPolicy
+func policyPorts(rule *model.SecurityGroupRule) []networkingv1.NetworkPolicyPort {
+	if rule.Protocol == model.ProtocolAny {
+		return nil
+	}
+
+	protocol := corev1.Protocol(rule.Protocol)
+	port := networkingv1.NetworkPolicyPort{Protocol: &protocol}
+	if rule.PortMin != nil {
+		portMin := intstr.FromInt32(*rule.PortMin)
+		port.Port = &portMin
+		port.EndPort = rule.PortMax
+	}
+
+	return []networkingv1.NetworkPolicyPort{port}
+}
+
+// convertToNetworkModel преобразует NetworkAttachmentDefinition в модель сети
+func convertToNetworkModel(networkObj *unstructured.Unstructured) *model.Network {
+	networkLabels := networkObj.GetLabels()
+	annotations := networkObj.GetAnnotations()
+
+	return &model.Network{
+		NetworkID:        networkObj.GetName(),
+		NetworkName:      annotations[networkNameAnnotation],
+		Cidr:             annotations[networkCIDRAnnotation],
+		GatewayIP:        annotations[networkGatewayAnnotation],
+		IsPublic:         false,
+		AvailabilityZone: networkLabels["region"],
+		Region:           networkLabels["region"],
+		SecurityGroupID:  networkLabels[securityGroupLabel],
+		ProjectID:        networkLabels["project-id"],
+	}
+}
+
+// convertToSecurityGroup преобразует NetworkPolicy в модель группы безопасности
+func convertToSecurityGroup(policy *networkingv1.NetworkPolicy) *model.SecurityGroup {
+	rules := []*model.SecurityGroupRule{}
+	for _, rule := range policy.Spec.Ingress {
+		rules = append(rules, convertToRules(model.TrafficDirectionIngress, rule.From, rule.Ports)...)
+	}
+	for _, rule := range policy.Spec.Egress {
+		rules = append(rules, convertToRules(model.TrafficDirectionEgress, rule.To, rule.Ports)...)
+	}
+
+	return &model.SecurityGroup{
+		SecurityGroupID: policy.Name,
+		ProjectID:       policy.Labels["project-id"],
+		Name:            policy.Annotations[securityGroupNameAnnotation],
+		Rules:           rules,
+	}
+}
+
+// convertToRules преобразует правило NetworkPolicy в правила группы. Пиры
+// без ipBlock группой не создаются и пропускаются
+func convertToRules(direction model.TrafficDirection, peers []networkingv1.NetworkPolicyPeer, ports []networkingv1.NetworkPolicyPort) []*model.SecurityGroupRule {
+	var rules []*model.SecurityGroupRule
+	for _, peer := range peers {
+		if peer.IPBlock == nil {
+			continue
+		}
+
+		if len(ports) == 0 {
+			rules = append(rules, &model.SecurityGroupRule{
+				Direction: direction,
+				Protocol:  model.ProtocolAny,
+				Cidr:      peer.IPBlock.CIDR,
+			})
+			continue
+		}
+
+		for _, port := range ports {
+			// Протокол по умолчанию в NetworkPolicy - TCP
+			protocol := model.ProtocolTCP
+			if port.Protocol != nil {
+				protocol = model.Protocol(*port.Protocol)
+			}
+
+			rule := &model.SecurityGroupRule{
+				Direction: direction,
+				Protocol:  protocol,
+				PortMax:   port.EndPort,
+				Cidr:      peer.IPBlock.CIDR,
+			}
+			if port.Port != nil {
+				portMin := port.Port.IntVal
+				rule.PortMin = &portMin
+			}
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
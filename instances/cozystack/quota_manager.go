@@ -0,0 +1,99 @@
+package cozystack
+
+import (
+	"bytes"
+	"sync"
+
+	"gqlfed/instances/graph/model"
+	"gqlfed/instances/quota"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// quotaConfigKey - ключ ConfigMap, в котором лежат квоты проектов
+const quotaConfigKey = "quotas.yaml"
+
+// QuotaManager хранит квоты проектов. Квоты загружаются из файла или ConfigMap
+// и перечитываются при их изменении; без источника проекты не ограничены
+type QuotaManager struct {
+	mu     sync.RWMutex
+	config *quota.Config
+	// content - содержимое последнего загруженного файла квот
+	content []byte
+	// locks сериализует проверку квот и создание ресурсов в каждом проекте
+	locks   map[string]*sync.Mutex
+	locksMu sync.Mutex
+	stopCh  chan struct{}
+}
+
+// NewQuotaManager создает менеджер без квот
+func NewQuotaManager() *QuotaManager {
+	return &QuotaManager{
+		locks:  make(map[string]*sync.Mutex),
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Load заменяет квоты содержимым файла квот в формате YAML или JSON
+func (m *QuotaManager) Load(content []byte) error {
+	m.mu.RLock()
+	unchanged := bytes.Equal(m.content, content)
+	m.mu.RUnlock()
+
+	if unchanged {
+		return nil
+	}
+
+	config, err := quota.Parse(content)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.config = config
+	m.content = content
+	m.mu.Unlock()
+
+	return nil
+}
+
+// WatchFile загружает квоты из файла и перечитывает его при изменении.
+// Ошибка возвращается только для первой загрузки; при ошибке перечитывания
+// остаются последние корректные квоты
+func (m *QuotaManager) WatchFile(path string) error {
+	return watchConfigFile(path, m.stopCh, m.Load)
+}
+
+// WatchConfigMap загружает квоты из ConfigMap и перечитывает их при изменении
+func (m *QuotaManager) WatchConfigMap(client kubernetes.Interface, namespace, name string) error {
+	return watchConfigMap(client, namespace, name, quotaConfigKey, m.stopCh, m.Load)
+}
+
+// Stop прекращает отслеживание источника квот
+func (m *QuotaManager) Stop() {
+	close(m.stopCh)
+}
+
+// Limits возвращает квоты проекта
+func (m *QuotaManager) Limits(projectID string) *model.QuotaLimits {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.config.For(projectID)
+}
+
+// Lock захватывает блокировку проекта и возвращает функцию ее освобождения.
+// Без нее параллельные запросы прошли бы проверку по одному и тому же
+// потреблению и вместе превысили квоту
+func (m *QuotaManager) Lock(projectID string) func() {
+	m.locksMu.Lock()
+	lock, exists := m.locks[projectID]
+	if !exists {
+		lock = &sync.Mutex{}
+		m.locks[projectID] = lock
+	}
+	m.locksMu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
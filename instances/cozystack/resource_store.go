@@ -0,0 +1,144 @@
+package cozystack
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// defaultResyncPeriod определяет период полной пересинхронизации информеров
+	defaultResyncPeriod = 10 * time.Minute
+
+	// defaultSyncTimeout определяет, сколько ждать первичной загрузки информеров
+	defaultSyncTimeout = 30 * time.Second
+)
+
+// ResourceStore держит shared-информеры VMInstance и VMDisk.
+// Информеры один раз выполняют List, после чего получают изменения через Watch,
+// поэтому кэши менеджеров обновляются без периодического опроса API-сервера.
+// Объекты, которые не принадлежат тенанту своего проекта, хранилище не отдает
+type ResourceStore struct {
+	tenants   *TenantMap
+	factory   dynamicinformer.DynamicSharedInformerFactory
+	instances cache.SharedIndexInformer
+	disks     cache.SharedIndexInformer
+	stopCh    chan struct{}
+}
+
+// NewResourceStore создает хранилище ресурсов для namespace тенантов
+func NewResourceStore(dynamicClient dynamic.Interface, tenants *TenantMap, resyncPeriod time.Duration) *ResourceStore {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, resyncPeriod, tenants.WatchNamespace(), nil)
+
+	store := &ResourceStore{
+		tenants:   tenants,
+		factory:   factory,
+		instances: factory.ForResource(VMInstanceGVR).Informer(),
+		disks:     factory.ForResource(VMDiskGVR).Informer(),
+		stopCh:    make(chan struct{}),
+	}
+
+	// При ошибке Watch рефлектор сам выполняет повторный List с экспоненциальной
+	// задержкой, нам остается только залогировать проблему
+	store.setWatchErrorHandler(store.instances, VMInstanceGVR)
+	store.setWatchErrorHandler(store.disks, VMDiskGVR)
+
+	addNameIndex(store.instances)
+	addNameIndex(store.disks)
+
+	return store
+}
+
+// setWatchErrorHandler логирует ошибки Watch перед повторным List
+func (s *ResourceStore) setWatchErrorHandler(informer cache.SharedIndexInformer, gvr schema.GroupVersionResource) {
+	err := informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+		fmt.Printf("Warning: watch of %s failed, relisting: %v\n", gvr.Resource, err)
+		cache.DefaultWatchErrorHandler(r, err)
+	})
+	if err != nil {
+		fmt.Printf("Warning: failed to set watch error handler for %s: %v\n", gvr.Resource, err)
+	}
+}
+
+// AddInstanceHandler подписывает обработчик на изменения VMInstance тенантов
+func (s *ResourceStore) AddInstanceHandler(handler cache.ResourceEventHandler) error {
+	_, err := s.instances.AddEventHandler(s.tenants.ownedEvents(handler))
+	return err
+}
+
+// AddDiskHandler подписывает обработчик на изменения VMDisk тенантов
+func (s *ResourceStore) AddDiskHandler(handler cache.ResourceEventHandler) error {
+	_, err := s.disks.AddEventHandler(s.tenants.ownedEvents(handler))
+	return err
+}
+
+// Start запускает информеры и ждет первичной загрузки данных
+func (s *ResourceStore) Start(timeout time.Duration) error {
+	s.factory.Start(s.stopCh)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if !cache.WaitForCacheSync(ctx.Done(), s.instances.HasSynced, s.disks.HasSynced) {
+		return fmt.Errorf("timed out waiting for informer caches to sync")
+	}
+
+	return nil
+}
+
+// Stop останавливает информеры
+func (s *ResourceStore) Stop() {
+	close(s.stopCh)
+	s.factory.Shutdown()
+}
+
+// ListInstances возвращает все VMInstance из памяти
+func (s *ResourceStore) ListInstances() []*unstructured.Unstructured {
+	return s.tenants.listOwned(s.instances)
+}
+
+// GetInstance возвращает VMInstance по имени из памяти
+func (s *ResourceStore) GetInstance(name string) (*unstructured.Unstructured, bool) {
+	return s.tenants.ownedByName(s.instances, name)
+}
+
+// ListDisks возвращает все VMDisk из памяти
+func (s *ResourceStore) ListDisks() []*unstructured.Unstructured {
+	return s.tenants.listOwned(s.disks)
+}
+
+// GetDisk возвращает VMDisk по имени из памяти
+func (s *ResourceStore) GetDisk(name string) (*unstructured.Unstructured, bool) {
+	return s.tenants.ownedByName(s.disks, name)
+}
+
+// listObjects возвращает все объекты из индекса информера
+func listObjects(informer cache.SharedIndexInformer) []*unstructured.Unstructured {
+	items := informer.GetIndexer().List()
+
+	objects := make([]*unstructured.Unstructured, 0, len(items))
+	for _, item := range items {
+		if obj, ok := item.(*unstructured.Unstructured); ok {
+			objects = append(objects, obj)
+		}
+	}
+
+	return objects
+}
+
+// objectFromEvent извлекает объект из события информера, в том числе
+// из DeletedFinalStateUnknown, который приходит при пропущенном удалении
+func objectFromEvent(obj interface{}) (*unstructured.Unstructured, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	u, ok := obj.(*unstructured.Unstructured)
+	return u, ok
+}
@@ -0,0 +1,85 @@
+package cozystack
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// CozyResource определяет ресурсы CozyStack в Kubernetes
+var (
+	// VMDisk resource
+	VMDiskGVR = schema.GroupVersionResource{
+		Group:    "apps.cozystack.io",
+		Version:  "v1alpha1",
+		Resource: "vmdisks",
+	}
+
+	// VMInstance resource
+	VMInstanceGVR = schema.GroupVersionResource{
+		Group:    "apps.cozystack.io",
+		Version:  "v1alpha1",
+		Resource: "vminstances",
+	}
+
+	// VirtualMachineInstance KubeVirt, в котором запущена VM
+	KubeVirtVMIGVR = schema.GroupVersionResource{
+		Group:    "kubevirt.io",
+		Version:  "v1",
+		Resource: "virtualmachineinstances",
+	}
+
+	// VirtualMachineClusterInstancetype KubeVirt, из которого берутся параметры flavor
+	ClusterInstancetypeGVR = schema.GroupVersionResource{
+		Group:    "instancetype.kubevirt.io",
+		Version:  "v1beta1",
+		Resource: "virtualmachineclusterinstancetypes",
+	}
+
+	// VolumeSnapshot, в котором хранится снимок диска
+	VolumeSnapshotGVR = schema.GroupVersionResource{
+		Group:    "snapshot.storage.k8s.io",
+		Version:  "v1",
+		Resource: "volumesnapshots",
+	}
+
+	// PersistentVolumeClaim, в котором хранятся данные VMDisk
+	PersistentVolumeClaimGVR = schema.GroupVersionResource{
+		Version:  "v1",
+		Resource: "persistentvolumeclaims",
+	}
+
+	// DataVolume CDI, через который VMDisk импортирует образ
+	DataVolumeGVR = schema.GroupVersionResource{
+		Group:    "cdi.kubevirt.io",
+		Version:  "v1beta1",
+		Resource: "datavolumes",
+	}
+
+	// UploadTokenRequest CDI, которым выдается токен для загрузки образа
+	UploadTokenRequestGVR = schema.GroupVersionResource{
+		Group:    "upload.cdi.kubevirt.io",
+		Version:  "v1beta1",
+		Resource: "uploadtokenrequests",
+	}
+
+	// NetworkAttachmentDefinition Multus, через который VM подключается к сети
+	NetworkAttachmentDefinitionGVR = schema.GroupVersionResource{
+		Group:    "k8s.cni.cncf.io",
+		Version:  "v1",
+		Resource: "network-attachment-definitions",
+	}
+
+	// Subnet kube-ovn, в котором выделяются адреса сети
+	KubeOVNSubnetGVR = schema.GroupVersionResource{
+		Group:    "kubeovn.io",
+		Version:  "v1",
+		Resource: "subnets",
+	}
+)
+
+const (
+	// kubevirtVMPrefix - префикс имени VirtualMachine KubeVirt, которую CozyStack создает для VMInstance
+	kubevirtVMPrefix = "vm-instance-"
+
+	// diskVolumePrefix - префикс имени DataVolume и PVC, которые CozyStack создает для VMDisk
+	diskVolumePrefix = "vm-disk-"
+)
@@ -0,0 +1,195 @@
+package cozystack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gqlfed/instances/graph/model"
+	"gqlfed/instances/rbac"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// roleBindingSelector отбирает привязки ролей, созданные API
+	roleBindingSelector = "created-by=graphql-api,resource=role-binding"
+	// projectIndex - индекс привязок ролей по проекту
+	projectIndex = "project"
+)
+
+// RoleManager хранит привязки ролей проектов в ConfigMap. Каждая привязка -
+// отдельный ConfigMap с метками проекта и пользователя; привязки проверяются
+// при каждом запросе, поэтому читаются из информера
+type RoleManager struct {
+	namespace string
+	k8sClient kubernetes.Interface
+	bindings  cache.SharedIndexInformer
+	stopCh    chan struct{}
+}
+
+// newRoleManager создает менеджер привязок ролей для указанного namespace
+func newRoleManager(k8sClient kubernetes.Interface, namespace string) *RoleManager {
+	factory := informers.NewSharedInformerFactoryWithOptions(k8sClient, defaultResyncPeriod,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = roleBindingSelector
+		}),
+	)
+
+	return &RoleManager{
+		namespace: namespace,
+		k8sClient: k8sClient,
+		bindings:  factory.Core().V1().ConfigMaps().Informer(),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start загружает привязки ролей и начинает отслеживать их изменения
+func (m *RoleManager) Start() error {
+	err := m.bindings.AddIndexers(cache.Indexers{
+		projectIndex: func(obj interface{}) ([]string, error) {
+			configMap, ok := obj.(*corev1.ConfigMap)
+			if !ok {
+				return nil, nil
+			}
+			return []string{configMap.Labels["project-id"]}, nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error registering role binding index: %v", err)
+	}
+
+	go m.bindings.Run(m.stopCh)
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSyncTimeout)
+	defer cancel()
+
+	if !cache.WaitForCacheSync(ctx.Done(), m.bindings.HasSynced) {
+		m.Stop()
+		return fmt.Errorf("timed out waiting for role bindings to sync")
+	}
+
+	return nil
+}
+
+// Stop останавливает отслеживание привязок ролей
+func (m *RoleManager) Stop() {
+	close(m.stopCh)
+}
+
+// ListBindings возвращает привязки ролей проекта из памяти
+func (m *RoleManager) ListBindings(projectID string) ([]*model.RoleBinding, error) {
+	items, err := m.bindings.GetIndexer().ByIndex(projectIndex, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role bindings: %v", err)
+	}
+
+	bindings := make([]*model.RoleBinding, 0, len(items))
+	for _, item := range items {
+		if configMap, ok := item.(*corev1.ConfigMap); ok {
+			bindings = append(bindings, convertToRoleBinding(configMap))
+		}
+	}
+
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].UserID < bindings[j].UserID
+	})
+
+	return bindings, nil
+}
+
+// SetBinding назначает пользователю роль в проекте, заменяя прежнюю
+func (m *RoleManager) SetBinding(ctx context.Context, projectID, userID string, role model.Role) (*model.RoleBinding, error) {
+	if projectID == "" || userID == "" {
+		return nil, fmt.Errorf("project_id and user_id must not be empty")
+	}
+
+	bindings, err := m.ListBindings(projectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := rbac.CheckChange(bindings, userID, role); err != nil {
+		return nil, err
+	}
+
+	name := roleBindingName(projectID, userID)
+	configMaps := m.k8sClient.CoreV1().ConfigMaps(m.namespace)
+
+	existing, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		existing.Data = map[string]string{"role": string(role)}
+		if _, err := configMaps.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to update role binding: %v", err)
+		}
+	case strings.Contains(err.Error(), "not found"):
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: m.namespace,
+				Labels: map[string]string{
+					"app":        "cozystack-vm",
+					"created-by": "graphql-api",
+					"resource":   "role-binding",
+					"project-id": projectID,
+				},
+				// ID пользователя может не подходить для значения метки
+				Annotations: map[string]string{"user-id": userID},
+			},
+			Data: map[string]string{"role": string(role)},
+		}
+		if _, err := configMaps.Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to save role binding: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("failed to get role binding: %v", err)
+	}
+
+	return &model.RoleBinding{ProjectID: projectID, UserID: userID, Role: role}, nil
+}
+
+// DeleteBinding отзывает роль пользователя в проекте
+func (m *RoleManager) DeleteBinding(ctx context.Context, projectID, userID string) error {
+	bindings, err := m.ListBindings(projectID)
+	if err != nil {
+		return err
+	}
+	if err := rbac.CheckChange(bindings, userID, ""); err != nil {
+		return err
+	}
+
+	err = m.k8sClient.CoreV1().ConfigMaps(m.namespace).Delete(ctx, roleBindingName(projectID, userID), metav1.DeleteOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return fmt.Errorf("role binding not found: %s", userID)
+		}
+		return fmt.Errorf("failed to delete role binding: %v", err)
+	}
+
+	return nil
+}
+
+// convertToRoleBinding преобразует ConfigMap в модель привязки роли
+func convertToRoleBinding(configMap *corev1.ConfigMap) *model.RoleBinding {
+	return &model.RoleBinding{
+		ProjectID: configMap.Labels["project-id"],
+		UserID:    configMap.Annotations["user-id"],
+		Role:      model.Role(configMap.Data["role"]),
+	}
+}
+
+// roleBindingName возвращает имя ConfigMap привязки. ID проекта и
+// пользователя могут содержать символы, недопустимые в именах Kubernetes,
+// поэтому используется хэш
+func roleBindingName(projectID, userID string) string {
+	sum := sha256.Sum256([]byte(projectID + "/" + userID))
+	return "role-binding-" + hex.EncodeToString(sum[:])[:16]
+}
@@ -0,0 +1,510 @@
+package cozystack
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gqlfed/instances/graph/model"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// populatedForAnnotation сообщает CDI, что PVC уже заполнен для DataVolume
+	// с указанным именем и импорт образа не нужен
+	populatedForAnnotation = "cdi.kubevirt.io/storage.populatedFor"
+
+	// volumeDeleteTimeout определяет, сколько ждать удаления PVC при восстановлении диска
+	volumeDeleteTimeout = 2 * time.Minute
+)
+
+// claimTemplate описывает параметры PVC, которые нужно повторить для диска из снимка
+type claimTemplate struct {
+	storageClass string
+	accessModes  []interface{}
+	volumeMode   string
+}
+
+// defaultClaimTemplate используется, если PVC исходного диска уже не существует
+var defaultClaimTemplate = claimTemplate{
+	storageClass: "replicated",
+	accessModes:  []interface{}{"ReadWriteMany"},
+	volumeMode:   "Block",
+}
+
+// snapshotEntry хранит снимок вместе с проектом, к которому он относится
+type snapshotEntry struct {
+	projectID string
+	snapshot  *model.Snapshot
+}
+
+// SnapshotManager управляет снимками дисков на основе VolumeSnapshot
+type SnapshotManager struct {
+	tenants       *TenantMap
+	dynamicClient dynamic.Interface
+	diskManager   *DiskManager
+	snapshotCache map[string]*snapshotEntry
+	cacheMutex    sync.RWMutex
+}
+
+// newSnapshotManager создает менеджер снимков и загружает существующие снимки
+func newSnapshotManager(dynamicClient dynamic.Interface, tenants *TenantMap, diskManager *DiskManager) *SnapshotManager {
+	manager := &SnapshotManager{
+		tenants:       tenants,
+		dynamicClient: dynamicClient,
+		diskManager:   diskManager,
+		snapshotCache: make(map[string]*snapshotEntry),
+	}
+
+	err := manager.loadSnapshots(context.Background())
+	if err != nil {
+		// Логируем ошибку, но продолжаем работу
+		fmt.Printf("Warning: Failed to initialize snapshot cache: %v\n", err)
+	}
+
+	return manager
+}
+
+// loadSnapshots заполняет кэш снимками тенантов, созданными через API
+func (m *SnapshotManager) loadSnapshots(ctx context.Context) error {
+	list, err := m.dynamicClient.Resource(VolumeSnapshotGVR).Namespace(m.tenants.WatchNamespace()).List(ctx, metav1.ListOptions{
+		LabelSelector: "created-by=graphql-api",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots: %v", err)
+	}
+
+	for i := range list.Items {
+		snapshotObj := &list.Items[i]
+		if !m.tenants.Owns(snapshotObj) {
+			continue
+		}
+
+		entry := m.convertToSnapshotEntry(snapshotObj)
+
+		m.cacheMutex.Lock()
+		_, duplicate := m.snapshotCache[entry.snapshot.SnapshotID]
+		if !duplicate {
+			m.snapshotCache[entry.snapshot.SnapshotID] = entry
+		}
+		m.cacheMutex.Unlock()
+
+		if duplicate {
+			fmt.Printf("Warning: snapshot %s exists in several namespaces, ignoring the one in %s\n", snapshotObj.GetName(), snapshotObj.GetNamespace())
+			continue
+		}
+
+		if entry.snapshot.Status == "CREATING" {
+			go m.waitForSnapshotReady(snapshotObj.GetNamespace(), entry.snapshot.SnapshotID)
+		}
+	}
+
+	return nil
+}
+
+// ListSnapshots возвращает снимки проекта; пустой diskID означает снимки всех дисков
+func (m *SnapshotManager) ListSnapshots(projectID, diskID string) []*model.Snapshot {
+	m.cacheMutex.RLock()
+	defer m.cacheMutex.RUnlock()
+
+	snapshots := make([]*model.Snapshot, 0, len(m.snapshotCache))
+	for _, entry := range m.snapshotCache {
+		if projectID != "" && entry.projectID != projectID {
+			continue
+		}
+		if diskID != "" && entry.snapshot.DiskID != diskID {
+			continue
+		}
+		snapshots = append(snapshots, entry.snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created < snapshots[j].Created
+	})
+
+	return snapshots
+}
+
+// GetSnapshot возвращает снимок по ID
+func (m *SnapshotManager) GetSnapshot(snapshotID string) (*model.Snapshot, error) {
+	entry, err := m.getEntry(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.snapshot, nil
+}
+
+// CreateSnapshot создает снимок PVC диска
+func (m *SnapshotManager) CreateSnapshot(ctx context.Context, diskID string) (*model.Snapshot, error) {
+	disk, err := m.diskManager.GetDisk(ctx, diskID)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, err := m.tenants.Namespace(disk.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotID := fmt.Sprintf("vms-%s", rand.String(8))
+
+	// Создаем объект ресурса VolumeSnapshot
+	snapshotObject := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "snapshot.storage.k8s.io/v1",
+			"kind":       "VolumeSnapshot",
+			"metadata": map[string]interface{}{
+				"name":      snapshotID,
+				"namespace": namespace,
+				"labels": map[string]interface{}{
+					"app":        "cozystack-vm",
+					"created-by": "graphql-api",
+					"project-id": disk.ProjectID,
+					"disk-id":    diskID,
+				},
+				"annotations": map[string]interface{}{
+					"size-gb": strconv.Itoa(int(disk.SizeGb)),
+				},
+			},
+			"spec": map[string]interface{}{
+				"source": map[string]interface{}{
+					"persistentVolumeClaimName": diskVolumePrefix + diskID,
+				},
+			},
+		},
+	}
+
+	created, err := m.dynamicClient.Resource(VolumeSnapshotGVR).Namespace(namespace).Create(ctx, snapshotObject, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %v", err)
+	}
+
+	entry := m.convertToSnapshotEntry(created)
+
+	// Сохраняем в кэш
+	m.cacheMutex.Lock()
+	m.snapshotCache[snapshotID] = entry
+	m.cacheMutex.Unlock()
+
+	// Запускаем горутину для отслеживания готовности снимка
+	go m.waitForSnapshotReady(namespace, snapshotID)
+
+	return entry.snapshot, nil
+}
+
+// DeleteSnapshot удаляет снимок
+func (m *SnapshotManager) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	entry, err := m.getEntry(snapshotID)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := m.tenants.Namespace(entry.projectID)
+	if err != nil {
+		return err
+	}
+
+	err = m.dynamicClient.Resource(VolumeSnapshotGVR).Namespace(namespace).Delete(ctx, snapshotID, metav1.DeleteOptions{})
+	// Если снимок не найден, считаем операцию успешной
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return fmt.Errorf("failed to delete snapshot: %v", err)
+	}
+
+	// Удаляем из кэша
+	m.cacheMutex.Lock()
+	delete(m.snapshotCache, snapshotID)
+	m.cacheMutex.Unlock()
+
+	return nil
+}
+
+// RestoreSnapshot пересоздает исходный диск из снимка. VMDisk удаляется вместе
+// с PVC, после чего создается заново поверх PVC, восстановленного из снимка
+func (m *SnapshotManager) RestoreSnapshot(ctx context.Context, snapshotID string) (*model.Disk, error) {
+	entry, err := m.getReadyEntry(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, err := m.tenants.Namespace(entry.projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Снимок восстанавливается только в диск своего тенанта
+	diskID := entry.snapshot.DiskID
+	diskObj, exists := m.diskManager.store.GetDisk(diskID)
+	if !exists || diskObj.GetNamespace() != namespace {
+		return nil, fmt.Errorf("disk %s no longer exists, create a new disk from the snapshot instead", diskID)
+	}
+
+	// Параметры VMDisk и PVC нужно запомнить до удаления
+	source, _, _ := unstructured.NestedMap(diskObj.Object, "spec", "source")
+	template := m.claimTemplate(ctx, namespace, diskID)
+
+	if err := m.diskManager.DeleteDisk(ctx, diskID); err != nil {
+		return nil, fmt.Errorf("failed to delete disk: %v", err)
+	}
+
+	if err := m.waitForClaimDeleted(ctx, namespace, diskVolumePrefix+diskID); err != nil {
+		return nil, err
+	}
+
+	return m.createDiskFromEntry(ctx, entry, diskID, int(entry.snapshot.SizeGb), source, template)
+}
+
+// CreateDiskFromSnapshot создает новый диск с содержимым снимка
+func (m *SnapshotManager) CreateDiskFromSnapshot(ctx context.Context, snapshotID, diskID string, sizeGB int) (*model.Disk, error) {
+	entry, err := m.getReadyEntry(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	if sizeGB < int(entry.snapshot.SizeGb) {
+		return nil, fmt.Errorf("disk size (%d GB) must not be smaller than snapshot size (%d GB)", sizeGB, entry.snapshot.SizeGb)
+	}
+
+	namespace, err := m.tenants.Namespace(entry.projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Если исходный диск еще существует, новый диск наследует его источник,
+	// по которому определяется, является ли диск загрузочным
+	source := map[string]interface{}{}
+	if diskObj, exists := m.diskManager.store.GetDisk(entry.snapshot.DiskID); exists && diskObj.GetNamespace() == namespace {
+		if diskSource, found, _ := unstructured.NestedMap(diskObj.Object, "spec", "source"); found {
+			source = diskSource
+		}
+	}
+
+	return m.createDiskFromEntry(ctx, entry, diskID, sizeGB, source, m.claimTemplate(ctx, namespace, entry.snapshot.DiskID))
+}
+
+// createDiskFromEntry создает PVC из снимка и VMDisk, который его использует
+func (m *SnapshotManager) createDiskFromEntry(ctx context.Context, entry *snapshotEntry, diskID string, sizeGB int, source map[string]interface{}, template claimTemplate) (*model.Disk, error) {
+	// PVC из снимка можно создать только в namespace снимка
+	namespace, err := m.tenants.Namespace(entry.projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	claimName := diskVolumePrefix + diskID
+
+	// PVC помечается как уже заполненный для DataVolume диска,
+	// поэтому CDI не будет импортировать в него образ
+	claimObject := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "PersistentVolumeClaim",
+			"metadata": map[string]interface{}{
+				"name":      claimName,
+				"namespace": namespace,
+				"labels": map[string]interface{}{
+					"app":        "cozystack-vm",
+					"created-by": "graphql-api",
+					"project-id": entry.projectID,
+				},
+				"annotations": map[string]interface{}{
+					populatedForAnnotation: claimName,
+				},
+			},
+			"spec": map[string]interface{}{
+				"accessModes":      template.accessModes,
+				"volumeMode":       template.volumeMode,
+				"storageClassName": template.storageClass,
+				"resources": map[string]interface{}{
+					"requests": map[string]interface{}{
+						"storage": fmt.Sprintf("%dGi", sizeGB),
+					},
+				},
+				"dataSource": map[string]interface{}{
+					"apiGroup": VolumeSnapshotGVR.Group,
+					"kind":     "VolumeSnapshot",
+					"name":     entry.snapshot.SnapshotID,
+				},
+			},
+		},
+	}
+
+	_, err = m.dynamicClient.Resource(PersistentVolumeClaimGVR).Namespace(namespace).Create(ctx, claimObject, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create volume from snapshot: %v", err)
+	}
+
+	diskObject := m.diskManager.newDiskObject(namespace, entry.projectID, diskID, sizeGB, source)
+	unstructured.SetNestedField(diskObject.Object, template.storageClass, "spec", "storageClass")
+
+	disk, err := m.diskManager.createDiskObject(ctx, diskObject)
+	if err != nil {
+		// Если создание диска не удалось, удаляем созданный PVC
+		deleteErr := m.dynamicClient.Resource(PersistentVolumeClaimGVR).Namespace(namespace).Delete(ctx, claimName, metav1.DeleteOptions{})
+		if deleteErr != nil {
+			fmt.Printf("Warning: failed to delete volume %s: %v\n", claimName, deleteErr)
+		}
+		return nil, err
+	}
+
+	return disk, nil
+}
+
+// claimTemplate возвращает параметры PVC диска или параметры по умолчанию
+func (m *SnapshotManager) claimTemplate(ctx context.Context, namespace, diskID string) claimTemplate {
+	template := defaultClaimTemplate
+
+	claimObj, err := m.dynamicClient.Resource(PersistentVolumeClaimGVR).Namespace(namespace).Get(ctx, diskVolumePrefix+diskID, metav1.GetOptions{})
+	if err != nil {
+		return template
+	}
+
+	if storageClass, found, _ := unstructured.NestedString(claimObj.Object, "spec", "storageClassName"); found {
+		template.storageClass = storageClass
+	}
+	if accessModes, found, _ := unstructured.NestedSlice(claimObj.Object, "spec", "accessModes"); found {
+		template.accessModes = accessModes
+	}
+	if volumeMode, found, _ := unstructured.NestedString(claimObj.Object, "spec", "volumeMode"); found {
+		template.volumeMode = volumeMode
+	}
+
+	return template
+}
+
+// waitForClaimDeleted ожидает, пока PVC будет удален
+func (m *SnapshotManager) waitForClaimDeleted(ctx context.Context, namespace, claimName string) error {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	timeout := time.After(volumeDeleteTimeout)
+
+	for {
+		_, err := m.dynamicClient.Resource(PersistentVolumeClaimGVR).Namespace(namespace).Get(ctx, claimName, metav1.GetOptions{})
+		if err != nil && strings.Contains(err.Error(), "not found") {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for volume %s to be deleted", claimName)
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitForSnapshotReady ожидает, пока снимок станет готов к использованию
+func (m *SnapshotManager) waitForSnapshotReady(namespace, snapshotID string) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	timeout := time.After(10 * time.Minute)
+
+	for {
+		select {
+		case <-timeout:
+			// Превышен таймаут ожидания
+			m.cacheMutex.Lock()
+			if entry, exists := m.snapshotCache[snapshotID]; exists {
+				entry.snapshot.Status = "ERROR"
+			}
+			m.cacheMutex.Unlock()
+			return
+		case <-ticker.C:
+			snapshotObj, err := m.dynamicClient.Resource(VolumeSnapshotGVR).Namespace(namespace).Get(context.Background(), snapshotID, metav1.GetOptions{})
+			if err != nil {
+				if strings.Contains(err.Error(), "not found") {
+					// Снимок был удален
+					m.cacheMutex.Lock()
+					delete(m.snapshotCache, snapshotID)
+					m.cacheMutex.Unlock()
+					return
+				}
+				continue
+			}
+
+			entry := m.convertToSnapshotEntry(snapshotObj)
+
+			// Обновляем снимок в кэше
+			m.cacheMutex.Lock()
+			m.snapshotCache[snapshotID] = entry
+			m.cacheMutex.Unlock()
+
+			// Если снимок готов или произошла ошибка, выходим из цикла
+			if entry.snapshot.Status != "CREATING" {
+				return
+			}
+		}
+	}
+}
+
+// getEntry возвращает снимок из кэша
+func (m *SnapshotManager) getEntry(snapshotID string) (*snapshotEntry, error) {
+	m.cacheMutex.RLock()
+	defer m.cacheMutex.RUnlock()
+
+	entry, exists := m.snapshotCache[snapshotID]
+	if !exists {
+		return nil, fmt.Errorf("snapshot not found: %s", snapshotID)
+	}
+
+	return entry, nil
+}
+
+// getReadyEntry возвращает снимок, из которого уже можно восстановить данные
+func (m *SnapshotManager) getReadyEntry(snapshotID string) (*snapshotEntry, error) {
+	entry, err := m.getEntry(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.snapshot.Status != "READY" {
+		return nil, fmt.Errorf("snapshot %s is not ready (status %s)", snapshotID, entry.snapshot.Status)
+	}
+
+	return entry, nil
+}
+
+// convertToSnapshotEntry преобразует VolumeSnapshot в модель снимка
+func (m *SnapshotManager) convertToSnapshotEntry(snapshotObj *unstructured.Unstructured) *snapshotEntry {
+	labels := snapshotObj.GetLabels()
+
+	// Размер берем из restoreSize, пока его нет - из размера диска на момент создания
+	sizeGB, _ := strconv.Atoi(snapshotObj.GetAnnotations()["size-gb"])
+	if restoreSize, found, _ := unstructured.NestedString(snapshotObj.Object, "status", "restoreSize"); found {
+		if quantity, err := resource.ParseQuantity(restoreSize); err == nil {
+			// Округляем вверх до целого числа гигабайт
+			sizeGB = int((quantity.Value() + (1<<30 - 1)) >> 30)
+		}
+	}
+
+	// Определяем статус снимка
+	status := "CREATING"
+	if ready, found, _ := unstructured.NestedBool(snapshotObj.Object, "status", "readyToUse"); found && ready {
+		status = "READY"
+	} else if _, found, _ := unstructured.NestedMap(snapshotObj.Object, "status", "error"); found {
+		status = "ERROR"
+	}
+
+	return &snapshotEntry{
+		projectID: labels["project-id"],
+		snapshot: &model.Snapshot{
+			SnapshotID: snapshotObj.GetName(),
+			ProjectID:  labels["project-id"],
+			DiskID:     labels["disk-id"],
+			SizeGb:     int32(sizeGB),
+			Status:     status,
+			Created:    snapshotObj.GetCreationTimestamp().Format(time.RFC3339),
+		},
+	}
+}
@@ -0,0 +1,182 @@
+package cozystack
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/cache"
+)
+
+// nameIndex - индекс объектов информера по имени. Информеры тенантов следят
+// за всеми namespace, а API обращается к ресурсам только по ID
+const nameIndex = "name"
+
+// TenantMap сопоставляет проектам namespace тенантов CozyStack. Без префикса и
+// явных сопоставлений все проекты находятся в одном namespace
+type TenantMap struct {
+	namespace string
+	prefix    string
+	explicit  map[string]string
+}
+
+// NewTenantMap создает сопоставление проектов и namespace. Проект из explicit
+// находится в указанном namespace, остальные - в namespace prefix+ID проекта.
+// Если не задано ни то ни другое, все проекты находятся в namespace
+func NewTenantMap(namespace, prefix string, explicit map[string]string) (*TenantMap, error) {
+	for projectID, tenantNamespace := range explicit {
+		if errs := validation.IsDNS1123Label(tenantNamespace); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid namespace %q for project %s: %s", tenantNamespace, projectID, strings.Join(errs, ", "))
+		}
+	}
+
+	return &TenantMap{
+		namespace: namespace,
+		prefix:    prefix,
+		explicit:  explicit,
+	}, nil
+}
+
+// multiTenant сообщает, распределены ли проекты по namespace тенантов
+func (t *TenantMap) multiTenant() bool {
+	return t.prefix != "" || len(t.explicit) > 0
+}
+
+// Namespace возвращает namespace тенанта проекта
+func (t *TenantMap) Namespace(projectID string) (string, error) {
+	if !t.multiTenant() {
+		return t.namespace, nil
+	}
+	if projectID == "" {
+		return "", fmt.Errorf("project_id must not be empty")
+	}
+
+	if tenantNamespace, exists := t.explicit[projectID]; exists {
+		return tenantNamespace, nil
+	}
+	if t.prefix == "" {
+		return "", fmt.Errorf("project %s has no tenant namespace", projectID)
+	}
+
+	tenantNamespace := t.prefix + projectID
+	if errs := validation.IsDNS1123Label(tenantNamespace); len(errs) > 0 {
+		return "", fmt.Errorf("project %s cannot be mapped to a namespace: %s", projectID, strings.Join(errs, ", "))
+	}
+
+	return tenantNamespace, nil
+}
+
+// WatchNamespace возвращает namespace, за которым следят информеры: namespace
+// менеджера или все namespace, если проекты распределены по тенантам
+func (t *TenantMap) WatchNamespace() string {
+	if !t.multiTenant() {
+		return t.namespace
+	}
+
+	return metav1.NamespaceAll
+}
+
+// Owns сообщает, находится ли объект в namespace тенанта своего проекта.
+// Объекты, метка project-id которых указывает на другой тенант, через API не
+// видны, поэтому тенант не может подложить ресурс в чужой проект
+func (t *TenantMap) Owns(obj metav1.Object) bool {
+	if !t.multiTenant() {
+		return obj.GetNamespace() == t.namespace
+	}
+
+	tenantNamespace, err := t.Namespace(obj.GetLabels()["project-id"])
+	return err == nil && tenantNamespace == obj.GetNamespace()
+}
+
+// ownedEvents пропускает к обработчику только события объектов тенантов.
+// Объект, перенесенный в чужой проект, обработчик получает как удаленный
+func (t *TenantMap) ownedEvents(handler cache.ResourceEventHandler) cache.ResourceEventHandler {
+	return cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj interface{}) bool {
+			u, ok := objectFromEvent(obj)
+			return ok && t.Owns(u)
+		},
+		Handler: handler,
+	}
+}
+
+// ownedByName ищет объект тенанта по имени в индексе информера. Одноименные
+// объекты разных тенантов не отличить по ID, поэтому такой объект не находится
+func (t *TenantMap) ownedByName(informer cache.SharedIndexInformer, name string) (*unstructured.Unstructured, bool) {
+	items, err := informer.GetIndexer().ByIndex(nameIndex, name)
+	if err != nil {
+		return nil, false
+	}
+
+	var found *unstructured.Unstructured
+	for _, item := range items {
+		obj, ok := item.(*unstructured.Unstructured)
+		if !ok || !t.Owns(obj) {
+			continue
+		}
+		if found != nil {
+			fmt.Printf("Warning: %s exists in namespaces %s and %s, ignoring it\n", name, found.GetNamespace(), obj.GetNamespace())
+			return nil, false
+		}
+		found = obj
+	}
+
+	return found, found != nil
+}
+
+// listOwned возвращает объекты тенантов из памяти информера
+func (t *TenantMap) listOwned(informer cache.SharedIndexInformer) []*unstructured.Unstructured {
+	objects := listObjects(informer)
+
+	owned := objects[:0]
+	for _, obj := range objects {
+		if t.Owns(obj) {
+			owned = append(owned, obj)
+		}
+	}
+
+	return owned
+}
+
+// addNameIndex добавляет в информер индекс по имени объекта
+func addNameIndex(informer cache.SharedIndexInformer) {
+	err := informer.AddIndexers(cache.Indexers{
+		nameIndex: func(obj interface{}) ([]string, error) {
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				return nil, nil
+			}
+			return []string{accessor.GetName()}, nil
+		},
+	})
+	if err != nil {
+		fmt.Printf("Warning: failed to add name index: %v\n", err)
+	}
+}
+
+// ParseTenantNamespaces разбирает сопоставления вида
+// "project-a=tenant-a,project-b=tenant-b"
+func ParseTenantNamespaces(value string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		projectID, tenantNamespace, found := strings.Cut(pair, "=")
+		projectID, tenantNamespace = strings.TrimSpace(projectID), strings.TrimSpace(tenantNamespace)
+		if !found || projectID == "" || tenantNamespace == "" {
+			return nil, fmt.Errorf("invalid tenant namespace mapping %q, expected project=namespace", pair)
+		}
+		if _, exists := mapping[projectID]; exists {
+			return nil, fmt.Errorf("project %s is mapped to more than one namespace", projectID)
+		}
+		mapping[projectID] = tenantNamespace
+	}
+
+	return mapping, nil
+}
@@ -0,0 +1,110 @@
+package diskimage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// FormatQCOW2 - образ диска QEMU
+	FormatQCOW2 = "qcow2"
+	// FormatISO - образ оптического диска ISO 9660
+	FormatISO = "iso"
+)
+
+const (
+	// qcow2Magic - сигнатура в начале файла qcow2
+	qcow2Magic = "QFI\xfb"
+	// qcow2SizeOffset - смещение виртуального размера диска в заголовке qcow2
+	qcow2SizeOffset = 24
+
+	// isoMagic - идентификатор первичного дескриптора тома ISO 9660
+	isoMagic = "CD001"
+	// isoMagicOffset - смещение идентификатора в шестнадцатом секторе
+	isoMagicOffset = 16*2048 + 1
+
+	// peekSize - сколько байт нужно прочитать для определения формата
+	peekSize = isoMagicOffset + len(isoMagic)
+)
+
+// Info описывает загружаемый образ
+type Info struct {
+	Format string
+	// VirtualSize - размер диска, который нужен для образа, в байтах
+	VirtualSize int64
+}
+
+// Optical сообщает, подключается ли образ как CD-ROM
+func (i *Info) Optical() bool {
+	return i.Format == FormatISO
+}
+
+// SizeGB возвращает размер диска для образа в гигабайтах с округлением вверх
+func (i *Info) SizeGB() int {
+	return int((i.VirtualSize + (1 << 30) - 1) >> 30)
+}
+
+// Detect определяет формат образа по его содержимому. Возвращаемый reader
+// отдает образ с начала, включая прочитанный для проверки заголовок
+func Detect(r io.Reader, size int64) (*Info, io.Reader, error) {
+	buffered := bufio.NewReaderSize(r, peekSize)
+
+	header, err := buffered.Peek(peekSize)
+	if err != nil && err != io.EOF {
+		return nil, nil, fmt.Errorf("failed to read image header: %v", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte(qcow2Magic)) && len(header) >= qcow2SizeOffset+8:
+		virtualSize := int64(binary.BigEndian.Uint64(header[qcow2SizeOffset:]))
+		if virtualSize <= 0 {
+			return nil, nil, fmt.Errorf("qcow2 image has invalid virtual size")
+		}
+		return &Info{Format: FormatQCOW2, VirtualSize: virtualSize}, buffered, nil
+	case len(header) == peekSize && string(header[isoMagicOffset:]) == isoMagic:
+		return &Info{Format: FormatISO, VirtualSize: size}, buffered, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported image format: expected %s or %s", FormatQCOW2, FormatISO)
+	}
+}
+
+// ProgressReader считает прочитанные байты и вызывает report при изменении
+// процента загрузки
+type ProgressReader struct {
+	reader  io.Reader
+	total   int64
+	read    int64
+	percent int32
+	report  func(percent int32)
+}
+
+// NewProgressReader создает reader, который сообщает о прогрессе чтения total байт
+func NewProgressReader(r io.Reader, total int64, report func(percent int32)) *ProgressReader {
+	return &ProgressReader{
+		reader: r,
+		total:  total,
+		report: report,
+	}
+}
+
+// Read читает данные и сообщает о прогрессе
+func (p *ProgressReader) Read(buf []byte) (int, error) {
+	n, err := p.reader.Read(buf)
+	p.read += int64(n)
+
+	if p.total > 0 {
+		percent := int32(p.read * 100 / p.total)
+		if percent > 100 {
+			percent = 100
+		}
+		if percent != p.percent {
+			p.percent = percent
+			p.report(percent)
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,173 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"gqlfed/instances/graph/model"
+)
+
+// DefaultBufferSize определяет размер буфера подписчика по умолчанию
+const DefaultBufferSize = 64
+
+// Resource определяет, к какому ресурсу относится событие
+type Resource string
+
+const (
+	// ResourceInstance обозначает событие виртуальной машины
+	ResourceInstance Resource = "INSTANCE"
+	// ResourceDisk обозначает событие виртуального диска
+	ResourceDisk Resource = "DISK"
+	// ResourceImageImport обозначает событие импорта образа
+	ResourceImageImport Resource = "IMAGE_IMPORT"
+)
+
+// Event описывает изменение состояния, о котором нужно сообщить подписчикам
+type Event struct {
+	Type      model.EventType
+	Resource  Resource
+	ProjectID string
+	// Instance заполняется для ResourceInstance; для DELETED содержит последнее известное состояние
+	Instance *model.Instance
+	// Disk заполняется для ResourceDisk; для DELETED содержит последнее известное состояние
+	Disk *model.Disk
+	// ImageImport заполняется для ResourceImageImport
+	ImageImport *model.ImageImport
+	// ChangedFields содержит имена изменившихся GraphQL-полей для MODIFIED
+	ChangedFields []string
+}
+
+// NewInstanceEvent создает событие инстанса
+func NewInstanceEvent(eventType model.EventType, instance *model.Instance, changedFields []string) Event {
+	return Event{
+		Type:          eventType,
+		Resource:      ResourceInstance,
+		ProjectID:     instance.ProjectID,
+		Instance:      instance,
+		ChangedFields: changedFields,
+	}
+}
+
+// NewDiskEvent создает событие диска
+func NewDiskEvent(eventType model.EventType, projectID string, disk *model.Disk, changedFields []string) Event {
+	return Event{
+		Type:          eventType,
+		Resource:      ResourceDisk,
+		ProjectID:     projectID,
+		Disk:          disk,
+		ChangedFields: changedFields,
+	}
+}
+
+// NewImageImportEvent создает событие импорта образа
+func NewImageImportEvent(eventType model.EventType, imageImport *model.ImageImport, changedFields []string) Event {
+	return Event{
+		Type:          eventType,
+		Resource:      ResourceImageImport,
+		ProjectID:     imageImport.ProjectID,
+		ImageImport:   imageImport,
+		ChangedFields: changedFields,
+	}
+}
+
+// Broker рассылает события всем подписчикам. У каждого подписчика свой буфер;
+// подписчик, который не успевает вычитывать события, отключается, чтобы
+// не задерживать остальных
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+	bufferSize  int
+	closed      bool
+}
+
+// NewBroker создает брокер с указанным размером буфера подписчика
+func NewBroker(bufferSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	return &Broker{
+		subscribers: make(map[chan Event]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+// Subscribe регистрирует нового подписчика. Канал закрывается, когда
+// отменяется ctx, когда подписчик отключен за отставание или когда брокер закрыт
+func (b *Broker) Subscribe(ctx context.Context) <-chan Event {
+	ch := make(chan Event, b.bufferSize)
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(ch)
+		return ch
+	}
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	// Отписываемся при завершении подписки
+	go func() {
+		<-ctx.Done()
+		b.unsubscribe(ch)
+	}()
+
+	return ch
+}
+
+// Publish отправляет событие всем подписчикам, не блокируясь
+func (b *Broker) Publish(event Event) {
+	var slow []chan Event
+
+	b.mu.RLock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+			// Событие доставлено
+		default:
+			// Буфер подписчика переполнен
+			slow = append(slow, ch)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, ch := range slow {
+		b.unsubscribe(ch)
+	}
+}
+
+// SubscriberCount возвращает количество активных подписчиков
+func (b *Broker) SubscriberCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.subscribers)
+}
+
+// Close отключает всех подписчиков; последующие подписки сразу закрываются
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// unsubscribe удаляет подписчика и закрывает его канал
+func (b *Broker) unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.subscribers[ch]; !exists {
+		return
+	}
+	delete(b.subscribers, ch)
+	close(ch)
+}
@@ -0,0 +1,125 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// receive reads the next event or fails after a timeout. ok is false once
+// the channel is closed.
+func receive(t *testing.T, ch <-chan Event) (Event, bool) {
+	t.Helper()
+
+	select {
+	case event, ok := <-ch:
+		return event, ok
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return Event{}, false
+	}
+}
+
+func TestBrokerFanOut(t *testing.T) {
+	broker := NewBroker(4)
+	defer broker.Close()
+
+	first := broker.Subscribe(context.Background())
+	second := broker.Subscribe(context.Background())
+	if got := broker.SubscriberCount(); got != 2 {
+		t.Fatalf("SubscriberCount() = %d, want 2", got)
+	}
+
+	broker.Publish(Event{ProjectID: "project-1"})
+	broker.Publish(Event{ProjectID: "project-2"})
+
+	for _, ch := range []<-chan Event{first, second} {
+		for _, want := range []string{"project-1", "project-2"} {
+			event, ok := receive(t, ch)
+			if !ok || event.ProjectID != want {
+				t.Fatalf("received %+v, %v, want project %s", event, ok, want)
+			}
+		}
+	}
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+	broker := NewBroker(1)
+	defer broker.Close()
+
+	fast := broker.Subscribe(context.Background())
+	slow := broker.Subscribe(context.Background())
+
+	broker.Publish(Event{ProjectID: "first"})
+	if event, ok := receive(t, fast); !ok || event.ProjectID != "first" {
+		t.Fatalf("fast subscriber received %+v, %v", event, ok)
+	}
+
+	// The slow subscriber still holds the first event, so the second one
+	// does not fit into its buffer.
+	broker.Publish(Event{ProjectID: "second"})
+	if event, ok := receive(t, fast); !ok || event.ProjectID != "second" {
+		t.Fatalf("fast subscriber received %+v, %v", event, ok)
+	}
+
+	if event, ok := receive(t, slow); !ok || event.ProjectID != "first" {
+		t.Fatalf("slow subscriber received %+v, %v", event, ok)
+	}
+	if _, ok := receive(t, slow); ok {
+		t.Fatal("slow subscriber was not disconnected")
+	}
+	if got := broker.SubscriberCount(); got != 1 {
+		t.Errorf("SubscriberCount() = %d, want 1", got)
+	}
+}
+
+func TestBrokerUnsubscribesOnCancel(t *testing.T) {
+	broker := NewBroker(1)
+	defer broker.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := broker.Subscribe(ctx)
+	cancel()
+
+	if _, ok := receive(t, ch); ok {
+		t.Fatal("channel is open after the context was cancelled")
+	}
+	if got := broker.SubscriberCount(); got != 0 {
+		t.Errorf("SubscriberCount() = %d, want 0", got)
+	}
+}
+
+func TestBrokerClose(t *testing.T) {
+	broker := NewBroker(1)
+	ch := broker.Subscribe(context.Background())
+
+	broker.Close()
+	broker.Close()
+
+	if _, ok := receive(t, ch); ok {
+		t.Fatal("channel is open after Close")
+	}
+	if _, ok := receive(t, broker.Subscribe(context.Background())); ok {
+		t.Fatal("subscription after Close is open")
+	}
+
+	// Publishing after Close must not panic.
+	broker.Publish(Event{ProjectID: "project-1"})
+}
+
+func TestNewBrokerDefaultBufferSize(t *testing.T) {
+	tests := []struct {
+		bufferSize int
+		want       int
+	}{
+		{0, DefaultBufferSize},
+		{-1, DefaultBufferSize},
+		{8, 8},
+	}
+
+	for _, tt := range tests {
+		if got := NewBroker(tt.bufferSize).bufferSize; got != tt.want {
+			t.Errorf("NewBroker(%d) buffer size = %d, want %d", tt.bufferSize, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package events
+
+import (
+	"slices"
+
+	"gqlfed/instances/graph/model"
+)
+
+// InstanceChangedFields возвращает имена GraphQL-полей, которые отличаются
+// у двух версий инстанса. Поле updated не учитывается, так как меняется при
+// каждой конвертации
+func InstanceChangedFields(old, new *model.Instance) []string {
+	changed := []string{}
+
+	if old.Name != new.Name {
+		changed = append(changed, "name")
+	}
+	if old.Status != new.Status {
+		changed = append(changed, "status")
+	}
+	if old.KeyName != new.KeyName {
+		changed = append(changed, "key_name")
+	}
+	if flavorName(old.Flavor) != flavorName(new.Flavor) {
+		changed = append(changed, "flavor")
+	}
+	if old.Locked != new.Locked {
+		changed = append(changed, "locked")
+	}
+	if old.Loading != new.Loading {
+		changed = append(changed, "loading")
+	}
+	if old.PowerState != new.PowerState {
+		changed = append(changed, "power_state")
+	}
+	if old.IPV4 != new.IPV4 {
+		changed = append(changed, "ipV4")
+	}
+	if !sameDisks(old.AttachedDisks, new.AttachedDisks) {
+		changed = append(changed, "attachedDisks")
+	}
+	if !sameNetworks(old.AttachedNetworks, new.AttachedNetworks) {
+		changed = append(changed, "attachedNetworks")
+	}
+	if old.ExternalMethod != new.ExternalMethod {
+		changed = append(changed, "external_method")
+	}
+	if !slices.Equal(old.ExternalPorts, new.ExternalPorts) {
+		changed = append(changed, "external_ports")
+	}
+	if !slices.Equal(old.ExternalAddresses, new.ExternalAddresses) {
+		changed = append(changed, "external_addresses")
+	}
+	if costValue(old.Cost) != costValue(new.Cost) {
+		changed = append(changed, "cost")
+	}
+
+	return changed
+}
+
+// DiskChangedFields возвращает имена GraphQL-полей, которые отличаются у двух версий диска
+func DiskChangedFields(old, new *model.Disk) []string {
+	changed := []string{}
+
+	if old.SizeGb != new.SizeGb {
+		changed = append(changed, "size_gb")
+	}
+	if old.Bootable != new.Bootable {
+		changed = append(changed, "bootable")
+	}
+	if old.Status != new.Status {
+		changed = append(changed, "status")
+	}
+	if imageID(old.Image) != imageID(new.Image) {
+		changed = append(changed, "image")
+	}
+
+	return changed
+}
+
+// ImageImportChangedFields возвращает имена GraphQL-полей, которые отличаются
+// у двух версий импорта образа
+func ImageImportChangedFields(old, new *model.ImageImport) []string {
+	changed := []string{}
+
+	if old.SizeGb != new.SizeGb {
+		changed = append(changed, "size_gb")
+	}
+	if old.Status != new.Status {
+		changed = append(changed, "status")
+	}
+	if old.Progress != new.Progress {
+		changed = append(changed, "progress")
+	}
+	if stringValue(old.Error) != stringValue(new.Error) {
+		changed = append(changed, "error")
+	}
+
+	return changed
+}
+
+// flavorName возвращает имя flavor независимо от его категории
+func flavorName(flavor model.Flavor) string {
+	switch f := flavor.(type) {
+	case *model.BaseFlavor:
+		return f.OriginalName
+	case *model.HiFreqFlavor:
+		return f.OriginalName
+	case *model.PremiumFlavor:
+		return f.OriginalName
+	case *model.ProFlavor:
+		return f.OriginalName
+	}
+	return ""
+}
+
+// imageID возвращает ID образа или пустую строку
+func imageID(image *model.Image) string {
+	if image == nil {
+		return ""
+	}
+	return image.ImageID
+}
+
+// stringValue возвращает значение строки или пустую строку
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// costValue возвращает стоимость или нулевую стоимость, если ее нет
+func costValue(cost *model.Cost) model.Cost {
+	if cost == nil {
+		return model.Cost{}
+	}
+	return *cost
+}
+
+// sameDisks сравнивает подключенные диски по ID, размеру и статусу
+func sameDisks(a, b []*model.Disk) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].DiskID != b[i].DiskID || a[i].SizeGb != b[i].SizeGb || a[i].Status != b[i].Status {
+			return false
+		}
+	}
+	return true
+}
+
+// sameNetworks сравнивает подключенные сети по ID и адресу
+func sameNetworks(a, b []*model.Network) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].NetworkID != b[i].NetworkID || a[i].IPV4 != b[i].IPV4 {
+			return false
+		}
+	}
+	return true
+}
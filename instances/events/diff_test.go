@@ -0,0 +1,96 @@
+package events
+
+import (
+	"slices"
+	"testing"
+
+	"gqlfed/instances/graph/model"
+)
+
+func TestInstanceChangedFields(t *testing.T) {
+	base := func() *model.Instance {
+		return &model.Instance{
+			InstanceID:       "inst-1",
+			ProjectID:        "project-1",
+			Name:             "web",
+			Status:           "ACTIVE",
+			Updated:          "2024-01-01T00:00:00Z",
+			KeyName:          "laptop",
+			Flavor:           &model.BaseFlavor{OriginalName: "base-1"},
+			PowerState:       "ACTIVE",
+			IPV4:             "10.0.0.2",
+			AttachedDisks:    []*model.Disk{{DiskID: "disk-1", SizeGb: 20, Status: "active"}},
+			AttachedNetworks: []*model.Network{{NetworkID: "net-1", IPV4: "10.0.0.2"}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(instance *model.Instance)
+		want   []string
+	}{
+		{"unchanged", func(*model.Instance) {}, []string{}},
+		{"updated is ignored", func(i *model.Instance) { i.Updated = "2024-01-02T00:00:00Z" }, []string{}},
+		{"name", func(i *model.Instance) { i.Name = "db" }, []string{"name"}},
+		{"status and power state", func(i *model.Instance) { i.Status = "STOPPED"; i.PowerState = "STOPPED" }, []string{"status", "power_state"}},
+		{"key name", func(i *model.Instance) { i.KeyName = "" }, []string{"key_name"}},
+		{"flavor", func(i *model.Instance) { i.Flavor = &model.ProFlavor{OriginalName: "pro-1"} }, []string{"flavor"}},
+		{"same flavor name in another object", func(i *model.Instance) { i.Flavor = &model.BaseFlavor{OriginalName: "base-1"} }, []string{}},
+		{"locked and loading", func(i *model.Instance) { i.Locked = true; i.Loading = true }, []string{"locked", "loading"}},
+		{"address", func(i *model.Instance) { i.IPV4 = "10.0.0.3" }, []string{"ipV4"}},
+		{"disk resized", func(i *model.Instance) { i.AttachedDisks[0].SizeGb = 40 }, []string{"attachedDisks"}},
+		{"disk attached", func(i *model.Instance) {
+			i.AttachedDisks = append(i.AttachedDisks, &model.Disk{DiskID: "disk-2"})
+		}, []string{"attachedDisks"}},
+		{"network address", func(i *model.Instance) { i.AttachedNetworks[0].IPV4 = "10.0.0.3" }, []string{"attachedNetworks"}},
+		{"cost", func(i *model.Instance) { i.Cost = &model.Cost{Currency: "RUB", Monthly: 1500} }, []string{"cost"}},
+		{"disk resized with cost", func(i *model.Instance) {
+			i.AttachedDisks[0].SizeGb = 40
+			i.Cost = &model.Cost{Currency: "RUB", DiskMonthly: 320}
+		}, []string{"attachedDisks", "cost"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := base()
+			tt.change(changed)
+			if got := InstanceChangedFields(base(), changed); !slices.Equal(got, tt.want) {
+				t.Errorf("InstanceChangedFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiskChangedFields(t *testing.T) {
+	base := func() *model.Disk {
+		return &model.Disk{
+			DiskID:   "disk-1",
+			SizeGb:   20,
+			Status:   "active",
+			Bootable: true,
+			Image:    &model.Image{ImageID: "ubuntu"},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(disk *model.Disk)
+		want   []string
+	}{
+		{"unchanged", func(*model.Disk) {}, []string{}},
+		{"resized", func(d *model.Disk) { d.SizeGb = 40 }, []string{"size_gb"}},
+		{"status", func(d *model.Disk) { d.Status = "error" }, []string{"status"}},
+		{"image removed", func(d *model.Disk) { d.Image = nil; d.Bootable = false }, []string{"bootable", "image"}},
+		{"same image in another object", func(d *model.Disk) { d.Image = &model.Image{ImageID: "ubuntu"} }, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := base()
+			tt.change(changed)
+			if got := DiskChangedFields(base(), changed); !slices.Equal(got, tt.want) {
+				t.Errorf("DiskChangedFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
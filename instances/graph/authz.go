@@ -0,0 +1,255 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"gqlfed/instances/auth"
+	"gqlfed/instances/graph/model"
+	"gqlfed/instances/rbac"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// CodeForbidden is the GraphQL error code returned when the caller lacks the
+// role a field requires.
+const CodeForbidden = "FORBIDDEN"
+
+// HasRole implements the @hasRole directive. The projects a field touches are
+// taken from its project_id and input arguments and from the resources its
+// *_id arguments refer to; the caller needs the role in every one of them.
+// Fields that name no project, such as getNetworkList without a project_id,
+// filter their results in the resolver instead.
+func (r *Resolver) HasRole(ctx context.Context, obj any, next graphql.Resolver, role model.Role) (any, error) {
+	if _, ok := auth.ClaimsFromContext(ctx); !ok {
+		// Authentication is disabled
+		return next(ctx)
+	}
+
+	// A resource that cannot be found is refused like one the caller may not
+	// access, so the error does not tell whether it exists.
+	projectIDs, err := r.fieldProjects(ctx)
+	if err != nil {
+		return nil, roleRequired(role)
+	}
+
+	for _, projectID := range projectIDs {
+		if err := r.requireRole(ctx, projectID, role); err != nil {
+			return nil, err
+		}
+	}
+
+	return next(ctx)
+}
+
+// fieldProjects collects the projects named by the arguments of the field
+// being resolved.
+func (r *Resolver) fieldProjects(ctx context.Context) ([]string, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil {
+		return nil, nil
+	}
+
+	var projectIDs []string
+	add := func(projectID string) {
+		for _, seen := range projectIDs {
+			if seen == projectID {
+				return
+			}
+		}
+		projectIDs = append(projectIDs, projectID)
+	}
+
+	for name, value := range fc.Args {
+		id, ok := stringArg(value)
+		if name != "input" && !ok {
+			continue
+		}
+
+		switch name {
+		case "project_id":
+			add(id)
+		case "input":
+			if projectID, ok := inputProject(value); ok {
+				add(projectID)
+			}
+		case "instance_id":
+			instance, err := r.Backend.GetInstanceItem(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			add(instance.ProjectID)
+		case "disk_id":
+			disk, err := r.Backend.GetDisk(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			add(disk.ProjectID)
+		case "snapshot_id":
+			snapshot, err := r.Backend.GetSnapshot(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			add(snapshot.ProjectID)
+		case "network_id":
+			networks, err := r.Backend.GetNetworksByIDs(ctx, []string{id})
+			if err != nil {
+				return nil, err
+			}
+			if networks[0] == nil {
+				return nil, fmt.Errorf("network not found: %s", id)
+			}
+			add(networks[0].ProjectID)
+		case "security_group_id":
+			group, err := r.Backend.GetSecurityGroup(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			add(group.ProjectID)
+		}
+	}
+
+	return projectIDs, nil
+}
+
+// requireRole returns a FORBIDDEN error unless the caller holds at least role
+// in the project.
+func (r *Resolver) requireRole(ctx context.Context, projectID string, role model.Role) error {
+	have, ok, err := r.roleIn(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	if !ok || !rbac.Allows(have, role) {
+		return roleRequired(role)
+	}
+	return nil
+}
+
+// roleRequired returns the FORBIDDEN error of a field that needs role. It
+// names neither the project nor the resource.
+func roleRequired(role model.Role) *gqlerror.Error {
+	return forbidden(fmt.Errorf("%s role is required", role))
+}
+
+// roleIn returns the caller's role in the project. Without claims, that is
+// with authentication disabled, every caller is an admin.
+func (r *Resolver) roleIn(ctx context.Context, projectID string) (model.Role, bool, error) {
+	claims, ok := auth.ClaimsFromContext(ctx)
+	if !ok {
+		return model.RoleAdmin, true, nil
+	}
+	if projectID == "" {
+		return "", false, nil
+	}
+
+	bindings, err := r.Backend.GetRoleBindings(ctx, projectID)
+	if err != nil {
+		return "", false, err
+	}
+
+	member := false
+	for _, id := range claims.ProjectIDs {
+		if id == projectID {
+			member = true
+			break
+		}
+	}
+
+	role, ok := rbac.Role(bindings, claims.UserID, member)
+	return role, ok, nil
+}
+
+// canView reports whether the caller may see resources of the project. Lookup
+// errors are treated as no access.
+func (r *Resolver) canView(ctx context.Context, projectID string) bool {
+	role, ok, err := r.roleIn(ctx, projectID)
+	return err == nil && ok && rbac.Allows(role, model.RoleViewer)
+}
+
+// visibleTo returns the items whose project the caller may view, asking for
+// the role in each project only once.
+func visibleTo[T any](ctx context.Context, r *Resolver, items []T, projectOf func(T) string) []T {
+	allowed := map[string]bool{}
+	visible := make([]T, 0, len(items))
+	for _, item := range items {
+		projectID := projectOf(item)
+		ok, seen := allowed[projectID]
+		if !seen {
+			ok = r.canView(ctx, projectID)
+			allowed[projectID] = ok
+		}
+		if ok {
+			visible = append(visible, item)
+		}
+	}
+	return visible
+}
+
+// visibleInstances drops the instances of projects the caller may not view.
+func (r *Resolver) visibleInstances(ctx context.Context, instances []*model.Instance) []*model.Instance {
+	return visibleTo(ctx, r, instances, func(instance *model.Instance) string { return instance.ProjectID })
+}
+
+// visibleKeyInstances drops from every key the instances of projects the
+// caller may not view. A key without a project may be injected anywhere.
+func (r *Resolver) visibleKeyInstances(ctx context.Context, keys []*model.SSHKey) []*model.SSHKey {
+	for _, key := range keys {
+		if key != nil {
+			key.Instances = r.visibleInstances(ctx, key.Instances)
+		}
+	}
+	return keys
+}
+
+// ownUserID returns the user the caller may manage SSH keys for when no
+// project is given: the caller themselves. An explicit user_id of someone
+// else is refused. Without claims the requested user is returned unchanged.
+func ownUserID(ctx context.Context, userID *string) (*string, error) {
+	claims, ok := auth.ClaimsFromContext(ctx)
+	if !ok {
+		return userID, nil
+	}
+	if userID != nil && *userID != claims.UserID {
+		return nil, forbidden(fmt.Errorf("SSH keys of another user are not accessible"))
+	}
+	return &claims.UserID, nil
+}
+
+// stringArg unwraps String and nullable String argument values.
+func stringArg(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case *string:
+		if v != nil {
+			return *v, true
+		}
+	}
+	return "", false
+}
+
+// inputProject returns the project of a create mutation input.
+func inputProject(value any) (string, bool) {
+	switch input := value.(type) {
+	case model.NewInstanceInput:
+		return input.ProjectID, true
+	case model.NewDiskInput:
+		return input.ProjectID, true
+	case model.NewNetworkInput:
+		return input.ProjectID, true
+	case model.NewSecurityGroupInput:
+		return input.ProjectID, true
+	case model.NewSSHKeyInput:
+		return stringArg(input.ProjectID)
+	}
+	return "", false
+}
+
+// forbidden wraps an authorization failure into a GraphQL error.
+func forbidden(err error) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message:    err.Error(),
+		Extensions: map[string]interface{}{"code": CodeForbidden},
+	}
+}
@@ -0,0 +1,244 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"gqlfed/instances/auth"
+	"gqlfed/instances/graph/model"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// authzBackend serves the role bindings and instances the authorization
+// checks look up. Other Backend methods are not implemented.
+type authzBackend struct {
+	Backend
+	bindings  map[string][]*model.RoleBinding
+	instances map[string]*model.Instance
+	// lookups counts GetRoleBindings calls per project.
+	lookups map[string]int
+}
+
+func newAuthzBackend() *authzBackend {
+	return &authzBackend{
+		bindings: map[string][]*model.RoleBinding{
+			"proj-a": {
+				{ProjectID: "proj-a", UserID: "alice", Role: model.RoleOperator},
+				{ProjectID: "proj-a", UserID: "bob", Role: model.RoleAdmin},
+			},
+			"proj-b": {
+				{ProjectID: "proj-b", UserID: "bob", Role: model.RoleAdmin},
+			},
+		},
+		instances: map[string]*model.Instance{
+			"inst-a": {InstanceID: "inst-a", ProjectID: "proj-a"},
+			"inst-b": {InstanceID: "inst-b", ProjectID: "proj-b"},
+		},
+		lookups: map[string]int{},
+	}
+}
+
+func (b *authzBackend) GetRoleBindings(ctx context.Context, projectID string) ([]*model.RoleBinding, error) {
+	b.lookups[projectID]++
+	return b.bindings[projectID], nil
+}
+
+func (b *authzBackend) GetInstanceItem(ctx context.Context, instanceID string) (*model.Instance, error) {
+	instance, exists := b.instances[instanceID]
+	if !exists {
+		return nil, fmt.Errorf("instance not found: %s", instanceID)
+	}
+	return instance, nil
+}
+
+// aliceContext returns a context of a caller who is a member of proj-a and
+// of proj-new, which has no role bindings yet.
+func aliceContext() context.Context {
+	return auth.WithClaims(context.Background(), &auth.Claims{UserID: "alice", ProjectIDs: []string{"proj-a", "proj-new"}})
+}
+
+func TestHasRole(t *testing.T) {
+	var noProject *string
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		args     map[string]any
+		role     model.Role
+		wantErr  bool
+		wantCode string
+	}{
+		{
+			name: "authentication disabled",
+			ctx:  context.Background(),
+			args: map[string]any{"project_id": "proj-b"},
+			role: model.RoleAdmin,
+		},
+		{
+			name: "role in project",
+			ctx:  aliceContext(),
+			args: map[string]any{"project_id": "proj-a"},
+			role: model.RoleOperator,
+		},
+		{
+			name:     "role too low",
+			ctx:      aliceContext(),
+			args:     map[string]any{"project_id": "proj-a"},
+			role:     model.RoleAdmin,
+			wantErr:  true,
+			wantCode: CodeForbidden,
+		},
+		{
+			name:     "no binding in project",
+			ctx:      aliceContext(),
+			args:     map[string]any{"project_id": "proj-b"},
+			role:     model.RoleViewer,
+			wantErr:  true,
+			wantCode: CodeForbidden,
+		},
+		{
+			name: "member of project without bindings",
+			ctx:  aliceContext(),
+			args: map[string]any{"project_id": "proj-new"},
+			role: model.RoleAdmin,
+		},
+		{
+			name:     "not a member of project without bindings",
+			ctx:      aliceContext(),
+			args:     map[string]any{"project_id": "proj-other"},
+			role:     model.RoleViewer,
+			wantErr:  true,
+			wantCode: CodeForbidden,
+		},
+		{
+			name: "no project named",
+			ctx:  aliceContext(),
+			args: map[string]any{"project_id": noProject},
+			role: model.RoleAdmin,
+		},
+		{
+			name: "project of instance",
+			ctx:  aliceContext(),
+			args: map[string]any{"instance_id": "inst-a"},
+			role: model.RoleOperator,
+		},
+		{
+			name:     "instance of another project",
+			ctx:      aliceContext(),
+			args:     map[string]any{"instance_id": "inst-b"},
+			role:     model.RoleViewer,
+			wantErr:  true,
+			wantCode: CodeForbidden,
+		},
+		{
+			name:     "unknown instance",
+			ctx:      aliceContext(),
+			args:     map[string]any{"instance_id": "inst-missing"},
+			role:     model.RoleViewer,
+			wantErr:  true,
+			wantCode: CodeForbidden,
+		},
+		{
+			name:     "project of create input",
+			ctx:      aliceContext(),
+			args:     map[string]any{"input": model.NewInstanceInput{ProjectID: "proj-b"}},
+			role:     model.RoleOperator,
+			wantErr:  true,
+			wantCode: CodeForbidden,
+		},
+		{
+			name:     "every named project is checked",
+			ctx:      aliceContext(),
+			args:     map[string]any{"project_id": "proj-a", "instance_id": "inst-b"},
+			role:     model.RoleViewer,
+			wantErr:  true,
+			wantCode: CodeForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Resolver{Backend: newAuthzBackend()}
+			ctx := graphql.WithFieldContext(tt.ctx, &graphql.FieldContext{Args: tt.args})
+
+			called := false
+			next := func(ctx context.Context) (any, error) {
+				called = true
+				return nil, nil
+			}
+
+			_, err := r.HasRole(ctx, nil, next, tt.role)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HasRole() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if called == tt.wantErr {
+				t.Errorf("HasRole() called next = %v, want %v", called, !tt.wantErr)
+			}
+			if tt.wantCode != "" {
+				gqlErr, ok := err.(*gqlerror.Error)
+				if !ok || gqlErr.Extensions["code"] != tt.wantCode {
+					t.Errorf("HasRole() error = %#v, want code %s", err, tt.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestHasRoleHidesExistence(t *testing.T) {
+	r := &Resolver{Backend: newAuthzBackend()}
+	next := func(ctx context.Context) (any, error) { return nil, nil }
+
+	hasRole := func(instanceID string) error {
+		ctx := graphql.WithFieldContext(aliceContext(), &graphql.FieldContext{Args: map[string]any{"instance_id": instanceID}})
+		_, err := r.HasRole(ctx, nil, next, model.RoleViewer)
+		return err
+	}
+
+	foreign, missing := hasRole("inst-b"), hasRole("inst-missing")
+	if foreign == nil || missing == nil || foreign.Error() != missing.Error() {
+		t.Errorf("HasRole() errors differ for a foreign instance (%v) and a missing one (%v)", foreign, missing)
+	}
+}
+
+func TestVisibleTo(t *testing.T) {
+	instances := []*model.Instance{
+		{InstanceID: "inst-1", ProjectID: "proj-a"},
+		{InstanceID: "inst-2", ProjectID: "proj-b"},
+		{InstanceID: "inst-3", ProjectID: "proj-new"},
+		{InstanceID: "inst-4", ProjectID: "proj-a"},
+		{InstanceID: "inst-5", ProjectID: ""},
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want []string
+	}{
+		{"authentication disabled", context.Background(), []string{"inst-1", "inst-2", "inst-3", "inst-4", "inst-5"}},
+		{"caller's projects only", aliceContext(), []string{"inst-1", "inst-3", "inst-4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newAuthzBackend()
+			r := &Resolver{Backend: backend}
+
+			var got []string
+			for _, instance := range r.visibleInstances(tt.ctx, instances) {
+				got = append(got, instance.InstanceID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("visibleInstances() = %v, want %v", got, tt.want)
+			}
+			for projectID, count := range backend.lookups {
+				if count > 1 {
+					t.Errorf("role in %s looked up %d times, want once", projectID, count)
+				}
+			}
+		})
+	}
+}
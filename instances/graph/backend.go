@@ -0,0 +1,143 @@
+package graph
+
+import (
+	"context"
+	"gqlfed/instances/events"
+	"gqlfed/instances/graph/model"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// Backend is the data source behind the resolvers. The mock implementation
+// serves the fixtures from mocks.go, the live one talks to CozyStack.
+type Backend interface {
+	// GetProjects returns the instances, disks, networks, usage and monthly
+	// cost of each project in the same order. Projects are owned by another
+	// subgraph, so every non-empty ID resolves, even one without resources.
+	GetProjects(ctx context.Context, projectIDs []string) ([]*model.Project, error)
+	// GetProjectQuota returns the limits of the project next to its current
+	// usage. A nil limit is unlimited.
+	GetProjectQuota(ctx context.Context, projectID string) (*model.ProjectQuota, error)
+
+	// CreateInstance validates the flavor and disk size against the image
+	// minimums; violations and instance types missing from the flavor catalog
+	// are reported with the BAD_USER_INPUT code. The
+	// networks must belong to the project and the region of the instance.
+	// Instances, disks and port changes that would exceed the project quota
+	// fail with the QUOTA_EXCEEDED code before anything is created.
+	CreateInstance(ctx context.Context, input model.NewInstanceInput) (*model.Instance, error)
+	// EstimateInstanceCost prices the instance CreateInstance would create
+	// from the same input, applying the same defaults. Unknown instance types
+	// fail with the BAD_USER_INPUT code like they do in CreateInstance. Every
+	// returned instance carries its own cost as well.
+	EstimateInstanceCost(ctx context.Context, input model.NewInstanceInput) (*model.Cost, error)
+	DeleteInstance(ctx context.Context, instanceID string) (bool, error)
+	// GetInstanceList returns the instances of the project. An empty project
+	// ID returns the instances of every project; callers must filter them by
+	// the caller's visible projects, as instancesUpdates does.
+	GetInstanceList(ctx context.Context, projectID string) ([]*model.Instance, error)
+	GetInstanceItem(ctx context.Context, instanceID string) (*model.Instance, error)
+	// GetInstancesByIDs resolves federation references in one call. The result
+	// has an entry per ID in the same order, nil for unknown IDs.
+	GetInstancesByIDs(ctx context.Context, instanceIDs []string) ([]*model.Instance, error)
+
+	StartInstance(ctx context.Context, instanceID string) (*model.Instance, error)
+	StopInstance(ctx context.Context, instanceID string) (*model.Instance, error)
+	RebootInstance(ctx context.Context, instanceID string) (*model.Instance, error)
+	ResetInstance(ctx context.Context, instanceID string) (*model.Instance, error)
+	// SetInstancePorts changes how the instance is exposed on its external
+	// address. PORT_LIST exposes the listed TCP ports and an empty list removes
+	// the external address; WHOLE_IP forwards all TCP and UDP traffic.
+	SetInstancePorts(ctx context.Context, instanceID string, ports []int32, method model.ExternalMethod) (*model.Instance, error)
+
+	// GetDiskList returns the disks of the project, or of every project for
+	// an empty ID like GetInstanceList.
+	GetDiskList(ctx context.Context, projectID string) ([]*model.Disk, error)
+	GetDisk(ctx context.Context, diskID string) (*model.Disk, error)
+	// GetDisksByIDs resolves disk references like GetInstancesByIDs.
+	GetDisksByIDs(ctx context.Context, diskIDs []string) ([]*model.Disk, error)
+	CreateDisk(ctx context.Context, input model.NewDiskInput) (*model.Disk, error)
+	ResizeDisk(ctx context.Context, diskID string, sizeGB int32) (*model.Disk, error)
+	// DeleteDisk refuses to delete a disk that is attached to an instance.
+	DeleteDisk(ctx context.Context, diskID string) (bool, error)
+	AttachDisk(ctx context.Context, instanceID, diskID string) (*model.Instance, error)
+	DetachDisk(ctx context.Context, instanceID, diskID string) (*model.Instance, error)
+
+	ListSnapshots(ctx context.Context, projectID string, diskID *string) ([]*model.Snapshot, error)
+	CreateSnapshot(ctx context.Context, diskID string) (*model.Snapshot, error)
+	GetSnapshot(ctx context.Context, snapshotID string) (*model.Snapshot, error)
+	DeleteSnapshot(ctx context.Context, snapshotID string) (bool, error)
+	// RestoreSnapshot rolls the source disk back to the snapshot. The disk must
+	// be detached or its instance stopped.
+	RestoreSnapshot(ctx context.Context, snapshotID string) (*model.Disk, error)
+	// CreateDiskFromSnapshot creates a new disk with the snapshot contents; a nil
+	// size keeps the size of the snapshot.
+	CreateDiskFromSnapshot(ctx context.Context, snapshotID string, sizeGB *int32) (*model.Disk, error)
+
+	GetFlavorList(ctx context.Context) ([]*model.KVStringListOfFlavor, error)
+	// GetCompatibleFlavors returns the flavors that meet the vCPU and RAM
+	// minimums of the image.
+	GetCompatibleFlavors(ctx context.Context, imageID string, projectID *string) ([]*model.KVStringListOfFlavor, error)
+	// GetImageList returns the image catalog; with a project it also returns
+	// the project's imported images that are ready.
+	GetImageList(ctx context.Context, projectID *string) ([]*model.Image, error)
+	// GetImagesByIDs resolves image references by image_id like
+	// GetInstancesByIDs; imported images are found once they are ready.
+	GetImagesByIDs(ctx context.Context, imageIDs []string) ([]*model.Image, error)
+	// GetImageProject returns the project that imported the image, or an
+	// empty string for catalog images that every project may use.
+	GetImageProject(ctx context.Context, imageID string) (string, error)
+
+	// GetNetworkList returns the networks of the project; nil returns the
+	// networks of every project.
+	GetNetworkList(ctx context.Context, projectID *string) ([]*model.Network, error)
+	// GetNetworksByIDs resolves network references like GetInstancesByIDs.
+	GetNetworksByIDs(ctx context.Context, networkIDs []string) ([]*model.Network, error)
+	// CreateNetwork creates a private network; a nil gateway uses the first
+	// address of the subnet.
+	CreateNetwork(ctx context.Context, input model.NewNetworkInput) (*model.Network, error)
+	// UpdateNetwork renames the network and changes its security group; nil
+	// keeps the current value and an empty security group removes it.
+	UpdateNetwork(ctx context.Context, networkID string, networkName, securityGroupID *string) (*model.Network, error)
+	// DeleteNetwork refuses to delete a network that instances are attached to.
+	DeleteNetwork(ctx context.Context, networkID string) (bool, error)
+
+	GetSecurityGroups(ctx context.Context, projectID string) ([]*model.SecurityGroup, error)
+	GetSecurityGroup(ctx context.Context, securityGroupID string) (*model.SecurityGroup, error)
+	// CreateSecurityGroup creates a group that applies to the instances of the
+	// networks it is assigned to. Ingress is denied unless a rule allows it;
+	// egress is restricted only once the group has egress rules.
+	CreateSecurityGroup(ctx context.Context, input model.NewSecurityGroupInput) (*model.SecurityGroup, error)
+	// UpdateSecurityGroup renames the group and replaces its rules; nil keeps
+	// the current value.
+	UpdateSecurityGroup(ctx context.Context, securityGroupID string, name *string, rules []*model.SecurityGroupRuleInput) (*model.SecurityGroup, error)
+	// DeleteSecurityGroup refuses to delete a group that a network uses.
+	DeleteSecurityGroup(ctx context.Context, securityGroupID string) (bool, error)
+
+	// GetSSHKeys returns the keys of the given user and project; nil filters
+	// match any owner.
+	GetSSHKeys(ctx context.Context, userID, projectID *string) ([]*model.SSHKey, error)
+	AddSSHKey(ctx context.Context, input model.NewSSHKeyInput) (*model.SSHKey, error)
+	DeleteSSHKey(ctx context.Context, name string, userID, projectID *string) (bool, error)
+
+	GetImageImports(ctx context.Context, projectID string) ([]*model.ImageImport, error)
+	// ImportImage starts importing an image from a URL and returns immediately;
+	// a nil size imports into a 20 GB disk.
+	ImportImage(ctx context.Context, projectID, url, label, osVersion string, sizeGB *int32) (*model.ImageImport, error)
+	// UploadImage streams a qcow2 or ISO file into a disk and returns once the
+	// upload finishes. Progress is published as image import events.
+	UploadImage(ctx context.Context, projectID string, file graphql.Upload, label, osVersion string) (*model.ImageImport, error)
+
+	// GetRoleBindings returns the roles granted in the project.
+	GetRoleBindings(ctx context.Context, projectID string) ([]*model.RoleBinding, error)
+	// SetRoleBinding grants the user a role in the project, replacing the
+	// previous one. A project must keep at least one ADMIN.
+	SetRoleBinding(ctx context.Context, projectID, userID string, role model.Role) (*model.RoleBinding, error)
+	// DeleteRoleBinding revokes the user's role; the last ADMIN cannot be
+	// removed.
+	DeleteRoleBinding(ctx context.Context, projectID, userID string) (bool, error)
+
+	// Subscribe returns a channel of state change events. The channel is
+	// closed when ctx is cancelled or when the subscriber falls behind.
+	Subscribe(ctx context.Context) <-chan events.Event
+}
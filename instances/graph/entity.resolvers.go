@@ -6,13 +6,121 @@ package graph
 
 import (
 	"context"
-	"fmt"
 	"gqlfed/instances/graph/model"
 )
 
+// FindManyDiskByDiskIDs is the resolver for the findManyDiskByDiskIDs field.
+func (r *entityResolver) FindManyDiskByDiskIDs(ctx context.Context, reps []*model.DiskByDiskIDsInput) ([]*model.Disk, error) {
+	ids := make([]string, len(reps))
+	for i, rep := range reps {
+		ids[i] = rep.DiskID
+	}
+	disks, err := r.Backend.GetDisksByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i, disk := range disks {
+		if disk != nil && !r.canView(ctx, disk.ProjectID) {
+			disks[i] = nil
+		}
+	}
+	return disks, nil
+}
+
+// FindManyImageByImageIDs is the resolver for the findManyImageByImageIDs field.
+func (r *entityResolver) FindManyImageByImageIDs(ctx context.Context, reps []*model.ImageByImageIDsInput) ([]*model.Image, error) {
+	ids := make([]string, len(reps))
+	for i, rep := range reps {
+		ids[i] = rep.ImageID
+	}
+	images, err := r.Backend.GetImagesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i, image := range images {
+		if image == nil {
+			continue
+		}
+		projectID, err := r.Backend.GetImageProject(ctx, image.ImageID)
+		if err != nil {
+			return nil, err
+		}
+		if projectID != "" && !r.canView(ctx, projectID) {
+			images[i] = nil
+		}
+	}
+	return images, nil
+}
+
+// FindManyInstanceByInstanceIDs is the resolver for the findManyInstanceByInstanceIDs field.
+func (r *entityResolver) FindManyInstanceByInstanceIDs(ctx context.Context, reps []*model.InstanceByInstanceIDsInput) ([]*model.Instance, error) {
+	ids := make([]string, len(reps))
+	for i, rep := range reps {
+		ids[i] = rep.InstanceID
+	}
+	instances, err := r.Backend.GetInstancesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i, instance := range instances {
+		if instance != nil && !r.canView(ctx, instance.ProjectID) {
+			instances[i] = nil
+		}
+	}
+	return instances, nil
+}
+
+// FindManyNetworkByNetworkIDs is the resolver for the findManyNetworkByNetworkIDs field.
+func (r *entityResolver) FindManyNetworkByNetworkIDs(ctx context.Context, reps []*model.NetworkByNetworkIDsInput) ([]*model.Network, error) {
+	ids := make([]string, len(reps))
+	for i, rep := range reps {
+		ids[i] = rep.NetworkID
+	}
+	networks, err := r.Backend.GetNetworksByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i, found := range networks {
+		if found != nil && !r.canView(ctx, found.ProjectID) {
+			networks[i] = nil
+		}
+	}
+	return networks, nil
+}
+
+// FindManyProjectByProjectIDs is the resolver for the findManyProjectByProjectIDs field.
+func (r *entityResolver) FindManyProjectByProjectIDs(ctx context.Context, reps []*model.ProjectByProjectIDsInput) ([]*model.Project, error) {
+	ids := make([]string, len(reps))
+	for i, rep := range reps {
+		ids[i] = rep.ProjectID
+	}
+	projects, err := r.Backend.GetProjects(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i, project := range projects {
+		if project != nil && !r.canView(ctx, project.ProjectID) {
+			projects[i] = nil
+		}
+	}
+	return projects, nil
+}
+
 // FindUserByUserID is the resolver for the findUserByUserID field.
 func (r *entityResolver) FindUserByUserID(ctx context.Context, userID string) (*model.User, error) {
-	panic(fmt.Errorf("not implemented: FindUserByUserID - findUserByUserID"))
+	// Keys are only shown to their owner
+	if _, err := ownUserID(ctx, &userID); err != nil {
+		return &model.User{UserID: userID, SSHKeys: []*model.SSHKey{}}, nil
+	}
+
+	keys, err := r.Backend.GetSSHKeys(ctx, &userID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The users subgraph owns the rest of the User fields; this one only
+	// contributes the keys stored for the user.
+	return &model.User{UserID: userID, SSHKeys: r.visibleKeyInstances(ctx, keys)}, nil
 }
 
 // Entity returns EntityResolver implementation.
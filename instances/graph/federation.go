@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"gqlfed/instances/graph/model"
 	"strings"
 	"sync"
 
@@ -134,6 +135,16 @@ func (ec *executionContext) resolveEntityGroup(
 
 func isMulti(typeName string) bool {
 	switch typeName {
+	case "Disk":
+		return true
+	case "Image":
+		return true
+	case "Instance":
+		return true
+	case "Network":
+		return true
+	case "Project":
+		return true
 	default:
 		return false
 	}
@@ -193,11 +204,361 @@ func (ec *executionContext) resolveManyEntities(
 
 	switch typeName {
 
+	case "Disk":
+		resolverName, err := entityResolverNameForDisk(ctx, reps[0].entity)
+		if err != nil {
+			return fmt.Errorf(`finding resolver for Entity "Disk": %w`, err)
+		}
+		switch resolverName {
+
+		case "findManyDiskByDiskIDs":
+			typedReps := make([]*model.DiskByDiskIDsInput, len(reps))
+
+			for i, rep := range reps {
+				id0, err := ec.unmarshalNString2string(ctx, rep.entity["disk_id"])
+				if err != nil {
+					return errors.New(fmt.Sprintf("Field %s undefined in schema.", "diskID"))
+				}
+
+				typedReps[i] = &model.DiskByDiskIDsInput{
+					DiskID: id0,
+				}
+			}
+
+			entities, err := ec.resolvers.Entity().FindManyDiskByDiskIDs(ctx, typedReps)
+			if err != nil {
+				return err
+			}
+
+			for i, entity := range entities {
+				list[reps[i].index] = entity
+			}
+			return nil
+
+		default:
+			return fmt.Errorf("unknown resolver: %s", resolverName)
+		}
+
+	case "Image":
+		resolverName, err := entityResolverNameForImage(ctx, reps[0].entity)
+		if err != nil {
+			return fmt.Errorf(`finding resolver for Entity "Image": %w`, err)
+		}
+		switch resolverName {
+
+		case "findManyImageByImageIDs":
+			typedReps := make([]*model.ImageByImageIDsInput, len(reps))
+
+			for i, rep := range reps {
+				id0, err := ec.unmarshalNString2string(ctx, rep.entity["image_id"])
+				if err != nil {
+					return errors.New(fmt.Sprintf("Field %s undefined in schema.", "imageID"))
+				}
+
+				typedReps[i] = &model.ImageByImageIDsInput{
+					ImageID: id0,
+				}
+			}
+
+			entities, err := ec.resolvers.Entity().FindManyImageByImageIDs(ctx, typedReps)
+			if err != nil {
+				return err
+			}
+
+			for i, entity := range entities {
+				list[reps[i].index] = entity
+			}
+			return nil
+
+		default:
+			return fmt.Errorf("unknown resolver: %s", resolverName)
+		}
+
+	case "Instance":
+		resolverName, err := entityResolverNameForInstance(ctx, reps[0].entity)
+		if err != nil {
+			return fmt.Errorf(`finding resolver for Entity "Instance": %w`, err)
+		}
+		switch resolverName {
+
+		case "findManyInstanceByInstanceIDs":
+			typedReps := make([]*model.InstanceByInstanceIDsInput, len(reps))
+
+			for i, rep := range reps {
+				id0, err := ec.unmarshalNString2string(ctx, rep.entity["instance_id"])
+				if err != nil {
+					return errors.New(fmt.Sprintf("Field %s undefined in schema.", "instanceID"))
+				}
+
+				typedReps[i] = &model.InstanceByInstanceIDsInput{
+					InstanceID: id0,
+				}
+			}
+
+			entities, err := ec.resolvers.Entity().FindManyInstanceByInstanceIDs(ctx, typedReps)
+			if err != nil {
+				return err
+			}
+
+			for i, entity := range entities {
+				list[reps[i].index] = entity
+			}
+			return nil
+
+		default:
+			return fmt.Errorf("unknown resolver: %s", resolverName)
+		}
+
+	case "Network":
+		resolverName, err := entityResolverNameForNetwork(ctx, reps[0].entity)
+		if err != nil {
+			return fmt.Errorf(`finding resolver for Entity "Network": %w`, err)
+		}
+		switch resolverName {
+
+		case "findManyNetworkByNetworkIDs":
+			typedReps := make([]*model.NetworkByNetworkIDsInput, len(reps))
+
+			for i, rep := range reps {
+				id0, err := ec.unmarshalNID2string(ctx, rep.entity["network_id"])
+				if err != nil {
+					return errors.New(fmt.Sprintf("Field %s undefined in schema.", "networkID"))
+				}
+
+				typedReps[i] = &model.NetworkByNetworkIDsInput{
+					NetworkID: id0,
+				}
+			}
+
+			entities, err := ec.resolvers.Entity().FindManyNetworkByNetworkIDs(ctx, typedReps)
+			if err != nil {
+				return err
+			}
+
+			for i, entity := range entities {
+				list[reps[i].index] = entity
+			}
+			return nil
+
+		default:
+			return fmt.Errorf("unknown resolver: %s", resolverName)
+		}
+
+	case "Project":
+		resolverName, err := entityResolverNameForProject(ctx, reps[0].entity)
+		if err != nil {
+			return fmt.Errorf(`finding resolver for Entity "Project": %w`, err)
+		}
+		switch resolverName {
+
+		case "findManyProjectByProjectIDs":
+			typedReps := make([]*model.ProjectByProjectIDsInput, len(reps))
+
+			for i, rep := range reps {
+				id0, err := ec.unmarshalNID2string(ctx, rep.entity["project_id"])
+				if err != nil {
+					return errors.New(fmt.Sprintf("Field %s undefined in schema.", "projectID"))
+				}
+
+				typedReps[i] = &model.ProjectByProjectIDsInput{
+					ProjectID: id0,
+				}
+			}
+
+			entities, err := ec.resolvers.Entity().FindManyProjectByProjectIDs(ctx, typedReps)
+			if err != nil {
+				return err
+			}
+
+			for i, entity := range entities {
+				list[reps[i].index] = entity
+			}
+			return nil
+
+		default:
+			return fmt.Errorf("unknown resolver: %s", resolverName)
+		}
+
 	default:
 		return errors.New("unknown type: " + typeName)
 	}
 }
 
+func entityResolverNameForDisk(ctx context.Context, rep EntityRepresentation) (string, error) {
+	// we collect errors because a later entity resolver may work fine
+	// when an entity has multiple keys
+	entityResolverErrs := []error{}
+	for {
+		var (
+			m   EntityRepresentation
+			val any
+			ok  bool
+		)
+		_ = val
+		// if all of the KeyFields values for this resolver are null,
+		// we shouldn't use use it
+		allNull := true
+		m = rep
+		val, ok = m["disk_id"]
+		if !ok {
+			entityResolverErrs = append(entityResolverErrs,
+				fmt.Errorf("%w due to missing Key Field \"disk_id\" for Disk", ErrTypeNotFound))
+			break
+		}
+		if allNull {
+			allNull = val == nil
+		}
+		if allNull {
+			entityResolverErrs = append(entityResolverErrs,
+				fmt.Errorf("%w due to all null value KeyFields for Disk", ErrTypeNotFound))
+			break
+		}
+		return "findManyDiskByDiskIDs", nil
+	}
+	return "", fmt.Errorf("%w for Disk due to %v", ErrTypeNotFound,
+		errors.Join(entityResolverErrs...).Error())
+}
+
+func entityResolverNameForImage(ctx context.Context, rep EntityRepresentation) (string, error) {
+	// we collect errors because a later entity resolver may work fine
+	// when an entity has multiple keys
+	entityResolverErrs := []error{}
+	for {
+		var (
+			m   EntityRepresentation
+			val any
+			ok  bool
+		)
+		_ = val
+		// if all of the KeyFields values for this resolver are null,
+		// we shouldn't use use it
+		allNull := true
+		m = rep
+		val, ok = m["image_id"]
+		if !ok {
+			entityResolverErrs = append(entityResolverErrs,
+				fmt.Errorf("%w due to missing Key Field \"image_id\" for Image", ErrTypeNotFound))
+			break
+		}
+		if allNull {
+			allNull = val == nil
+		}
+		if allNull {
+			entityResolverErrs = append(entityResolverErrs,
+				fmt.Errorf("%w due to all null value KeyFields for Image", ErrTypeNotFound))
+			break
+		}
+		return "findManyImageByImageIDs", nil
+	}
+	return "", fmt.Errorf("%w for Image due to %v", ErrTypeNotFound,
+		errors.Join(entityResolverErrs...).Error())
+}
+
+func entityResolverNameForInstance(ctx context.Context, rep EntityRepresentation) (string, error) {
+	// we collect errors because a later entity resolver may work fine
+	// when an entity has multiple keys
+	entityResolverErrs := []error{}
+	for {
+		var (
+			m   EntityRepresentation
+			val any
+			ok  bool
+		)
+		_ = val
+		// if all of the KeyFields values for this resolver are null,
+		// we shouldn't use use it
+		allNull := true
+		m = rep
+		val, ok = m["instance_id"]
+		if !ok {
+			entityResolverErrs = append(entityResolverErrs,
+				fmt.Errorf("%w due to missing Key Field \"instance_id\" for Instance", ErrTypeNotFound))
+			break
+		}
+		if allNull {
+			allNull = val == nil
+		}
+		if allNull {
+			entityResolverErrs = append(entityResolverErrs,
+				fmt.Errorf("%w due to all null value KeyFields for Instance", ErrTypeNotFound))
+			break
+		}
+		return "findManyInstanceByInstanceIDs", nil
+	}
+	return "", fmt.Errorf("%w for Instance due to %v", ErrTypeNotFound,
+		errors.Join(entityResolverErrs...).Error())
+}
+
+func entityResolverNameForNetwork(ctx context.Context, rep EntityRepresentation) (string, error) {
+	// we collect errors because a later entity resolver may work fine
+	// when an entity has multiple keys
+	entityResolverErrs := []error{}
+	for {
+		var (
+			m   EntityRepresentation
+			val any
+			ok  bool
+		)
+		_ = val
+		// if all of the KeyFields values for this resolver are null,
+		// we shouldn't use use it
+		allNull := true
+		m = rep
+		val, ok = m["network_id"]
+		if !ok {
+			entityResolverErrs = append(entityResolverErrs,
+				fmt.Errorf("%w due to missing Key Field \"network_id\" for Network", ErrTypeNotFound))
+			break
+		}
+		if allNull {
+			allNull = val == nil
+		}
+		if allNull {
+			entityResolverErrs = append(entityResolverErrs,
+				fmt.Errorf("%w due to all null value KeyFields for Network", ErrTypeNotFound))
+			break
+		}
+		return "findManyNetworkByNetworkIDs", nil
+	}
+	return "", fmt.Errorf("%w for Network due to %v", ErrTypeNotFound,
+		errors.Join(entityResolverErrs...).Error())
+}
+
+func entityResolverNameForProject(ctx context.Context, rep EntityRepresentation) (string, error) {
+	// we collect errors because a later entity resolver may work fine
+	// when an entity has multiple keys
+	entityResolverErrs := []error{}
+	for {
+		var (
+			m   EntityRepresentation
+			val any
+			ok  bool
+		)
+		_ = val
+		// if all of the KeyFields values for this resolver are null,
+		// we shouldn't use use it
+		allNull := true
+		m = rep
+		val, ok = m["project_id"]
+		if !ok {
+			entityResolverErrs = append(entityResolverErrs,
+				fmt.Errorf("%w due to missing Key Field \"project_id\" for Project", ErrTypeNotFound))
+			break
+		}
+		if allNull {
+			allNull = val == nil
+		}
+		if allNull {
+			entityResolverErrs = append(entityResolverErrs,
+				fmt.Errorf("%w due to all null value KeyFields for Project", ErrTypeNotFound))
+			break
+		}
+		return "findManyProjectByProjectIDs", nil
+	}
+	return "", fmt.Errorf("%w for Project due to %v", ErrTypeNotFound,
+		errors.Join(entityResolverErrs...).Error())
+}
+
 func entityResolverNameForUser(ctx context.Context, rep EntityRepresentation) (string, error) {
 	// we collect errors because a later entity resolver may work fine
 	// when an entity has multiple keys
@@ -0,0 +1,1547 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"gqlfed/instances/auth"
+	"gqlfed/instances/cloudinit"
+	"gqlfed/instances/diskimage"
+	"gqlfed/instances/events"
+	"gqlfed/instances/graph/model"
+	"gqlfed/instances/network"
+	"gqlfed/instances/pricing"
+	"gqlfed/instances/quota"
+	"gqlfed/instances/rbac"
+	"gqlfed/instances/requirements"
+	"gqlfed/instances/sshkey"
+	"gqlfed/instances/usage"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// MockBackend serves the fixtures from mocks.go. It is used for local
+// development and for running the subgraph without a cluster.
+type MockBackend struct {
+	mu     sync.RWMutex
+	events *events.Broker
+}
+
+// NewMockBackend creates a backend over the mock data and starts the
+// goroutine that randomly changes instance statuses.
+func NewMockBackend() *MockBackend {
+	b := &MockBackend{
+		events: events.NewBroker(events.DefaultBufferSize),
+	}
+	b.mu.Lock()
+	updateMockCosts()
+	b.mu.Unlock()
+	go b.liveUpdates()
+	return b
+}
+
+func (b *MockBackend) GetProjects(ctx context.Context, projectIDs []string) ([]*model.Project, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	projects := make([]*model.Project, len(projectIDs))
+	for i, projectID := range projectIDs {
+		if projectID == "" {
+			continue
+		}
+
+		project := &model.Project{
+			ProjectID: projectID,
+			Instances: []*model.Instance{},
+			Disks:     []*model.Disk{},
+			Networks:  []*model.Network{},
+		}
+		for _, instance := range Instances {
+			if instance.ProjectID == projectID {
+				project.Instances = append(project.Instances, snapshotInstance(instance))
+			}
+		}
+		for _, disk := range mockDiskList {
+			if disk.ProjectID == projectID {
+				project.Disks = append(project.Disks, b.withInstances(disk))
+			}
+		}
+		for _, n := range mockNetworks {
+			if n.ProjectID == projectID {
+				project.Networks = append(project.Networks, n)
+			}
+		}
+		project.Usage = usage.Compute(project.Instances, project.Disks)
+		project.MonthlyCost = pricing.ProjectMonthly(mockPrices, project.Instances, project.Disks)
+		projects[i] = project
+	}
+	return projects, nil
+}
+
+func (b *MockBackend) GetProjectQuota(ctx context.Context, projectID string) (*model.ProjectQuota, error) {
+	if projectID == "" {
+		return nil, fmt.Errorf("project_id must not be empty")
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return &model.ProjectQuota{
+		ProjectID: projectID,
+		Limits:    mockQuotas.For(projectID),
+		Usage:     mockProjectUsage(projectID),
+	}, nil
+}
+
+func (b *MockBackend) CreateInstance(ctx context.Context, input model.NewInstanceInput) (*model.Instance, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if input.ProjectID == "" {
+		return nil, fmt.Errorf("project_id must not be empty")
+	}
+
+	instanceID := newMockID("inst", len(Instances), func(id string) bool { return findMockInstance(id) != nil })
+
+	flavor := findMockFlavor(input.InstanceType)
+	if flavor == nil {
+		return nil, requirements.UnknownFlavor(input.InstanceType)
+	}
+	image, diskGB, err := mockBootDisk(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := requirements.Check(image, flavor, diskGB); err != nil {
+		return nil, err
+	}
+
+	for _, name := range input.KeyNames {
+		if _, err := findMockSSHKey(input.ProjectID, auth.UserIDFromContext(ctx), name); err != nil {
+			return nil, err
+		}
+	}
+
+	externalMethod, externalPorts, err := network.InstanceExternal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	networks := []*model.Network{}
+	for _, networkID := range input.NetworkIds {
+		attached := findMockNetwork(networkID)
+		if attached == nil || attached.ProjectID != input.ProjectID {
+			return nil, fmt.Errorf("network not found: %s", networkID)
+		}
+		if attached.Region != input.Region {
+			return nil, fmt.Errorf("network %s is in region %s, not %s", networkID, attached.Region, input.Region)
+		}
+		networks = append(networks, attached)
+	}
+
+	requested := usage.Compute(
+		[]*model.Instance{{Flavor: flavor, ExternalMethod: externalMethod, ExternalPorts: externalPorts}},
+		[]*model.Disk{{SizeGb: diskGB}},
+	)
+	if err := checkMockQuota(input.ProjectID, requested); err != nil {
+		return nil, err
+	}
+
+	// Reject user data the live backend would reject.
+	if input.UserData != nil {
+		_, err := cloudinit.Compose(cloudinit.Config{
+			Hostname: input.Hostname,
+			Profile:  cloudinit.ProfileFor(strings.ToLower(image.Label)),
+			UserData: *input.UserData,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The boot disk is counted by the quota check above, so it is created
+	// like any other disk of the project.
+	bootDisk := &model.Disk{
+		DiskID:    newMockID("disk", len(mockDiskList), func(id string) bool { return findMockDisk(id) != nil }),
+		ProjectID: input.ProjectID,
+		Image:     image,
+		SizeGb:    diskGB,
+		Status:    "active",
+		Bootable:  true,
+		Instances: []*model.Instance{},
+	}
+	mockDiskList = append(mockDiskList, bootDisk)
+
+	now := time.Now().Format(time.RFC3339)
+	instance := &model.Instance{
+		InstanceID:        instanceID,
+		ProjectID:         input.ProjectID,
+		Name:              input.Hostname,
+		Status:            "BUILDING",
+		Created:           now,
+		Updated:           now,
+		KeyName:           strings.Join(input.KeyNames, ","),
+		Flavor:            flavor,
+		PowerState:        model.PowerStateStarting,
+		Loading:           true,
+		AttachedDisks:     []*model.Disk{bootDisk},
+		AttachedNetworks:  networks,
+		ExternalMethod:    externalMethod,
+		ExternalPorts:     externalPorts,
+		ExternalAddresses: []string{},
+	}
+	instance.Cost = pricing.Instance(mockPrices, instance)
+	Instances = append(Instances, instance)
+
+	b.events.Publish(events.NewDiskEvent(model.EventTypeAdded, input.ProjectID, b.withInstances(bootDisk), nil))
+	b.events.Publish(events.NewInstanceEvent(model.EventTypeAdded, snapshotInstance(instance), nil))
+
+	return snapshotInstance(instance), nil
+}
+
+func (b *MockBackend) EstimateInstanceCost(ctx context.Context, input model.NewInstanceInput) (*model.Cost, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if input.ProjectID == "" {
+		return nil, fmt.Errorf("project_id must not be empty")
+	}
+
+	_, diskGB, err := mockBootDisk(input)
+	if err != nil {
+		return nil, err
+	}
+	externalMethod, externalPorts, err := network.InstanceExternal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	flavor := findMockFlavor(input.InstanceType)
+	if flavor == nil {
+		return nil, requirements.UnknownFlavor(input.InstanceType)
+	}
+
+	return pricing.Instance(mockPrices, &model.Instance{
+		Flavor:         flavor,
+		AttachedDisks:  []*model.Disk{{SizeGb: diskGB}},
+		ExternalMethod: externalMethod,
+		ExternalPorts:  externalPorts,
+	}), nil
+}
+
+func (b *MockBackend) DeleteInstance(ctx context.Context, instanceID string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, instance := range Instances {
+		if instance.InstanceID == instanceID {
+			Instances = append(Instances[:i], Instances[i+1:]...)
+			b.events.Publish(events.NewInstanceEvent(model.EventTypeDeleted, snapshotInstance(instance), nil))
+
+			// Like the live backend, the boot disk goes with the instance and
+			// other attached disks are kept.
+			if len(instance.AttachedDisks) > 0 {
+				bootDiskID := instance.AttachedDisks[0].DiskID
+				for j, disk := range mockDiskList {
+					if disk.DiskID == bootDiskID {
+						mockDiskList = append(mockDiskList[:j], mockDiskList[j+1:]...)
+						b.events.Publish(events.NewDiskEvent(model.EventTypeDeleted, disk.ProjectID, b.withInstances(disk), nil))
+						break
+					}
+				}
+			}
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("instance not found: %s", instanceID)
+}
+
+func (b *MockBackend) GetInstanceList(ctx context.Context, projectID string) ([]*model.Instance, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	instances := []*model.Instance{}
+	for _, instance := range Instances {
+		if projectID == "" || instance.ProjectID == projectID {
+			instances = append(instances, snapshotInstance(instance))
+		}
+	}
+	return instances, nil
+}
+
+func (b *MockBackend) GetInstanceItem(ctx context.Context, instanceID string) (*model.Instance, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, instance := range Instances {
+		if instance.InstanceID == instanceID {
+			return snapshotInstance(instance), nil
+		}
+	}
+	return nil, fmt.Errorf("instance not found: %s", instanceID)
+}
+
+func (b *MockBackend) GetInstancesByIDs(ctx context.Context, instanceIDs []string) ([]*model.Instance, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	instances := make([]*model.Instance, len(instanceIDs))
+	for i, instanceID := range instanceIDs {
+		instances[i] = snapshotInstance(findMockInstance(instanceID))
+	}
+	return instances, nil
+}
+
+func (b *MockBackend) StartInstance(ctx context.Context, instanceID string) (*model.Instance, error) {
+	return b.setPowerState(instanceID, "ACTIVE", model.PowerStateActive)
+}
+
+func (b *MockBackend) StopInstance(ctx context.Context, instanceID string) (*model.Instance, error) {
+	return b.setPowerState(instanceID, "STOPPED", model.PowerStateStopped)
+}
+
+func (b *MockBackend) RebootInstance(ctx context.Context, instanceID string) (*model.Instance, error) {
+	return b.reboot(instanceID)
+}
+
+func (b *MockBackend) ResetInstance(ctx context.Context, instanceID string) (*model.Instance, error) {
+	return b.reboot(instanceID)
+}
+
+// mockRebootTime is how long a mock instance stays REBOOTING.
+var mockRebootTime = 5 * time.Second
+
+// reboot refuses instances that are not running, like the live backend, and
+// otherwise reports the instance REBOOTING until mockRebootTime has passed.
+func (b *MockBackend) reboot(instanceID string) (*model.Instance, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	instance := findMockInstance(instanceID)
+	if instance == nil {
+		return nil, fmt.Errorf("instance not found: %s", instanceID)
+	}
+	if instance.PowerState != model.PowerStateActive {
+		return nil, fmt.Errorf("instance %s is not running (power state %s)", instanceID, instance.PowerState)
+	}
+
+	b.changePowerState(instance, model.PowerStateRebooting, true)
+	time.AfterFunc(mockRebootTime, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		// The instance may have been deleted or stopped in the meantime.
+		if instance := findMockInstance(instanceID); instance != nil && instance.PowerState == model.PowerStateRebooting {
+			b.changePowerState(instance, model.PowerStateActive, false)
+		}
+	})
+
+	return snapshotInstance(instance), nil
+}
+
+// changePowerState sets the power state of a mock instance and reports the
+// change. The caller must hold b.mu.
+func (b *MockBackend) changePowerState(instance *model.Instance, powerState model.PowerState, loading bool) {
+	before := snapshotInstance(instance)
+	instance.PowerState = powerState
+	instance.Loading = loading
+	instance.Updated = time.Now().Format(time.RFC3339)
+	b.events.Publish(events.NewInstanceEvent(model.EventTypeModified, snapshotInstance(instance), events.InstanceChangedFields(before, instance)))
+}
+
+// setPowerState changes the status of a mock instance and reports the change.
+func (b *MockBackend) setPowerState(instanceID, status string, powerState model.PowerState) (*model.Instance, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, instance := range Instances {
+		if instance.InstanceID != instanceID {
+			continue
+		}
+
+		var changed []string
+		if instance.Status != status {
+			instance.Status = status
+			changed = append(changed, "status")
+		}
+		if instance.PowerState != powerState {
+			instance.PowerState = powerState
+			changed = append(changed, "power_state")
+		}
+		instance.Updated = time.Now().Format(time.RFC3339)
+
+		if len(changed) > 0 {
+			b.events.Publish(events.NewInstanceEvent(model.EventTypeModified, snapshotInstance(instance), changed))
+		}
+		return snapshotInstance(instance), nil
+	}
+	return nil, fmt.Errorf("instance not found: %s", instanceID)
+}
+
+func (b *MockBackend) SetInstancePorts(ctx context.Context, instanceID string, ports []int32, method model.ExternalMethod) (*model.Instance, error) {
+	ports, err := network.ExternalPorts(method, ports)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	instance := findMockInstance(instanceID)
+	if instance == nil {
+		return nil, fmt.Errorf("instance not found: %s", instanceID)
+	}
+	if !usage.Exposed(instance) && usage.Exposed(&model.Instance{ExternalMethod: method, ExternalPorts: ports}) {
+		if err := checkMockQuota(instance.ProjectID, &model.ProjectUsage{PublicIps: 1}); err != nil {
+			return nil, err
+		}
+	}
+
+	before := snapshotInstance(instance)
+	instance.ExternalMethod = method
+	instance.ExternalPorts = ports
+	// Without exposed ports the instance loses its external address.
+	if method == model.ExternalMethodPortList && len(ports) == 0 {
+		instance.ExternalAddresses = []string{}
+		instance.IPV4 = ""
+	}
+	instance.Cost = pricing.Instance(mockPrices, instance)
+	instance.Updated = time.Now().Format(time.RFC3339)
+	b.events.Publish(events.NewInstanceEvent(model.EventTypeModified, snapshotInstance(instance), events.InstanceChangedFields(before, instance)))
+
+	return snapshotInstance(instance), nil
+}
+
+func (b *MockBackend) GetDiskList(ctx context.Context, projectID string) ([]*model.Disk, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	disks := []*model.Disk{}
+	for _, disk := range mockDiskList {
+		if projectID == "" || disk.ProjectID == projectID {
+			disks = append(disks, b.withInstances(disk))
+		}
+	}
+	return disks, nil
+}
+
+func (b *MockBackend) GetDisk(ctx context.Context, diskID string) (*model.Disk, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	disk := findMockDisk(diskID)
+	if disk == nil {
+		return nil, fmt.Errorf("disk not found: %s", diskID)
+	}
+	return b.withInstances(disk), nil
+}
+
+func (b *MockBackend) GetDisksByIDs(ctx context.Context, diskIDs []string) ([]*model.Disk, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	disks := make([]*model.Disk, len(diskIDs))
+	for i, diskID := range diskIDs {
+		if disk := findMockDisk(diskID); disk != nil {
+			disks[i] = b.withInstances(disk)
+		}
+	}
+	return disks, nil
+}
+
+func (b *MockBackend) CreateDisk(ctx context.Context, input model.NewDiskInput) (*model.Disk, error) {
+	if input.SizeGb <= 0 {
+		return nil, fmt.Errorf("disk size must be positive, got %d", input.SizeGb)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	disk := &model.Disk{
+		DiskID:    newMockID("disk", len(mockDiskList), func(id string) bool { return findMockDisk(id) != nil }),
+		ProjectID: input.ProjectID,
+		SizeGb:    input.SizeGb,
+		Status:    "active",
+		Instances: []*model.Instance{},
+	}
+
+	if input.ImageID != nil {
+		disk.Image = findMockImage(input.ProjectID, *input.ImageID)
+		if disk.Image == nil {
+			return nil, fmt.Errorf("unknown image %s", *input.ImageID)
+		}
+		if err := requirements.CheckDisk(disk.Image, input.SizeGb); err != nil {
+			return nil, err
+		}
+		disk.Bootable = true
+	}
+	if err := checkMockQuota(input.ProjectID, &model.ProjectUsage{DiskGb: input.SizeGb}); err != nil {
+		return nil, err
+	}
+	mockDiskList = append(mockDiskList, disk)
+
+	b.events.Publish(events.NewDiskEvent(model.EventTypeAdded, input.ProjectID, b.withInstances(disk), nil))
+
+	return b.withInstances(disk), nil
+}
+
+func (b *MockBackend) ResizeDisk(ctx context.Context, diskID string, sizeGB int32) (*model.Disk, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	disk := findMockDisk(diskID)
+	if disk == nil {
+		return nil, fmt.Errorf("disk not found: %s", diskID)
+	}
+	if sizeGB <= disk.SizeGb {
+		return nil, fmt.Errorf("new size (%d GB) must be larger than current size (%d GB)", sizeGB, disk.SizeGb)
+	}
+	if err := checkMockQuota(disk.ProjectID, &model.ProjectUsage{DiskGb: sizeGB - disk.SizeGb}); err != nil {
+		return nil, err
+	}
+
+	disk.SizeGb = sizeGB
+	updateMockCosts()
+	b.events.Publish(events.NewDiskEvent(model.EventTypeModified, disk.ProjectID, b.withInstances(disk), []string{"size_gb"}))
+
+	return b.withInstances(disk), nil
+}
+
+func (b *MockBackend) DeleteDisk(ctx context.Context, diskID string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if instance := mockDiskOwner(diskID); instance != nil {
+		return false, fmt.Errorf("disk %s is attached to instance %s", diskID, instance.InstanceID)
+	}
+
+	for i, disk := range mockDiskList {
+		if disk.DiskID == diskID {
+			mockDiskList = append(mockDiskList[:i], mockDiskList[i+1:]...)
+			b.events.Publish(events.NewDiskEvent(model.EventTypeDeleted, disk.ProjectID, b.withInstances(disk), nil))
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("disk not found: %s", diskID)
+}
+
+func (b *MockBackend) AttachDisk(ctx context.Context, instanceID, diskID string) (*model.Instance, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	instance := findMockInstance(instanceID)
+	if instance == nil {
+		return nil, fmt.Errorf("instance not found: %s", instanceID)
+	}
+	disk := findMockDisk(diskID)
+	if disk == nil {
+		return nil, fmt.Errorf("disk not found: %s", diskID)
+	}
+	if disk.ProjectID != instance.ProjectID {
+		return nil, fmt.Errorf("disk %s belongs to another project than instance %s", diskID, instanceID)
+	}
+	if owner := mockDiskOwner(diskID); owner != nil {
+		return nil, fmt.Errorf("disk %s is already attached to instance %s", diskID, owner.InstanceID)
+	}
+
+	before := snapshotInstance(instance)
+	instance.AttachedDisks = append(instance.AttachedDisks, disk)
+	instance.Cost = pricing.Instance(mockPrices, instance)
+	instance.Updated = time.Now().Format(time.RFC3339)
+	b.events.Publish(events.NewInstanceEvent(model.EventTypeModified, snapshotInstance(instance), events.InstanceChangedFields(before, instance)))
+
+	return snapshotInstance(instance), nil
+}
+
+func (b *MockBackend) DetachDisk(ctx context.Context, instanceID, diskID string) (*model.Instance, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	instance := findMockInstance(instanceID)
+	if instance == nil {
+		return nil, fmt.Errorf("instance not found: %s", instanceID)
+	}
+
+	for i, disk := range instance.AttachedDisks {
+		if disk.DiskID != diskID {
+			continue
+		}
+		if i == 0 {
+			return nil, fmt.Errorf("disk %s is the boot disk of instance %s", diskID, instanceID)
+		}
+		before := snapshotInstance(instance)
+		instance.AttachedDisks = append(instance.AttachedDisks[:i:i], instance.AttachedDisks[i+1:]...)
+		instance.Cost = pricing.Instance(mockPrices, instance)
+		instance.Updated = time.Now().Format(time.RFC3339)
+		b.events.Publish(events.NewInstanceEvent(model.EventTypeModified, snapshotInstance(instance), events.InstanceChangedFields(before, instance)))
+		return snapshotInstance(instance), nil
+	}
+	return nil, fmt.Errorf("disk %s is not attached to instance %s", diskID, instanceID)
+}
+
+func (b *MockBackend) ListSnapshots(ctx context.Context, projectID string, diskID *string) ([]*model.Snapshot, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	snapshots := []*model.Snapshot{}
+	for _, snapshot := range mockSnapshots {
+		if snapshot.ProjectID != projectID {
+			continue
+		}
+		if diskID == nil || snapshot.DiskID == *diskID {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots, nil
+}
+
+func (b *MockBackend) CreateSnapshot(ctx context.Context, diskID string) (*model.Snapshot, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	disk := findMockDisk(diskID)
+	if disk == nil {
+		return nil, fmt.Errorf("disk not found: %s", diskID)
+	}
+
+	snapshot := &model.Snapshot{
+		SnapshotID: newMockID("snap", len(mockSnapshots), func(id string) bool { return findMockSnapshot(id) != nil }),
+		ProjectID:  disk.ProjectID,
+		DiskID:     diskID,
+		SizeGb:     disk.SizeGb,
+		Status:     "READY",
+		Created:    time.Now().Format(time.RFC3339),
+	}
+	mockSnapshots = append(mockSnapshots, snapshot)
+
+	return snapshot, nil
+}
+
+func (b *MockBackend) GetSnapshot(ctx context.Context, snapshotID string) (*model.Snapshot, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	snapshot := findMockSnapshot(snapshotID)
+	if snapshot == nil {
+		return nil, fmt.Errorf("snapshot not found: %s", snapshotID)
+	}
+	return snapshot, nil
+}
+
+func (b *MockBackend) DeleteSnapshot(ctx context.Context, snapshotID string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, snapshot := range mockSnapshots {
+		if snapshot.SnapshotID == snapshotID {
+			mockSnapshots = append(mockSnapshots[:i], mockSnapshots[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("snapshot not found: %s", snapshotID)
+}
+
+func (b *MockBackend) RestoreSnapshot(ctx context.Context, snapshotID string) (*model.Disk, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snapshot := findMockSnapshot(snapshotID)
+	if snapshot == nil {
+		return nil, fmt.Errorf("snapshot not found: %s", snapshotID)
+	}
+	disk := findMockDisk(snapshot.DiskID)
+	if disk == nil {
+		return nil, fmt.Errorf("disk not found: %s", snapshot.DiskID)
+	}
+	if owner := mockDiskOwner(disk.DiskID); owner != nil && owner.PowerState != model.PowerStateStopped {
+		return nil, fmt.Errorf("instance %s must be stopped before restoring disk %s", owner.InstanceID, disk.DiskID)
+	}
+
+	if disk.SizeGb != snapshot.SizeGb {
+		disk.SizeGb = snapshot.SizeGb
+		updateMockCosts()
+		b.events.Publish(events.NewDiskEvent(model.EventTypeModified, disk.ProjectID, b.withInstances(disk), []string{"size_gb"}))
+	}
+
+	return b.withInstances(disk), nil
+}
+
+func (b *MockBackend) CreateDiskFromSnapshot(ctx context.Context, snapshotID string, sizeGB *int32) (*model.Disk, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snapshot := findMockSnapshot(snapshotID)
+	if snapshot == nil {
+		return nil, fmt.Errorf("snapshot not found: %s", snapshotID)
+	}
+
+	size := snapshot.SizeGb
+	if sizeGB != nil {
+		if *sizeGB < snapshot.SizeGb {
+			return nil, fmt.Errorf("disk size (%d GB) must not be smaller than snapshot size (%d GB)", *sizeGB, snapshot.SizeGb)
+		}
+		size = *sizeGB
+	}
+
+	disk := &model.Disk{
+		DiskID:    newMockID("disk", len(mockDiskList), func(id string) bool { return findMockDisk(id) != nil }),
+		SizeGb:    size,
+		Status:    "active",
+		Instances: []*model.Instance{},
+	}
+	if source := findMockDisk(snapshot.DiskID); source != nil {
+		disk.ProjectID = source.ProjectID
+		disk.Bootable = source.Bootable
+		disk.Image = source.Image
+	}
+	if err := checkMockQuota(disk.ProjectID, &model.ProjectUsage{DiskGb: size}); err != nil {
+		return nil, err
+	}
+	mockDiskList = append(mockDiskList, disk)
+
+	b.events.Publish(events.NewDiskEvent(model.EventTypeAdded, disk.ProjectID, b.withInstances(disk), nil))
+
+	return b.withInstances(disk), nil
+}
+
+// withInstances returns a copy of the disk with the instances it is attached to.
+// The caller must hold b.mu.
+func (b *MockBackend) withInstances(disk *model.Disk) *model.Disk {
+	result := *disk
+	result.Instances = []*model.Instance{}
+	if owner := mockDiskOwner(disk.DiskID); owner != nil {
+		result.Instances = append(result.Instances, snapshotInstance(owner))
+	}
+	return &result
+}
+
+// snapshotInstance returns a copy of the instance that later changes to the
+// fixtures do not reach, so it can be read after b.mu is released. Nil stays
+// nil. The caller must hold b.mu.
+func snapshotInstance(instance *model.Instance) *model.Instance {
+	if instance == nil {
+		return nil
+	}
+
+	result := *instance
+	result.AttachedDisks = make([]*model.Disk, len(instance.AttachedDisks))
+	for i, disk := range instance.AttachedDisks {
+		attached := *disk
+		result.AttachedDisks[i] = &attached
+	}
+	result.AttachedNetworks = make([]*model.Network, len(instance.AttachedNetworks))
+	for i, n := range instance.AttachedNetworks {
+		attached := *n
+		result.AttachedNetworks[i] = &attached
+	}
+	result.ExternalPorts = slices.Clone(instance.ExternalPorts)
+	result.ExternalAddresses = slices.Clone(instance.ExternalAddresses)
+	if instance.Cost != nil {
+		cost := *instance.Cost
+		result.Cost = &cost
+	}
+	return &result
+}
+
+func findMockFlavor(instanceType string) *model.BaseFlavor {
+	for _, flavor := range mockFlavorList {
+		if flavor.OriginalName == instanceType {
+			return flavor
+		}
+	}
+	return nil
+}
+
+// mockBootDisk returns the image of a new instance and the size of its boot
+// disk, the recommended size of the image unless disk_gb is given.
+func mockBootDisk(input model.NewInstanceInput) (*model.Image, int32, error) {
+	image := findMockImage(input.ProjectID, input.ImageID)
+	if image == nil {
+		return nil, 0, fmt.Errorf("unknown image %s", input.ImageID)
+	}
+	if imageImport := findMockImageImport(input.ImageID); imageImport != nil && imageImport.Optical {
+		return nil, 0, fmt.Errorf("image %s is an ISO and cannot be used as a boot disk", input.ImageID)
+	}
+
+	diskGB := image.DiskGb.Rec
+	if input.DiskGb != nil {
+		diskGB = *input.DiskGb
+		if err := requirements.CheckDiskSize(diskGB, "disk_gb"); err != nil {
+			return nil, 0, err
+		}
+	}
+	return image, diskGB, nil
+}
+
+// updateMockCosts recomputes the cost of every instance after a disk changed
+// size; fixtures share disks between instances. The caller must hold b.mu.
+func updateMockCosts() {
+	for _, instance := range Instances {
+		instance.Cost = pricing.Instance(mockPrices, instance)
+	}
+}
+
+func findMockInstance(instanceID string) *model.Instance {
+	for _, instance := range Instances {
+		if instance.InstanceID == instanceID {
+			return instance
+		}
+	}
+	return nil
+}
+
+func findMockDisk(diskID string) *model.Disk {
+	for _, disk := range mockDiskList {
+		if disk.DiskID == diskID {
+			return disk
+		}
+	}
+	return nil
+}
+
+// findMockImage looks an image up by its image_id or by the imageVerId of
+// one of its versions. Images imported into the project are found once they
+// are ready.
+func findMockImage(projectID, imageID string) *model.Image {
+	for _, image := range mockImages {
+		if image.ImageID == imageID {
+			return image
+		}
+		for _, version := range image.OsVersions {
+			if version.ImageVerID == imageID {
+				return image
+			}
+		}
+	}
+	if imageImport := findMockImageImport(imageID); imageImport != nil && imageImport.ProjectID == projectID && imageImport.Status == "READY" {
+		return imageImport.Image
+	}
+	return nil
+}
+
+// mockImageProject returns the project that imported the image, empty for
+// catalog images.
+func mockImageProject(imageID string) string {
+	if imageImport := findMockImageImport(imageID); imageImport != nil {
+		return imageImport.ProjectID
+	}
+	return ""
+}
+
+func findMockImageImport(importID string) *model.ImageImport {
+	for _, imageImport := range mockImageImports {
+		if imageImport.ImportID == importID {
+			return imageImport
+		}
+	}
+	return nil
+}
+
+func findMockNetwork(networkID string) *model.Network {
+	for _, n := range mockNetworks {
+		if n.NetworkID == networkID {
+			return n
+		}
+	}
+	return nil
+}
+
+func findMockSecurityGroup(securityGroupID string) *model.SecurityGroup {
+	for _, group := range mockSecurityGroups {
+		if group.SecurityGroupID == securityGroupID {
+			return group
+		}
+	}
+	return nil
+}
+
+func findMockSnapshot(snapshotID string) *model.Snapshot {
+	for _, snapshot := range mockSnapshots {
+		if snapshot.SnapshotID == snapshotID {
+			return snapshot
+		}
+	}
+	return nil
+}
+
+// findMockSSHKey finds a key the project can use: its own key of that name
+// or, failing that, a key of the user stored without a project. A nil user,
+// as with authentication disabled, may use anyone's key. Names matching
+// several keys are refused.
+func findMockSSHKey(projectID string, userID *string, name string) (*model.SSHKey, error) {
+	var projectKeys, personalKeys []*model.SSHKey
+	for _, stored := range mockSSHKeys {
+		if stored.Key.Name != name {
+			continue
+		}
+		if stored.ProjectID == projectID {
+			projectKeys = append(projectKeys, stored.Key)
+		} else if stored.ProjectID == "" && (userID == nil || stored.UserID == *userID) {
+			personalKeys = append(personalKeys, stored.Key)
+		}
+	}
+
+	candidates := projectKeys
+	if len(candidates) == 0 {
+		candidates = personalKeys
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("ssh key not found: %s", name)
+	case 1:
+		return candidates[0], nil
+	default:
+		return nil, fmt.Errorf("ssh key name %s is ambiguous", name)
+	}
+}
+
+// valueOrEmpty returns the value of an optional argument.
+func valueOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// hasKeyName reports whether the comma-separated key_name of an instance
+// contains the key.
+func hasKeyName(keyNames, name string) bool {
+	for _, keyName := range strings.Split(keyNames, ",") {
+		if keyName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newMockID returns the first free "<prefix>-NNN" identifier after count.
+func newMockID(prefix string, count int, exists func(string) bool) string {
+	for n := count + 1; ; n++ {
+		id := fmt.Sprintf("%s-%03d", prefix, n)
+		if !exists(id) {
+			return id
+		}
+	}
+}
+
+// mockProjectUsage sums up the resources of the project. The caller must hold b.mu.
+func mockProjectUsage(projectID string) *model.ProjectUsage {
+	instances := []*model.Instance{}
+	for _, instance := range Instances {
+		if instance.ProjectID == projectID {
+			instances = append(instances, instance)
+		}
+	}
+	disks := []*model.Disk{}
+	for _, disk := range mockDiskList {
+		if disk.ProjectID == projectID {
+			disks = append(disks, disk)
+		}
+	}
+	return usage.Compute(instances, disks)
+}
+
+// checkMockQuota refuses requests that would take the project over its
+// quota. The caller must hold b.mu.
+func checkMockQuota(projectID string, requested *model.ProjectUsage) error {
+	return quota.Check(mockQuotas.For(projectID), mockProjectUsage(projectID), requested)
+}
+
+// mockDiskOwner returns the instance the disk is attached to, if any.
+func mockDiskOwner(diskID string) *model.Instance {
+	for _, instance := range Instances {
+		for _, disk := range instance.AttachedDisks {
+			if disk.DiskID == diskID {
+				return instance
+			}
+		}
+	}
+	return nil
+}
+
+func (b *MockBackend) GetFlavorList(ctx context.Context) ([]*model.KVStringListOfFlavor, error) {
+	flavorList := make([]*model.KVStringListOfFlavor, len(mockFlavorList))
+	for i, flavor := range mockFlavorList {
+		flavorList[i] = &model.KVStringListOfFlavor{
+			Key: flavor.OriginalName,
+			Value: []model.Flavor{
+				flavor,
+			},
+		}
+	}
+	return flavorList, nil
+}
+
+func (b *MockBackend) GetCompatibleFlavors(ctx context.Context, imageID string, projectID *string) ([]*model.KVStringListOfFlavor, error) {
+	b.mu.RLock()
+	image := findMockImage(valueOrEmpty(projectID), imageID)
+	b.mu.RUnlock()
+
+	if image == nil {
+		return nil, fmt.Errorf("unknown image %s", imageID)
+	}
+
+	flavorList, err := b.GetFlavorList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return requirements.CompatibleFlavors(image, flavorList), nil
+}
+
+func (b *MockBackend) GetImageList(ctx context.Context, projectID *string) ([]*model.Image, error) {
+	if projectID == nil {
+		return mockImages, nil
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	images := append([]*model.Image(nil), mockImages...)
+	for _, imageImport := range mockImageImports {
+		if imageImport.ProjectID == *projectID && imageImport.Status == "READY" {
+			images = append(images, imageImport.Image)
+		}
+	}
+	return images, nil
+}
+
+func (b *MockBackend) GetImagesByIDs(ctx context.Context, imageIDs []string) ([]*model.Image, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	images := make([]*model.Image, len(imageIDs))
+	for i, imageID := range imageIDs {
+		// findMockImage also accepts version IDs, references carry image_id only
+		if image := findMockImage(mockImageProject(imageID), imageID); image != nil && image.ImageID == imageID {
+			images[i] = image
+		}
+	}
+	return images, nil
+}
+
+func (b *MockBackend) GetImageProject(ctx context.Context, imageID string) (string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return mockImageProject(imageID), nil
+}
+
+func (b *MockBackend) GetImageImports(ctx context.Context, projectID string) ([]*model.ImageImport, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	imports := []*model.ImageImport{}
+	for _, imageImport := range mockImageImports {
+		if imageImport.ProjectID == projectID {
+			result := *imageImport
+			imports = append(imports, &result)
+		}
+	}
+	return imports, nil
+}
+
+func (b *MockBackend) ImportImage(ctx context.Context, projectID, url, label, osVersion string, sizeGB *int32) (*model.ImageImport, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("image url must be an http or https url, got %q", url)
+	}
+
+	size := int32(20)
+	if sizeGB != nil {
+		size = *sizeGB
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("image size must be positive, got %d GB", size)
+	}
+
+	imageImport, err := b.addMockImageImport(projectID, label, osVersion, size, strings.HasSuffix(strings.ToLower(url), ".iso"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Pretend the cluster downloads the image in ten steps.
+	go func() {
+		for progress := int32(10); progress <= 100; progress += 10 {
+			time.Sleep(500 * time.Millisecond)
+			b.updateMockImageImport(imageImport.ImportID, "IMPORTING", progress)
+		}
+		b.updateMockImageImport(imageImport.ImportID, "READY", 100)
+	}()
+
+	return imageImport, nil
+}
+
+func (b *MockBackend) UploadImage(ctx context.Context, projectID string, file graphql.Upload, label, osVersion string) (*model.ImageImport, error) {
+	info, reader, err := diskimage.Detect(file.File, file.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	imageImport, err := b.addMockImageImport(projectID, label, osVersion, int32(info.SizeGB()), info.Optical())
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the whole file so that progress is reported like a real upload.
+	_, err = io.Copy(io.Discard, diskimage.NewProgressReader(reader, file.Size, func(percent int32) {
+		b.updateMockImageImport(imageImport.ImportID, "UPLOADING", percent)
+	}))
+	if err != nil {
+		b.updateMockImageImport(imageImport.ImportID, "ERROR", 0)
+		return nil, fmt.Errorf("failed to upload image: %v", err)
+	}
+	b.updateMockImageImport(imageImport.ImportID, "READY", 100)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	result := *findMockImageImport(imageImport.ImportID)
+	return &result, nil
+}
+
+// addMockImageImport registers a new import and reports it to subscribers.
+func (b *MockBackend) addMockImageImport(projectID, label, osVersion string, sizeGB int32, optical bool) (*model.ImageImport, error) {
+	if strings.TrimSpace(label) == "" {
+		return nil, fmt.Errorf("image label must not be empty")
+	}
+	if strings.TrimSpace(osVersion) == "" {
+		return nil, fmt.Errorf("image os version must not be empty")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	importID := newMockID("img-import", len(mockImageImports), func(id string) bool { return findMockImageImport(id) != nil })
+	imageImport := &model.ImageImport{
+		ImportID:  importID,
+		ProjectID: projectID,
+		Image: &model.Image{
+			ImageID:    importID,
+			Label:      label,
+			OsVersions: []*model.ImageVersion{{VersionName: osVersion, ImageVerID: importID}},
+			CPU:        &model.MinRec{Min: 1, Rec: 1},
+			RAMGb:      &model.MinRec{Min: 1, Rec: 1},
+			DiskGb:     &model.MinRec{Min: sizeGB, Rec: sizeGB},
+		},
+		SizeGb:  sizeGB,
+		Optical: optical,
+		Status:  "PENDING",
+	}
+	mockImageImports = append(mockImageImports, imageImport)
+
+	result := *imageImport
+	b.events.Publish(events.NewImageImportEvent(model.EventTypeAdded, &result, nil))
+
+	return &result, nil
+}
+
+// updateMockImageImport changes the status and progress of an import and
+// reports the changed fields.
+func (b *MockBackend) updateMockImageImport(importID, status string, progress int32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	imageImport := findMockImageImport(importID)
+	if imageImport == nil {
+		return
+	}
+
+	previous := *imageImport
+	imageImport.Status = status
+	imageImport.Progress = progress
+	if status == "ERROR" {
+		message := "image upload failed"
+		imageImport.Error = &message
+	}
+
+	changedFields := events.ImageImportChangedFields(&previous, imageImport)
+	if len(changedFields) == 0 {
+		return
+	}
+
+	result := *imageImport
+	b.events.Publish(events.NewImageImportEvent(model.EventTypeModified, &result, changedFields))
+}
+
+func (b *MockBackend) GetSSHKeys(ctx context.Context, userID, projectID *string) ([]*model.SSHKey, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	keys := []*model.SSHKey{}
+	for _, stored := range mockSSHKeys {
+		if (userID != nil && stored.UserID != *userID) || (projectID != nil && stored.ProjectID != *projectID) {
+			continue
+		}
+
+		result := *stored.Key
+		result.Instances = []*model.Instance{}
+		for _, instance := range Instances {
+			if stored.ProjectID != "" && instance.ProjectID != stored.ProjectID {
+				continue
+			}
+			if hasKeyName(instance.KeyName, stored.Key.Name) {
+				result.Instances = append(result.Instances, snapshotInstance(instance))
+			}
+		}
+		keys = append(keys, &result)
+	}
+	return keys, nil
+}
+
+func (b *MockBackend) AddSSHKey(ctx context.Context, input model.NewSSHKeyInput) (*model.SSHKey, error) {
+	parsed, err := sshkey.Parse(input.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	userID, projectID := valueOrEmpty(input.UserID), valueOrEmpty(input.ProjectID)
+	for _, stored := range mockSSHKeys {
+		if stored.UserID == userID && stored.ProjectID == projectID && stored.Key.Name == input.Name {
+			return nil, fmt.Errorf("ssh key %s already exists", input.Name)
+		}
+	}
+
+	key := &model.SSHKey{
+		Name:        input.Name,
+		PublicKey:   parsed.PublicKey,
+		Fingerprint: parsed.Fingerprint,
+		Instances:   []*model.Instance{},
+	}
+	mockSSHKeys = append(mockSSHKeys, &mockSSHKey{UserID: userID, ProjectID: projectID, Key: key})
+
+	return key, nil
+}
+
+func (b *MockBackend) DeleteSSHKey(ctx context.Context, name string, userID, projectID *string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, stored := range mockSSHKeys {
+		if stored.UserID == valueOrEmpty(userID) && stored.ProjectID == valueOrEmpty(projectID) && stored.Key.Name == name {
+			mockSSHKeys = append(mockSSHKeys[:i], mockSSHKeys[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("ssh key not found: %s", name)
+}
+
+func (b *MockBackend) GetNetworkList(ctx context.Context, projectID *string) ([]*model.Network, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	networks := []*model.Network{}
+	for _, n := range mockNetworks {
+		if projectID == nil || n.ProjectID == *projectID {
+			networks = append(networks, n)
+		}
+	}
+	return networks, nil
+}
+
+func (b *MockBackend) GetNetworksByIDs(ctx context.Context, networkIDs []string) ([]*model.Network, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	networks := make([]*model.Network, len(networkIDs))
+	for i, networkID := range networkIDs {
+		networks[i] = findMockNetwork(networkID)
+	}
+	return networks, nil
+}
+
+func (b *MockBackend) CreateNetwork(ctx context.Context, input model.NewNetworkInput) (*model.Network, error) {
+	name := strings.TrimSpace(input.NetworkName)
+	if name == "" {
+		return nil, fmt.Errorf("network name must not be empty")
+	}
+	if input.Region == "" {
+		return nil, fmt.Errorf("network region must not be empty")
+	}
+
+	subnet, err := network.ParseSubnet(input.Cidr, input.GatewayIP)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, existing := range mockNetworks {
+		if !network.Overlaps(existing.Cidr, subnet.CIDR) {
+			continue
+		}
+		// Networks of other projects are not named.
+		if existing.ProjectID != input.ProjectID {
+			return nil, fmt.Errorf("cidr %s is already in use", subnet.CIDR)
+		}
+		return nil, fmt.Errorf("cidr %s overlaps network %s", subnet.CIDR, existing.NetworkID)
+	}
+
+	securityGroupID := ""
+	if input.SecurityGroupID != nil && *input.SecurityGroupID != "" {
+		group := findMockSecurityGroup(*input.SecurityGroupID)
+		if group == nil || group.ProjectID != input.ProjectID {
+			return nil, fmt.Errorf("security group not found: %s", *input.SecurityGroupID)
+		}
+		securityGroupID = group.SecurityGroupID
+	}
+
+	created := &model.Network{
+		NetworkID:        newMockID("net", len(mockNetworks), func(id string) bool { return findMockNetwork(id) != nil }),
+		NetworkName:      name,
+		Cidr:             subnet.CIDR,
+		GatewayIP:        subnet.Gateway,
+		AvailabilityZone: input.Region,
+		Region:           input.Region,
+		SecurityGroupID:  securityGroupID,
+		ProjectID:        input.ProjectID,
+	}
+	mockNetworks = append(mockNetworks, created)
+
+	return created, nil
+}
+
+func (b *MockBackend) UpdateNetwork(ctx context.Context, networkID string, networkName, securityGroupID *string) (*model.Network, error) {
+	if networkName != nil && strings.TrimSpace(*networkName) == "" {
+		return nil, fmt.Errorf("network name must not be empty")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	updated := findMockNetwork(networkID)
+	if updated == nil {
+		return nil, fmt.Errorf("network not found: %s", networkID)
+	}
+	if securityGroupID != nil && *securityGroupID != "" {
+		group := findMockSecurityGroup(*securityGroupID)
+		if group == nil || group.ProjectID != updated.ProjectID {
+			return nil, fmt.Errorf("security group not found: %s", *securityGroupID)
+		}
+	}
+
+	if networkName != nil {
+		updated.NetworkName = strings.TrimSpace(*networkName)
+	}
+	if securityGroupID != nil {
+		updated.SecurityGroupID = *securityGroupID
+	}
+
+	return updated, nil
+}
+
+func (b *MockBackend) DeleteNetwork(ctx context.Context, networkID string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, instance := range Instances {
+		for _, attached := range instance.AttachedNetworks {
+			if attached.NetworkID == networkID {
+				return false, fmt.Errorf("network %s is attached to instance %s", networkID, instance.InstanceID)
+			}
+		}
+	}
+
+	for i, n := range mockNetworks {
+		if n.NetworkID == networkID {
+			mockNetworks = append(mockNetworks[:i], mockNetworks[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("network not found: %s", networkID)
+}
+
+func (b *MockBackend) GetSecurityGroups(ctx context.Context, projectID string) ([]*model.SecurityGroup, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	groups := []*model.SecurityGroup{}
+	for _, group := range mockSecurityGroups {
+		if group.ProjectID == projectID {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+func (b *MockBackend) GetSecurityGroup(ctx context.Context, securityGroupID string) (*model.SecurityGroup, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	group := findMockSecurityGroup(securityGroupID)
+	if group == nil {
+		return nil, fmt.Errorf("security group not found: %s", securityGroupID)
+	}
+	return group, nil
+}
+
+func (b *MockBackend) CreateSecurityGroup(ctx context.Context, input model.NewSecurityGroupInput) (*model.SecurityGroup, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, fmt.Errorf("security group name must not be empty")
+	}
+
+	rules, err := network.Rules(input.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	group := &model.SecurityGroup{
+		SecurityGroupID: newMockID("sg", len(mockSecurityGroups), func(id string) bool { return findMockSecurityGroup(id) != nil }),
+		ProjectID:       input.ProjectID,
+		Name:            name,
+		Rules:           rules,
+	}
+	mockSecurityGroups = append(mockSecurityGroups, group)
+
+	return group, nil
+}
+
+func (b *MockBackend) UpdateSecurityGroup(ctx context.Context, securityGroupID string, name *string, rules []*model.SecurityGroupRuleInput) (*model.SecurityGroup, error) {
+	if name != nil && strings.TrimSpace(*name) == "" {
+		return nil, fmt.Errorf("security group name must not be empty")
+	}
+
+	var parsed []*model.SecurityGroupRule
+	if rules != nil {
+		var err error
+		parsed, err = network.Rules(rules)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	group := findMockSecurityGroup(securityGroupID)
+	if group == nil {
+		return nil, fmt.Errorf("security group not found: %s", securityGroupID)
+	}
+
+	if name != nil {
+		group.Name = strings.TrimSpace(*name)
+	}
+	if rules != nil {
+		group.Rules = parsed
+	}
+
+	return group, nil
+}
+
+func (b *MockBackend) DeleteSecurityGroup(ctx context.Context, securityGroupID string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, n := range mockNetworks {
+		if n.SecurityGroupID == securityGroupID {
+			return false, fmt.Errorf("security group %s is used by network %s", securityGroupID, n.NetworkID)
+		}
+	}
+
+	for i, group := range mockSecurityGroups {
+		if group.SecurityGroupID == securityGroupID {
+			mockSecurityGroups = append(mockSecurityGroups[:i], mockSecurityGroups[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("security group not found: %s", securityGroupID)
+}
+
+func (b *MockBackend) GetRoleBindings(ctx context.Context, projectID string) ([]*model.RoleBinding, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return mockProjectBindings(projectID), nil
+}
+
+func (b *MockBackend) SetRoleBinding(ctx context.Context, projectID, userID string, role model.Role) (*model.RoleBinding, error) {
+	if projectID == "" || userID == "" {
+		return nil, fmt.Errorf("project_id and user_id must not be empty")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := rbac.CheckChange(mockProjectBindings(projectID), userID, role); err != nil {
+		return nil, err
+	}
+
+	for _, binding := range mockRoleBindings {
+		if binding.ProjectID == projectID && binding.UserID == userID {
+			binding.Role = role
+			return binding, nil
+		}
+	}
+
+	binding := &model.RoleBinding{ProjectID: projectID, UserID: userID, Role: role}
+	mockRoleBindings = append(mockRoleBindings, binding)
+	return binding, nil
+}
+
+func (b *MockBackend) DeleteRoleBinding(ctx context.Context, projectID, userID string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := rbac.CheckChange(mockProjectBindings(projectID), userID, ""); err != nil {
+		return false, err
+	}
+
+	for i, binding := range mockRoleBindings {
+		if binding.ProjectID == projectID && binding.UserID == userID {
+			mockRoleBindings = append(mockRoleBindings[:i], mockRoleBindings[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("role binding not found: %s", userID)
+}
+
+// mockProjectBindings returns the role bindings of the project. The caller
+// must hold b.mu.
+func mockProjectBindings(projectID string) []*model.RoleBinding {
+	bindings := []*model.RoleBinding{}
+	for _, binding := range mockRoleBindings {
+		if binding.ProjectID == projectID {
+			bindings = append(bindings, binding)
+		}
+	}
+	return bindings
+}
+
+func (b *MockBackend) Subscribe(ctx context.Context) <-chan events.Event {
+	return b.events.Subscribe(ctx)
+}
+
+// liveUpdates shuffles instance statuses every 2 seconds and reports the
+// instances whose status changed, imitating a cluster that is doing something.
+func (b *MockBackend) liveUpdates() {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		b.mu.Lock()
+		previous := make(map[string]string, len(Instances))
+		for _, instance := range Instances {
+			previous[instance.InstanceID] = instance.Status
+		}
+		var changed []*model.Instance
+		for _, instance := range mockInstanceLiveUpd() {
+			if previous[instance.InstanceID] != instance.Status {
+				changed = append(changed, snapshotInstance(instance))
+			}
+		}
+		b.mu.Unlock()
+
+		for _, instance := range changed {
+			b.events.Publish(events.NewInstanceEvent(model.EventTypeModified, instance, []string{"status"}))
+		}
+	}
+}
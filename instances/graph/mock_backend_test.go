@@ -0,0 +1,248 @@
+package graph
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"gqlfed/instances/events"
+	"gqlfed/instances/graph/model"
+)
+
+// newTestMockBackend returns a mock backend without the live status updates
+// and restores the instance and disk fixtures when the test ends.
+func newTestMockBackend(t *testing.T) *MockBackend {
+	t.Helper()
+
+	instances := slices.Clone(Instances)
+	instanceValues := make([]model.Instance, len(instances))
+	for i, instance := range instances {
+		instanceValues[i] = *instance
+	}
+	disks := slices.Clone(mockDiskList)
+	diskValues := make([]model.Disk, len(disks))
+	for i, disk := range disks {
+		diskValues[i] = *disk
+	}
+
+	t.Cleanup(func() {
+		Instances = instances
+		for i, instance := range instances {
+			*instance = instanceValues[i]
+		}
+		mockDiskList = disks
+		for i, disk := range disks {
+			*disk = diskValues[i]
+		}
+	})
+
+	return &MockBackend{events: events.NewBroker(events.DefaultBufferSize)}
+}
+
+func TestMockBackendReturnsSnapshots(t *testing.T) {
+	b := newTestMockBackend(t)
+	ctx := context.Background()
+
+	updates := b.Subscribe(ctx)
+
+	before, err := b.GetInstanceItem(ctx, "inst-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopped, err := b.StopInstance(ctx, "inst-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := <-updates
+
+	if _, err := b.StartInstance(ctx, "inst-001"); err != nil {
+		t.Fatal(err)
+	}
+
+	if before.PowerState != model.PowerStateActive {
+		t.Errorf("instance read before stopInstance has power state %s, want %s", before.PowerState, model.PowerStateActive)
+	}
+	if stopped.PowerState != model.PowerStateStopped {
+		t.Errorf("stopInstance result has power state %s after startInstance, want %s", stopped.PowerState, model.PowerStateStopped)
+	}
+	if event.Instance.PowerState != model.PowerStateStopped {
+		t.Errorf("stop event has power state %s after startInstance, want %s", event.Instance.PowerState, model.PowerStateStopped)
+	}
+}
+
+func TestMockBackendLiveUpdatesDoNotRace(t *testing.T) {
+	b := newTestMockBackend(t)
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			b.mu.Lock()
+			mockInstanceLiveUpd()
+			b.mu.Unlock()
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		instances, err := b.GetInstanceList(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, instance := range instances {
+			_ = instance.Status
+		}
+	}
+	<-done
+}
+
+func TestMockBackendDeleteInstanceDeletesBootDisk(t *testing.T) {
+	b := newTestMockBackend(t)
+	ctx := context.Background()
+
+	instance, err := b.CreateInstance(ctx, model.NewInstanceInput{
+		ProjectID:    "proj-id-001",
+		Hostname:     "delete-test",
+		Region:       "us-east-1",
+		InstanceType: "premium-4-8",
+		ImageID:      "img-001",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bootDiskID := instance.AttachedDisks[0].DiskID
+	data, err := b.CreateDisk(ctx, model.NewDiskInput{ProjectID: "proj-id-001", SizeGb: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.AttachDisk(ctx, instance.InstanceID, data.DiskID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.DeleteInstance(ctx, instance.InstanceID); err != nil {
+		t.Fatal(err)
+	}
+
+	if findMockDisk(bootDiskID) != nil {
+		t.Errorf("boot disk %s is still listed after deleteInstance", bootDiskID)
+	}
+	if findMockDisk(data.DiskID) == nil {
+		t.Errorf("data disk %s was deleted with the instance", data.DiskID)
+	}
+	if owner := mockDiskOwner(data.DiskID); owner != nil {
+		t.Errorf("data disk %s is still attached to %s", data.DiskID, owner.InstanceID)
+	}
+}
+
+func TestFindMockSSHKey(t *testing.T) {
+	saved := mockSSHKeys
+	t.Cleanup(func() { mockSSHKeys = saved })
+
+	key := func(name string) *model.SSHKey { return &model.SSHKey{Name: name} }
+	mockSSHKeys = []*mockSSHKey{
+		{UserID: "alice", Key: key("personal")},
+		{UserID: "bob", Key: key("personal")},
+		{UserID: "bob", Key: key("bobs")},
+		{UserID: "alice", ProjectID: "proj-a", Key: key("personal")},
+	}
+	alice, bob := "alice", "bob"
+
+	tests := []struct {
+		name      string
+		projectID string
+		userID    *string
+		key       string
+		wantErr   bool
+	}{
+		{name: "own personal key", projectID: "proj-b", userID: &alice, key: "personal"},
+		{name: "project key first", projectID: "proj-a", userID: &bob, key: "personal"},
+		{name: "other user's key", projectID: "proj-b", userID: &alice, key: "bobs", wantErr: true},
+		{name: "ambiguous without user", projectID: "proj-b", key: "personal", wantErr: true},
+		{name: "any user's key without user", projectID: "proj-b", key: "bobs"},
+		{name: "unknown", projectID: "proj-a", userID: &alice, key: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findMockSSHKey(tt.projectID, tt.userID, tt.key)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("findMockSSHKey(%q, %q) = %v, want an error", tt.projectID, tt.key, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != tt.key {
+				t.Errorf("findMockSSHKey(%q, %q) = %q", tt.projectID, tt.key, got.Name)
+			}
+		})
+	}
+}
+
+func TestMockFixturesStayInTheirProject(t *testing.T) {
+	owners := map[string]string{}
+	for _, instance := range Instances {
+		for i, disk := range instance.AttachedDisks {
+			if disk.ProjectID != instance.ProjectID {
+				t.Errorf("instance %s of %s has disk %s of %s", instance.InstanceID, instance.ProjectID, disk.DiskID, disk.ProjectID)
+			}
+			if owner, ok := owners[disk.DiskID]; ok {
+				t.Errorf("disk %s is attached to both %s and %s", disk.DiskID, owner, instance.InstanceID)
+			}
+			owners[disk.DiskID] = instance.InstanceID
+			if i == 0 && !disk.Bootable {
+				t.Errorf("first disk %s of instance %s is not bootable", disk.DiskID, instance.InstanceID)
+			}
+		}
+		for _, network := range instance.AttachedNetworks {
+			if network.ProjectID != instance.ProjectID {
+				t.Errorf("instance %s of %s is attached to network %s of %s", instance.InstanceID, instance.ProjectID, network.NetworkID, network.ProjectID)
+			}
+		}
+	}
+}
+
+func TestMockRoleBindingsCoverFixtureProjects(t *testing.T) {
+	bound := map[string]bool{}
+	for _, binding := range mockRoleBindings {
+		bound[binding.ProjectID] = true
+	}
+	for _, instance := range Instances {
+		if !bound[instance.ProjectID] {
+			t.Errorf("fixture project %s has no role bindings", instance.ProjectID)
+		}
+	}
+}
+
+func TestMockBackendReboot(t *testing.T) {
+	b := newTestMockBackend(t)
+	ctx := context.Background()
+
+	saved := mockRebootTime
+	mockRebootTime = time.Hour
+	t.Cleanup(func() { mockRebootTime = saved })
+
+	rebooting, err := b.RebootInstance(ctx, "inst-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebooting.PowerState != model.PowerStateRebooting {
+		t.Errorf("rebootInstance result has power state %s, want %s", rebooting.PowerState, model.PowerStateRebooting)
+	}
+
+	if _, err := b.StopInstance(ctx, "inst-002"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.ResetInstance(ctx, "inst-002"); err == nil {
+		t.Error("resetInstance of a stopped instance succeeded")
+	}
+	stopped, err := b.GetInstanceItem(ctx, "inst-002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopped.PowerState != model.PowerStateStopped {
+		t.Errorf("stopped instance has power state %s after resetInstance, want %s", stopped.PowerState, model.PowerStateStopped)
+	}
+}
@@ -3,6 +3,8 @@ package graph
 
 import (
 	"gqlfed/instances/graph/model"
+	"gqlfed/instances/pricing"
+	"gqlfed/instances/quota"
 	"math/rand"
 	"time"
 )
@@ -54,100 +56,118 @@ var mockFlavorList = []*model.BaseFlavor{
 
 var Instances = []*model.Instance{
 	{
-		InstanceID:       "inst-001",
-		ProjectID:        "proj-id-001",
-		Name:             "test-server-1",
-		Status:           "BUILDING",
-		Created:          "2024-02-03T18:30:00Z",
-		Updated:          time.Now().Format(time.RFC3339),
-		KeyName:          "default-key",
-		Flavor:           mockFlavorList[0],
-		Locked:           false,
-		PowerState:       "running",
-		IPV4:             "192.168.1.100",
-		AttachedDisks:    getRandomDisks(),
-		AttachedNetworks: getRandomNetworks(),
-		Loading:          rand.Intn(2) == 0,
-	},
-	{
-		InstanceID:       "inst-002",
-		ProjectID:        "proj-id-001",
-		Name:             "test-server-2",
-		Status:           "BUILDING",
-		Created:          "2024-02-03T19:00:00Z",
-		Updated:          time.Now().Format(time.RFC3339),
-		KeyName:          "default-key",
-		Flavor:           mockFlavorList[1],
-		Locked:           false,
-		PowerState:       "running",
-		IPV4:             "192.168.1.101",
-		AttachedDisks:    getRandomDisks(),
-		AttachedNetworks: getRandomNetworks(),
-		Loading:          rand.Intn(2) == 0,
-	},
-	{
-		InstanceID:       "inst-003",
-		ProjectID:        "proj-id-001",
-		Name:             "test-server-3",
-		Status:           "BUILDING",
-		Created:          "2024-02-03T20:00:00Z",
-		Updated:          time.Now().Format(time.RFC3339),
-		KeyName:          "default-key",
-		Flavor:           mockFlavorList[2],
-		Locked:           false,
-		PowerState:       "running",
-		IPV4:             "192.168.1.102",
-		AttachedDisks:    getRandomDisks(),
-		AttachedNetworks: getRandomNetworks(),
-		Loading:          rand.Intn(2) == 0,
-	},
-	{
-		InstanceID:       "inst-004",
-		ProjectID:        "proj-id-002",
-		Name:             "test-server-4",
-		Status:           "BUILDING",
-		Created:          "2024-02-03T21:00:00Z",
-		Updated:          time.Now().Format(time.RFC3339),
-		KeyName:          "default-key",
-		Flavor:           mockFlavorList[3],
-		Locked:           false,
-		PowerState:       "running",
-		IPV4:             "192.168.1.103",
-		AttachedDisks:    getRandomDisks(),
-		AttachedNetworks: getRandomNetworks(),
-		Loading:          rand.Intn(2) == 0,
-	},
-	{
-		InstanceID:       "inst-005",
-		ProjectID:        "proj-id-002",
-		Name:             "test-server-5",
-		Status:           "BUILDING",
-		Created:          "2024-02-03T22:00:00Z",
-		Updated:          time.Now().Format(time.RFC3339),
-		KeyName:          "default-key",
-		Flavor:           mockFlavorList[4],
-		Locked:           false,
-		PowerState:       "running",
-		IPV4:             "192.168.1.104",
-		AttachedDisks:    getRandomDisks(),
-		AttachedNetworks: getRandomNetworks(),
-		Loading:          rand.Intn(2) == 0,
-	},
-	{
-		InstanceID:       "inst-006",
-		ProjectID:        "proj-id-003",
-		Name:             "test-server-6",
-		Status:           "BUILDING",
-		Created:          "2024-02-03T23:00:00Z",
-		Updated:          time.Now().Format(time.RFC3339),
-		KeyName:          "default-key",
-		Flavor:           mockFlavorList[5],
-		Locked:           false,
-		PowerState:       "running",
-		IPV4:             "192.168.1.105",
-		AttachedDisks:    getRandomDisks(),
-		AttachedNetworks: getRandomNetworks(),
-		Loading:          rand.Intn(2) == 0,
+		InstanceID:        "inst-001",
+		ProjectID:         "proj-id-001",
+		Name:              "test-server-1",
+		Status:            "BUILDING",
+		Created:           "2024-02-03T18:30:00Z",
+		Updated:           time.Now().Format(time.RFC3339),
+		KeyName:           "default-key",
+		Flavor:            mockFlavorList[0],
+		Locked:            false,
+		PowerState:        model.PowerStateActive,
+		IPV4:              "192.168.1.100",
+		AttachedDisks:     fixtureDisks("disk-001", "disk-002"),
+		AttachedNetworks:  fixtureNetworks("net-001"),
+		ExternalMethod:    model.ExternalMethodPortList,
+		ExternalPorts:     []int32{22},
+		ExternalAddresses: []string{"192.168.1.100"},
+		Loading:           rand.Intn(2) == 0,
+	},
+	{
+		InstanceID:        "inst-002",
+		ProjectID:         "proj-id-001",
+		Name:              "test-server-2",
+		Status:            "BUILDING",
+		Created:           "2024-02-03T19:00:00Z",
+		Updated:           time.Now().Format(time.RFC3339),
+		KeyName:           "default-key",
+		Flavor:            mockFlavorList[1],
+		Locked:            false,
+		PowerState:        model.PowerStateActive,
+		IPV4:              "192.168.1.101",
+		AttachedDisks:     fixtureDisks("disk-003"),
+		AttachedNetworks:  fixtureNetworks("net-001", "net-003"),
+		ExternalMethod:    model.ExternalMethodPortList,
+		ExternalPorts:     []int32{22},
+		ExternalAddresses: []string{"192.168.1.101"},
+		Loading:           rand.Intn(2) == 0,
+	},
+	{
+		InstanceID:        "inst-003",
+		ProjectID:         "proj-id-001",
+		Name:              "test-server-3",
+		Status:            "BUILDING",
+		Created:           "2024-02-03T20:00:00Z",
+		Updated:           time.Now().Format(time.RFC3339),
+		KeyName:           "default-key",
+		Flavor:            mockFlavorList[2],
+		Locked:            false,
+		PowerState:        model.PowerStateActive,
+		IPV4:              "192.168.1.102",
+		AttachedDisks:     fixtureDisks(),
+		AttachedNetworks:  fixtureNetworks(),
+		ExternalMethod:    model.ExternalMethodPortList,
+		ExternalPorts:     []int32{22},
+		ExternalAddresses: []string{"192.168.1.102"},
+		Loading:           rand.Intn(2) == 0,
+	},
+	{
+		InstanceID:        "inst-004",
+		ProjectID:         "proj-id-002",
+		Name:              "test-server-4",
+		Status:            "BUILDING",
+		Created:           "2024-02-03T21:00:00Z",
+		Updated:           time.Now().Format(time.RFC3339),
+		KeyName:           "default-key",
+		Flavor:            mockFlavorList[3],
+		Locked:            false,
+		PowerState:        model.PowerStateActive,
+		IPV4:              "192.168.1.103",
+		AttachedDisks:     fixtureDisks("disk-005", "disk-004"),
+		AttachedNetworks:  fixtureNetworks("net-004"),
+		ExternalMethod:    model.ExternalMethodPortList,
+		ExternalPorts:     []int32{22},
+		ExternalAddresses: []string{"192.168.1.103"},
+		Loading:           rand.Intn(2) == 0,
+	},
+	{
+		InstanceID:        "inst-005",
+		ProjectID:         "proj-id-002",
+		Name:              "test-server-5",
+		Status:            "BUILDING",
+		Created:           "2024-02-03T22:00:00Z",
+		Updated:           time.Now().Format(time.RFC3339),
+		KeyName:           "default-key",
+		Flavor:            mockFlavorList[4],
+		Locked:            false,
+		PowerState:        model.PowerStateActive,
+		IPV4:              "192.168.1.104",
+		AttachedDisks:     fixtureDisks(),
+		AttachedNetworks:  fixtureNetworks("net-006"),
+		ExternalMethod:    model.ExternalMethodPortList,
+		ExternalPorts:     []int32{22},
+		ExternalAddresses: []string{"192.168.1.104"},
+		Loading:           rand.Intn(2) == 0,
+	},
+	{
+		InstanceID:        "inst-006",
+		ProjectID:         "proj-id-003",
+		Name:              "test-server-6",
+		Status:            "BUILDING",
+		Created:           "2024-02-03T23:00:00Z",
+		Updated:           time.Now().Format(time.RFC3339),
+		KeyName:           "default-key",
+		Flavor:            mockFlavorList[5],
+		Locked:            false,
+		PowerState:        model.PowerStateActive,
+		IPV4:              "192.168.1.105",
+		AttachedDisks:     fixtureDisks("disk-007", "disk-006"),
+		AttachedNetworks:  fixtureNetworks("net-007"),
+		ExternalMethod:    model.ExternalMethodPortList,
+		ExternalPorts:     []int32{22},
+		ExternalAddresses: []string{"192.168.1.105"},
+		Loading:           rand.Intn(2) == 0,
 	},
 }
 
@@ -158,22 +178,29 @@ func mockInstanceLiveUpd() []*model.Instance {
 	return Instances
 }
 
-func getRandomDisks() []*model.Disk {
-	numDisks := rand.Intn(len(mockDiskList) + 1) // Random number of disks from 0 to len(mockDiskList)
+// fixtureDisks returns the fixture disks with the given IDs, the boot disk
+// first. Each instance gets disks of its own project only.
+func fixtureDisks(diskIDs ...string) []*model.Disk {
 	disks := []*model.Disk{}
-	for i := 0; i < numDisks; i++ {
-		disk := mockDiskList[rand.Intn(len(mockDiskList))]
-		disks = append(disks, disk)
+	for _, diskID := range diskIDs {
+		for _, disk := range mockDiskList {
+			if disk.DiskID == diskID {
+				disks = append(disks, disk)
+			}
+		}
 	}
 	return disks
 }
 
-func getRandomNetworks() []*model.Network {
-	numNetworks := rand.Intn(len(mockNetworks) + 1) // Random number of networks from 0 to len(mockNetworks)
+// fixtureNetworks returns the fixture networks with the given IDs.
+func fixtureNetworks(networkIDs ...string) []*model.Network {
 	networks := []*model.Network{}
-	for i := 0; i < numNetworks; i++ {
-		network := mockNetworks[rand.Intn(len(mockNetworks))]
-		networks = append(networks, network)
+	for _, networkID := range networkIDs {
+		for _, network := range mockNetworks {
+			if network.NetworkID == networkID {
+				networks = append(networks, network)
+			}
+		}
 	}
 	return networks
 }
@@ -181,6 +208,7 @@ func getRandomNetworks() []*model.Network {
 var mockDiskList = []*model.Disk{
 	{
 		DiskID:    "disk-001",
+		ProjectID: "proj-id-001",
 		SizeGb:    50,
 		Bootable:  true,
 		Status:    "active",
@@ -189,6 +217,7 @@ var mockDiskList = []*model.Disk{
 	},
 	{
 		DiskID:    "disk-002",
+		ProjectID: "proj-id-001",
 		SizeGb:    100,
 		Bootable:  false,
 		Status:    "active",
@@ -197,6 +226,7 @@ var mockDiskList = []*model.Disk{
 	},
 	{
 		DiskID:    "disk-003",
+		ProjectID: "proj-id-001",
 		SizeGb:    200,
 		Bootable:  true,
 		Status:    "active",
@@ -205,6 +235,7 @@ var mockDiskList = []*model.Disk{
 	},
 	{
 		DiskID:    "disk-004",
+		ProjectID: "proj-id-002",
 		SizeGb:    25,
 		Bootable:  false,
 		Status:    "active",
@@ -213,6 +244,7 @@ var mockDiskList = []*model.Disk{
 	},
 	{
 		DiskID:    "disk-005",
+		ProjectID: "proj-id-002",
 		SizeGb:    75,
 		Bootable:  true,
 		Status:    "active",
@@ -221,6 +253,7 @@ var mockDiskList = []*model.Disk{
 	},
 	{
 		DiskID:    "disk-006",
+		ProjectID: "proj-id-003",
 		SizeGb:    125,
 		Bootable:  false,
 		Status:    "active",
@@ -229,6 +262,7 @@ var mockDiskList = []*model.Disk{
 	},
 	{
 		DiskID:    "disk-007",
+		ProjectID: "proj-id-003",
 		SizeGb:    175,
 		Bootable:  true,
 		Status:    "active",
@@ -237,6 +271,7 @@ var mockDiskList = []*model.Disk{
 	},
 	{
 		DiskID:    "disk-008",
+		ProjectID: "proj-id-003",
 		SizeGb:    225,
 		Bootable:  false,
 		Status:    "active",
@@ -245,19 +280,48 @@ var mockDiskList = []*model.Disk{
 	},
 }
 
-var mockSSHKeys = []*model.SSHKey{
+var mockSnapshots = []*model.Snapshot{
 	{
-		Name:      "key-001",
-		PublicKey: "ssh-rsa AAAAB3NzaC1yc2EAAAABIwAAAQEAr...",
-		Instances: []*model.Instance{},
+		SnapshotID: "snap-001",
+		ProjectID:  "proj-id-001",
+		DiskID:     "disk-001",
+		SizeGb:     50,
+		Status:     "READY",
+		Created:    "2024-01-01T00:00:00Z",
+	},
+}
+
+// mockSSHKey is a stored key together with its owner. Empty owner fields
+// mean the key is not bound to a user or a project, as in the live backend.
+type mockSSHKey struct {
+	UserID    string
+	ProjectID string
+	Key       *model.SSHKey
+}
+
+var mockSSHKeys = []*mockSSHKey{
+	{
+		UserID: "user-001",
+		Key: &model.SSHKey{
+			Name:        "key-001",
+			PublicKey:   "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIJJT5DFJJSXoANUv1r83p6Dg69nbWgdYcUW+PwYMOIuE key-001",
+			Fingerprint: "SHA256:2fOfHz4rBAw8XdyvpIrlXRn96B0QFZHgA6hPBHpC6DM",
+			Instances:   []*model.Instance{},
+		},
 	},
 	{
-		Name:      "key-002",
-		PublicKey: "ssh-rsa AAAAB3NzaC1yc2EAAAABIwAAAQEAr...",
-		Instances: []*model.Instance{},
+		UserID: "user-002",
+		Key: &model.SSHKey{
+			Name:        "key-002",
+			PublicKey:   "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIJ51M/en01DxmRjCtTOVr7LEwpu+E8eWjuUjfEuJDCdY key-002",
+			Fingerprint: "SHA256:wddgt7kGP3RHoEUymqyFZP9RozmHdEDG5Nh/GOTVP4c",
+			Instances:   []*model.Instance{},
+		},
 	},
 }
 
+var mockImageImports = []*model.ImageImport{}
+
 var mockImages = []*model.Image{
 	{
 		ImageID: "img-001",
@@ -380,6 +444,7 @@ var mockNetworks = []*model.Network{
 		AvailabilityZone: "az-1",
 		Region:           "region-1",
 		SecurityGroupID:  "sg-001",
+		ProjectID:        "proj-id-001",
 	},
 	{
 		NetworkID:        "net-002",
@@ -390,7 +455,8 @@ var mockNetworks = []*model.Network{
 		IPV4:             "10.0.0.10",
 		AvailabilityZone: "az-2",
 		Region:           "region-2",
-		SecurityGroupID:  "sg-002",
+		SecurityGroupID:  "sg-001",
+		ProjectID:        "proj-id-001",
 	},
 	{
 		NetworkID:        "net-003",
@@ -401,7 +467,8 @@ var mockNetworks = []*model.Network{
 		IPV4:             "192.168.2.10",
 		AvailabilityZone: "az-1",
 		Region:           "region-1",
-		SecurityGroupID:  "sg-003",
+		SecurityGroupID:  "sg-001",
+		ProjectID:        "proj-id-001",
 	},
 	{
 		NetworkID:        "net-004",
@@ -412,7 +479,8 @@ var mockNetworks = []*model.Network{
 		IPV4:             "10.0.1.10",
 		AvailabilityZone: "az-2",
 		Region:           "region-2",
-		SecurityGroupID:  "sg-004",
+		SecurityGroupID:  "sg-002",
+		ProjectID:        "proj-id-002",
 	},
 	{
 		NetworkID:        "net-005",
@@ -423,7 +491,8 @@ var mockNetworks = []*model.Network{
 		IPV4:             "192.168.3.10",
 		AvailabilityZone: "az-3",
 		Region:           "region-3",
-		SecurityGroupID:  "sg-005",
+		SecurityGroupID:  "sg-002",
+		ProjectID:        "proj-id-002",
 	},
 	{
 		NetworkID:        "net-006",
@@ -434,7 +503,8 @@ var mockNetworks = []*model.Network{
 		IPV4:             "10.0.2.10",
 		AvailabilityZone: "az-2",
 		Region:           "region-2",
-		SecurityGroupID:  "sg-006",
+		SecurityGroupID:  "sg-002",
+		ProjectID:        "proj-id-002",
 	},
 	{
 		NetworkID:        "net-007",
@@ -445,7 +515,8 @@ var mockNetworks = []*model.Network{
 		IPV4:             "192.168.4.10",
 		AvailabilityZone: "az-1",
 		Region:           "region-1",
-		SecurityGroupID:  "sg-007",
+		SecurityGroupID:  "sg-003",
+		ProjectID:        "proj-id-003",
 	},
 	{
 		NetworkID:        "net-008",
@@ -456,6 +527,79 @@ var mockNetworks = []*model.Network{
 		IPV4:             "10.0.3.10",
 		AvailabilityZone: "az-2",
 		Region:           "region-2",
-		SecurityGroupID:  "sg-008",
+		SecurityGroupID:  "sg-003",
+		ProjectID:        "proj-id-003",
+	},
+}
+
+var mockSecurityGroups = []*model.SecurityGroup{
+	{
+		SecurityGroupID: "sg-001",
+		ProjectID:       "proj-id-001",
+		Name:            "default",
+		Rules: []*model.SecurityGroupRule{
+			{Direction: model.TrafficDirectionIngress, Protocol: model.ProtocolTCP, PortMin: int32Ptr(22), Cidr: "0.0.0.0/0"},
+		},
+	},
+	{
+		SecurityGroupID: "sg-002",
+		ProjectID:       "proj-id-002",
+		Name:            "default",
+		Rules: []*model.SecurityGroupRule{
+			{Direction: model.TrafficDirectionIngress, Protocol: model.ProtocolTCP, PortMin: int32Ptr(22), Cidr: "0.0.0.0/0"},
+		},
+	},
+	{
+		SecurityGroupID: "sg-003",
+		ProjectID:       "proj-id-003",
+		Name:            "web",
+		Rules: []*model.SecurityGroupRule{
+			{Direction: model.TrafficDirectionIngress, Protocol: model.ProtocolTCP, PortMin: int32Ptr(80), Cidr: "0.0.0.0/0"},
+			{Direction: model.TrafficDirectionIngress, Protocol: model.ProtocolTCP, PortMin: int32Ptr(443), Cidr: "0.0.0.0/0"},
+			{Direction: model.TrafficDirectionIngress, Protocol: model.ProtocolAny, Cidr: "10.0.0.0/8"},
+		},
+	},
+}
+
+// mockRoleBindings binds roles in every fixture project, so no member of a
+// project listed in a token becomes its admin just for lacking bindings.
+var mockRoleBindings = []*model.RoleBinding{
+	{ProjectID: "proj-id-001", UserID: "user-001", Role: model.RoleAdmin},
+	{ProjectID: "proj-id-001", UserID: "user-002", Role: model.RoleViewer},
+	{ProjectID: "proj-id-002", UserID: "user-002", Role: model.RoleAdmin},
+	{ProjectID: "proj-id-002", UserID: "user-001", Role: model.RoleOperator},
+	{ProjectID: "proj-id-003", UserID: "user-001", Role: model.RoleAdmin},
+	{ProjectID: "proj-id-003", UserID: "user-002", Role: model.RoleViewer},
+}
+
+// mockPrices prices disks and public IPs next to the flavors' rub_month.
+var mockPrices = pricing.Prices{
+	DiskGB:   8,
+	PublicIP: 150,
+}
+
+// mockQuotas keeps proj-id-003 close to its instance and disk limits, so
+// quota errors can be tried out there.
+var mockQuotas = &quota.Config{
+	Default: model.QuotaLimits{
+		Instances: int32Ptr(20),
+		Vcpus:     int32Ptr(64),
+		RAMGb:     float64Ptr(256),
+		DiskGb:    int32Ptr(2000),
+		PublicIps: int32Ptr(10),
+	},
+	Projects: map[string]model.QuotaLimits{
+		"proj-id-003": {
+			Instances: int32Ptr(2),
+			DiskGb:    int32Ptr(600),
+		},
 	},
 }
+
+func int32Ptr(v int32) *int32 {
+	return &v
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
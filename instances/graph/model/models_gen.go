@@ -2,6 +2,12 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
 type Flavor interface {
 	IsFlavor()
 }
@@ -15,8 +21,18 @@ type BaseFlavor struct {
 
 func (BaseFlavor) IsFlavor() {}
 
+type Cost struct {
+	Currency        string  `json:"currency"`
+	Hourly          float64 `json:"hourly"`
+	Monthly         float64 `json:"monthly"`
+	FlavorMonthly   float64 `json:"flavor_monthly"`
+	DiskMonthly     float64 `json:"disk_monthly"`
+	PublicIPMonthly float64 `json:"public_ip_monthly"`
+}
+
 type Disk struct {
 	DiskID    string      `json:"disk_id"`
+	ProjectID string      `json:"project_id"`
 	SizeGb    int32       `json:"size_gb"`
 	Bootable  bool        `json:"bootable"`
 	Status    string      `json:"status"`
@@ -24,6 +40,18 @@ type Disk struct {
 	Image     *Image      `json:"image,omitempty"`
 }
 
+func (Disk) IsEntity() {}
+
+type DiskByDiskIDsInput struct {
+	DiskID string `json:"DiskID"`
+}
+
+type DiskEvent struct {
+	Type          EventType `json:"type"`
+	Disk          *Disk     `json:"disk"`
+	ChangedFields []string  `json:"changedFields"`
+}
+
 type HiFreqFlavor struct {
 	OriginalName string `json:"original_name"`
 	Vcpus        string `json:"vcpus"`
@@ -42,26 +70,65 @@ type Image struct {
 	DiskGb     *MinRec         `json:"disk_gb"`
 }
 
+func (Image) IsEntity() {}
+
+type ImageByImageIDsInput struct {
+	ImageID string `json:"ImageID"`
+}
+
+type ImageImport struct {
+	ImportID  string  `json:"import_id"`
+	ProjectID string  `json:"project_id"`
+	Image     *Image  `json:"image"`
+	SizeGb    int32   `json:"size_gb"`
+	Optical   bool    `json:"optical"`
+	Status    string  `json:"status"`
+	Progress  int32   `json:"progress"`
+	Error     *string `json:"error,omitempty"`
+}
+
+type ImageImportEvent struct {
+	Type          EventType    `json:"type"`
+	ImageImport   *ImageImport `json:"imageImport"`
+	ChangedFields []string     `json:"changedFields"`
+}
+
 type ImageVersion struct {
 	VersionName string `json:"versionName"`
 	ImageVerID  string `json:"imageVerId"`
 }
 
 type Instance struct {
-	InstanceID       string     `json:"instance_id"`
-	ProjectID        string     `json:"project_id"`
-	Name             string     `json:"name"`
-	Status           string     `json:"status"`
-	Created          string     `json:"created"`
-	Updated          string     `json:"updated"`
-	KeyName          string     `json:"key_name"`
-	Flavor           Flavor     `json:"flavor"`
-	Locked           bool       `json:"locked"`
-	Loading          bool       `json:"loading"`
-	PowerState       string     `json:"power_state"`
-	IPV4             string     `json:"ipV4"`
-	AttachedDisks    []*Disk    `json:"attachedDisks"`
-	AttachedNetworks []*Network `json:"attachedNetworks"`
+	InstanceID        string         `json:"instance_id"`
+	ProjectID         string         `json:"project_id"`
+	Name              string         `json:"name"`
+	Status            string         `json:"status"`
+	Created           string         `json:"created"`
+	Updated           string         `json:"updated"`
+	KeyName           string         `json:"key_name"`
+	Flavor            Flavor         `json:"flavor"`
+	Locked            bool           `json:"locked"`
+	Loading           bool           `json:"loading"`
+	PowerState        PowerState     `json:"power_state"`
+	IPV4              string         `json:"ipV4"`
+	AttachedDisks     []*Disk        `json:"attachedDisks"`
+	AttachedNetworks  []*Network     `json:"attachedNetworks"`
+	ExternalMethod    ExternalMethod `json:"external_method"`
+	ExternalPorts     []int32        `json:"external_ports"`
+	ExternalAddresses []string       `json:"external_addresses"`
+	Cost              *Cost          `json:"cost"`
+}
+
+func (Instance) IsEntity() {}
+
+type InstanceByInstanceIDsInput struct {
+	InstanceID string `json:"InstanceID"`
+}
+
+type InstanceEvent struct {
+	Type          EventType `json:"type"`
+	Instance      *Instance `json:"instance"`
+	ChangedFields []string  `json:"changedFields"`
 }
 
 type KVStringListOfFlavor struct {
@@ -87,15 +154,56 @@ type Network struct {
 	AvailabilityZone string `json:"availability_zone"`
 	Region           string `json:"region"`
 	SecurityGroupID  string `json:"security_group_id"`
+	ProjectID        string `json:"project_id"`
+}
+
+func (Network) IsEntity() {}
+
+type NetworkByNetworkIDsInput struct {
+	NetworkID string `json:"NetworkID"`
+}
+
+type NewDiskInput struct {
+	ProjectID string  `json:"project_id"`
+	SizeGb    int32   `json:"size_gb"`
+	ImageID   *string `json:"image_id,omitempty"`
 }
 
 type NewInstanceInput struct {
-	ID           string `json:"id"`
-	Hostname     string `json:"hostname"`
-	Region       string `json:"region"`
-	InstanceType string `json:"instanceType"`
-	ImageID      string `json:"imageId"`
-	State        string `json:"state"`
+	ProjectID      string          `json:"project_id"`
+	Hostname       string          `json:"hostname"`
+	Region         string          `json:"region"`
+	InstanceType   string          `json:"instanceType"`
+	ImageID        string          `json:"imageId"`
+	State          string          `json:"state"`
+	KeyNames       []string        `json:"key_names,omitempty"`
+	UserData       *string         `json:"userData,omitempty"`
+	DiskGb         *int32          `json:"disk_gb,omitempty"`
+	NetworkIds     []string        `json:"network_ids,omitempty"`
+	ExternalMethod *ExternalMethod `json:"external_method,omitempty"`
+	ExternalPorts  []int32         `json:"external_ports,omitempty"`
+}
+
+type NewNetworkInput struct {
+	ProjectID       string  `json:"project_id"`
+	NetworkName     string  `json:"network_name"`
+	Region          string  `json:"region"`
+	Cidr            string  `json:"cidr"`
+	GatewayIP       *string `json:"gateway_ip,omitempty"`
+	SecurityGroupID *string `json:"security_group_id,omitempty"`
+}
+
+type NewSSHKeyInput struct {
+	Name      string  `json:"name"`
+	PublicKey string  `json:"publicKey"`
+	UserID    *string `json:"user_id,omitempty"`
+	ProjectID *string `json:"project_id,omitempty"`
+}
+
+type NewSecurityGroupInput struct {
+	ProjectID string                    `json:"project_id"`
+	Name      string                    `json:"name"`
+	Rules     []*SecurityGroupRuleInput `json:"rules,omitempty"`
 }
 
 type PremiumFlavor struct {
@@ -116,23 +224,359 @@ type ProFlavor struct {
 
 func (ProFlavor) IsFlavor() {}
 
+type Project struct {
+	ProjectID   string        `json:"project_id"`
+	Instances   []*Instance   `json:"instances"`
+	Disks       []*Disk       `json:"disks"`
+	Networks    []*Network    `json:"networks"`
+	Usage       *ProjectUsage `json:"usage"`
+	MonthlyCost float64       `json:"monthlyCost"`
+}
+
+func (Project) IsEntity() {}
+
+type ProjectByProjectIDsInput struct {
+	ProjectID string `json:"ProjectID"`
+}
+
+type ProjectQuota struct {
+	ProjectID string        `json:"project_id"`
+	Limits    *QuotaLimits  `json:"limits"`
+	Usage     *ProjectUsage `json:"usage"`
+}
+
+type ProjectUsage struct {
+	Instances int32   `json:"instances"`
+	Vcpus     int32   `json:"vcpus"`
+	RAMGb     float64 `json:"ram_gb"`
+	DiskGb    int32   `json:"disk_gb"`
+	PublicIps int32   `json:"public_ips"`
+}
+
 type Query struct {
 }
 
+type QuotaLimits struct {
+	Instances *int32   `json:"instances,omitempty"`
+	Vcpus     *int32   `json:"vcpus,omitempty"`
+	RAMGb     *float64 `json:"ram_gb,omitempty"`
+	DiskGb    *int32   `json:"disk_gb,omitempty"`
+	PublicIps *int32   `json:"public_ips,omitempty"`
+}
+
+type RoleBinding struct {
+	ProjectID string `json:"project_id"`
+	UserID    string `json:"user_id"`
+	Role      Role   `json:"role"`
+}
+
 type SSHKey struct {
-	Name      string      `json:"name"`
-	PublicKey string      `json:"publicKey"`
-	Instances []*Instance `json:"instances,omitempty"`
+	Name        string      `json:"name"`
+	PublicKey   string      `json:"publicKey"`
+	Fingerprint string      `json:"fingerprint"`
+	Instances   []*Instance `json:"instances,omitempty"`
+}
+
+type SecurityGroup struct {
+	SecurityGroupID string               `json:"security_group_id"`
+	ProjectID       string               `json:"project_id"`
+	Name            string               `json:"name"`
+	Rules           []*SecurityGroupRule `json:"rules"`
+}
+
+type SecurityGroupRule struct {
+	Direction TrafficDirection `json:"direction"`
+	Protocol  Protocol         `json:"protocol"`
+	PortMin   *int32           `json:"port_min,omitempty"`
+	PortMax   *int32           `json:"port_max,omitempty"`
+	Cidr      string           `json:"cidr"`
+}
+
+type SecurityGroupRuleInput struct {
+	Direction TrafficDirection `json:"direction"`
+	Protocol  Protocol         `json:"protocol"`
+	PortMin   *int32           `json:"port_min,omitempty"`
+	PortMax   *int32           `json:"port_max,omitempty"`
+	Cidr      string           `json:"cidr"`
+}
+
+type Snapshot struct {
+	SnapshotID string `json:"snapshot_id"`
+	ProjectID  string `json:"project_id"`
+	DiskID     string `json:"disk_id"`
+	SizeGb     int32  `json:"size_gb"`
+	Status     string `json:"status"`
+	Created    string `json:"created"`
 }
 
 type Subscription struct {
 }
 
 type User struct {
-	UserID    string    `json:"user_id"`
-	UserName  string    `json:"user_name"`
-	CompanyID string    `json:"company_id"`
-	SSHKeys   []*SSHKey `json:"sshKeys,omitempty"`
+	UserID  string    `json:"user_id"`
+	SSHKeys []*SSHKey `json:"sshKeys,omitempty"`
 }
 
 func (User) IsEntity() {}
+
+type EventType string
+
+const (
+	EventTypeAdded    EventType = "ADDED"
+	EventTypeModified EventType = "MODIFIED"
+	EventTypeDeleted  EventType = "DELETED"
+)
+
+var AllEventType = []EventType{
+	EventTypeAdded,
+	EventTypeModified,
+	EventTypeDeleted,
+}
+
+func (e EventType) IsValid() bool {
+	switch e {
+	case EventTypeAdded, EventTypeModified, EventTypeDeleted:
+		return true
+	}
+	return false
+}
+
+func (e EventType) String() string {
+	return string(e)
+}
+
+func (e *EventType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = EventType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid EventType", str)
+	}
+	return nil
+}
+
+func (e EventType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ExternalMethod string
+
+const (
+	ExternalMethodPortList ExternalMethod = "PORT_LIST"
+	ExternalMethodWholeIP  ExternalMethod = "WHOLE_IP"
+)
+
+var AllExternalMethod = []ExternalMethod{
+	ExternalMethodPortList,
+	ExternalMethodWholeIP,
+}
+
+func (e ExternalMethod) IsValid() bool {
+	switch e {
+	case ExternalMethodPortList, ExternalMethodWholeIP:
+		return true
+	}
+	return false
+}
+
+func (e ExternalMethod) String() string {
+	return string(e)
+}
+
+func (e *ExternalMethod) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ExternalMethod(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ExternalMethod", str)
+	}
+	return nil
+}
+
+func (e ExternalMethod) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type PowerState string
+
+const (
+	PowerStateStarting  PowerState = "STARTING"
+	PowerStateActive    PowerState = "ACTIVE"
+	PowerStateRebooting PowerState = "REBOOTING"
+	PowerStateStopping  PowerState = "STOPPING"
+	PowerStateStopped   PowerState = "STOPPED"
+	PowerStateUnknown   PowerState = "UNKNOWN"
+)
+
+var AllPowerState = []PowerState{
+	PowerStateStarting,
+	PowerStateActive,
+	PowerStateRebooting,
+	PowerStateStopping,
+	PowerStateStopped,
+	PowerStateUnknown,
+}
+
+func (e PowerState) IsValid() bool {
+	switch e {
+	case PowerStateStarting, PowerStateActive, PowerStateRebooting, PowerStateStopping, PowerStateStopped, PowerStateUnknown:
+		return true
+	}
+	return false
+}
+
+func (e PowerState) String() string {
+	return string(e)
+}
+
+func (e *PowerState) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PowerState(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PowerState", str)
+	}
+	return nil
+}
+
+func (e PowerState) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Protocol string
+
+const (
+	ProtocolTCP  Protocol = "TCP"
+	ProtocolUDP  Protocol = "UDP"
+	ProtocolSctp Protocol = "SCTP"
+	ProtocolAny  Protocol = "ANY"
+)
+
+var AllProtocol = []Protocol{
+	ProtocolTCP,
+	ProtocolUDP,
+	ProtocolSctp,
+	ProtocolAny,
+}
+
+func (e Protocol) IsValid() bool {
+	switch e {
+	case ProtocolTCP, ProtocolUDP, ProtocolSctp, ProtocolAny:
+		return true
+	}
+	return false
+}
+
+func (e Protocol) String() string {
+	return string(e)
+}
+
+func (e *Protocol) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Protocol(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Protocol", str)
+	}
+	return nil
+}
+
+func (e Protocol) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Role string
+
+const (
+	RoleViewer   Role = "VIEWER"
+	RoleOperator Role = "OPERATOR"
+	RoleAdmin    Role = "ADMIN"
+)
+
+var AllRole = []Role{
+	RoleViewer,
+	RoleOperator,
+	RoleAdmin,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleViewer, RoleOperator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type TrafficDirection string
+
+const (
+	TrafficDirectionIngress TrafficDirection = "INGRESS"
+	TrafficDirectionEgress  TrafficDirection = "EGRESS"
+)
+
+var AllTrafficDirection = []TrafficDirection{
+	TrafficDirectionIngress,
+	TrafficDirectionEgress,
+}
+
+func (e TrafficDirection) IsValid() bool {
+	switch e {
+	case TrafficDirectionIngress, TrafficDirectionEgress:
+		return true
+	}
+	return false
+}
+
+func (e TrafficDirection) String() string {
+	return string(e)
+}
+
+func (e *TrafficDirection) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TrafficDirection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TrafficDirection", str)
+	}
+	return nil
+}
+
+func (e TrafficDirection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -1,12 +1,71 @@
 package graph
 
 //go:generate go run github.com/99designs/gqlgen generate
-import "gqlfed/instances/graph/model"
+import (
+	"context"
+	"gqlfed/instances/events"
+)
 
 // This file will not be regenerated automatically.
 //
 // It serves as dependency injection for your app, add any dependencies you require here.
 
 type Resolver struct {
-	instances []*model.Instance
+	Backend Backend
+}
+
+// drainEvents discards events that are already queued, so that a burst of
+// changes results in a single list being pushed to the client.
+func drainEvents(updates <-chan events.Event) {
+	for {
+		select {
+		case _, ok := <-updates:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// forwardEvents converts backend events into subscription payloads until ctx
+// is cancelled or the backend closes the channel. Events for which convert
+// returns false are skipped.
+func forwardEvents[T any](ctx context.Context, updates <-chan events.Event, convert func(events.Event) (T, bool)) <-chan T {
+	out := make(chan T, 1)
+
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-updates:
+				if !ok {
+					return
+				}
+				payload, ok := convert(event)
+				if !ok {
+					continue
+				}
+
+				select {
+				case out <- payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// nonNilFields makes sure a list of changed fields is serialized as [] rather than null.
+func nonNilFields(fields []string) []string {
+	if fields == nil {
+		return []string{}
+	}
+	return fields
 }
@@ -7,83 +7,353 @@ package graph
 import (
 	"context"
 	"fmt"
+	"gqlfed/instances/events"
 	"gqlfed/instances/graph/model"
-	"time"
+
+	"github.com/99designs/gqlgen/graphql"
 )
 
 // DeleteInstance is the resolver for the deleteInstance field.
 func (r *mutationResolver) DeleteInstance(ctx context.Context, instanceID string) (bool, error) {
-	for i, instance := range Instances {
-		if instance.InstanceID == instanceID {
-			Instances = append(Instances[:i], Instances[i+1:]...)
-			return true, nil
-		}
-	}
-	return false, fmt.Errorf("instance not found: %s", instanceID)
+	return r.Backend.DeleteInstance(ctx, instanceID)
 }
 
 // CreateInstance is the resolver for the createInstance field.
 func (r *mutationResolver) CreateInstance(ctx context.Context, input model.NewInstanceInput) (*model.Instance, error) {
-	panic(fmt.Errorf("not implemented: CreateInstance - createInstance"))
+	return r.Backend.CreateInstance(ctx, input)
+}
+
+// StartInstance is the resolver for the startInstance field.
+func (r *mutationResolver) StartInstance(ctx context.Context, instanceID string) (*model.Instance, error) {
+	return r.Backend.StartInstance(ctx, instanceID)
+}
+
+// StopInstance is the resolver for the stopInstance field.
+func (r *mutationResolver) StopInstance(ctx context.Context, instanceID string) (*model.Instance, error) {
+	return r.Backend.StopInstance(ctx, instanceID)
+}
+
+// RebootInstance is the resolver for the rebootInstance field.
+func (r *mutationResolver) RebootInstance(ctx context.Context, instanceID string) (*model.Instance, error) {
+	return r.Backend.RebootInstance(ctx, instanceID)
+}
+
+// ResetInstance is the resolver for the resetInstance field.
+func (r *mutationResolver) ResetInstance(ctx context.Context, instanceID string) (*model.Instance, error) {
+	return r.Backend.ResetInstance(ctx, instanceID)
+}
+
+// CreateDisk is the resolver for the createDisk field.
+func (r *mutationResolver) CreateDisk(ctx context.Context, input model.NewDiskInput) (*model.Disk, error) {
+	return r.Backend.CreateDisk(ctx, input)
+}
+
+// ResizeDisk is the resolver for the resizeDisk field.
+func (r *mutationResolver) ResizeDisk(ctx context.Context, diskID string, sizeGb int32) (*model.Disk, error) {
+	return r.Backend.ResizeDisk(ctx, diskID, sizeGb)
+}
+
+// DeleteDisk is the resolver for the deleteDisk field.
+func (r *mutationResolver) DeleteDisk(ctx context.Context, diskID string) (bool, error) {
+	return r.Backend.DeleteDisk(ctx, diskID)
+}
+
+// AttachDisk is the resolver for the attachDisk field.
+func (r *mutationResolver) AttachDisk(ctx context.Context, instanceID string, diskID string) (*model.Instance, error) {
+	return r.Backend.AttachDisk(ctx, instanceID, diskID)
+}
+
+// DetachDisk is the resolver for the detachDisk field.
+func (r *mutationResolver) DetachDisk(ctx context.Context, instanceID string, diskID string) (*model.Instance, error) {
+	return r.Backend.DetachDisk(ctx, instanceID, diskID)
+}
+
+// SetInstancePorts is the resolver for the setInstancePorts field.
+func (r *mutationResolver) SetInstancePorts(ctx context.Context, instanceID string, ports []int32, method model.ExternalMethod) (*model.Instance, error) {
+	return r.Backend.SetInstancePorts(ctx, instanceID, ports, method)
+}
+
+// CreateSnapshot is the resolver for the createSnapshot field.
+func (r *mutationResolver) CreateSnapshot(ctx context.Context, diskID string) (*model.Snapshot, error) {
+	return r.Backend.CreateSnapshot(ctx, diskID)
+}
+
+// DeleteSnapshot is the resolver for the deleteSnapshot field.
+func (r *mutationResolver) DeleteSnapshot(ctx context.Context, snapshotID string) (bool, error) {
+	return r.Backend.DeleteSnapshot(ctx, snapshotID)
+}
+
+// RestoreSnapshot is the resolver for the restoreSnapshot field.
+func (r *mutationResolver) RestoreSnapshot(ctx context.Context, snapshotID string) (*model.Disk, error) {
+	return r.Backend.RestoreSnapshot(ctx, snapshotID)
+}
+
+// CreateDiskFromSnapshot is the resolver for the createDiskFromSnapshot field.
+func (r *mutationResolver) CreateDiskFromSnapshot(ctx context.Context, snapshotID string, sizeGb *int32) (*model.Disk, error) {
+	return r.Backend.CreateDiskFromSnapshot(ctx, snapshotID, sizeGb)
+}
+
+// AddSSHKey is the resolver for the addSSHKey field.
+func (r *mutationResolver) AddSSHKey(ctx context.Context, input model.NewSSHKeyInput) (*model.SSHKey, error) {
+	if input.ProjectID == nil {
+		userID, err := ownUserID(ctx, input.UserID)
+		if err != nil {
+			return nil, err
+		}
+		input.UserID = userID
+	}
+	return r.Backend.AddSSHKey(ctx, input)
+}
+
+// DeleteSSHKey is the resolver for the deleteSSHKey field.
+func (r *mutationResolver) DeleteSSHKey(ctx context.Context, name string, userID *string, projectID *string) (bool, error) {
+	if projectID == nil {
+		var err error
+		if userID, err = ownUserID(ctx, userID); err != nil {
+			return false, err
+		}
+	}
+	return r.Backend.DeleteSSHKey(ctx, name, userID, projectID)
+}
+
+// ImportImage is the resolver for the importImage field.
+func (r *mutationResolver) ImportImage(ctx context.Context, projectID string, url string, label string, osVersion string, sizeGb *int32) (*model.ImageImport, error) {
+	return r.Backend.ImportImage(ctx, projectID, url, label, osVersion, sizeGb)
+}
+
+// UploadImage is the resolver for the uploadImage field.
+func (r *mutationResolver) UploadImage(ctx context.Context, projectID string, file graphql.Upload, label string, osVersion string) (*model.ImageImport, error) {
+	return r.Backend.UploadImage(ctx, projectID, file, label, osVersion)
+}
+
+// CreateNetwork is the resolver for the createNetwork field.
+func (r *mutationResolver) CreateNetwork(ctx context.Context, input model.NewNetworkInput) (*model.Network, error) {
+	return r.Backend.CreateNetwork(ctx, input)
+}
+
+// UpdateNetwork is the resolver for the updateNetwork field.
+func (r *mutationResolver) UpdateNetwork(ctx context.Context, networkID string, networkName *string, securityGroupID *string) (*model.Network, error) {
+	return r.Backend.UpdateNetwork(ctx, networkID, networkName, securityGroupID)
+}
+
+// DeleteNetwork is the resolver for the deleteNetwork field.
+func (r *mutationResolver) DeleteNetwork(ctx context.Context, networkID string) (bool, error) {
+	return r.Backend.DeleteNetwork(ctx, networkID)
+}
+
+// CreateSecurityGroup is the resolver for the createSecurityGroup field.
+func (r *mutationResolver) CreateSecurityGroup(ctx context.Context, input model.NewSecurityGroupInput) (*model.SecurityGroup, error) {
+	return r.Backend.CreateSecurityGroup(ctx, input)
+}
+
+// UpdateSecurityGroup is the resolver for the updateSecurityGroup field.
+func (r *mutationResolver) UpdateSecurityGroup(ctx context.Context, securityGroupID string, name *string, rules []*model.SecurityGroupRuleInput) (*model.SecurityGroup, error) {
+	return r.Backend.UpdateSecurityGroup(ctx, securityGroupID, name, rules)
+}
+
+// DeleteSecurityGroup is the resolver for the deleteSecurityGroup field.
+func (r *mutationResolver) DeleteSecurityGroup(ctx context.Context, securityGroupID string) (bool, error) {
+	return r.Backend.DeleteSecurityGroup(ctx, securityGroupID)
+}
+
+// SetRoleBinding is the resolver for the setRoleBinding field.
+func (r *mutationResolver) SetRoleBinding(ctx context.Context, projectID string, userID string, role model.Role) (*model.RoleBinding, error) {
+	return r.Backend.SetRoleBinding(ctx, projectID, userID, role)
+}
+
+// DeleteRoleBinding is the resolver for the deleteRoleBinding field.
+func (r *mutationResolver) DeleteRoleBinding(ctx context.Context, projectID string, userID string) (bool, error) {
+	return r.Backend.DeleteRoleBinding(ctx, projectID, userID)
+}
+
+// GetProject is the resolver for the getProject field.
+func (r *queryResolver) GetProject(ctx context.Context, projectID string) (*model.Project, error) {
+	projects, err := r.Backend.GetProjects(ctx, []string{projectID})
+	if err != nil {
+		return nil, err
+	}
+	if projects[0] == nil {
+		return nil, fmt.Errorf("project_id must not be empty")
+	}
+	return projects[0], nil
+}
+
+// GetProjectQuota is the resolver for the getProjectQuota field.
+func (r *queryResolver) GetProjectQuota(ctx context.Context, projectID string) (*model.ProjectQuota, error) {
+	return r.Backend.GetProjectQuota(ctx, projectID)
 }
 
 // GetInstanceList is the resolver for the getInstanceList field.
 func (r *queryResolver) GetInstanceList(ctx context.Context, projectID string) ([]*model.Instance, error) {
-	return Instances, nil
+	return r.Backend.GetInstanceList(ctx, projectID)
 }
 
 // GetInstanceItem is the resolver for the getInstanceItem field.
 func (r *queryResolver) GetInstanceItem(ctx context.Context, instanceID string) (*model.Instance, error) {
-	for _, instance := range mockInstanceLiveUpd() {
-		if instance.InstanceID == instanceID {
-			return instance, nil
-		}
-	}
-	return nil, fmt.Errorf("instance not found: %s", instanceID)
+	return r.Backend.GetInstanceItem(ctx, instanceID)
+}
+
+// GetDiskList is the resolver for the getDiskList field.
+func (r *queryResolver) GetDiskList(ctx context.Context, projectID string) ([]*model.Disk, error) {
+	return r.Backend.GetDiskList(ctx, projectID)
+}
+
+// GetDisk is the resolver for the getDisk field.
+func (r *queryResolver) GetDisk(ctx context.Context, diskID string) (*model.Disk, error) {
+	return r.Backend.GetDisk(ctx, diskID)
+}
+
+// ListSnapshots is the resolver for the listSnapshots field.
+func (r *queryResolver) ListSnapshots(ctx context.Context, projectID string, diskID *string) ([]*model.Snapshot, error) {
+	return r.Backend.ListSnapshots(ctx, projectID, diskID)
 }
 
 // GetFlavorList is the resolver for the getFlavorList field.
 func (r *queryResolver) GetFlavorList(ctx context.Context) ([]*model.KVStringListOfFlavor, error) {
-	// Convert mockFlavorList to []*model.KVStringListOfFlavor
-	flavorList := make([]*model.KVStringListOfFlavor, len(mockFlavorList))
-	for i, flavor := range mockFlavorList {
-		flavorList[i] = &model.KVStringListOfFlavor{
-			Key: flavor.OriginalName,
-			Value: []model.Flavor{
-				flavor,
-			},
-		}
-	}
-	return flavorList, nil
+	return r.Backend.GetFlavorList(ctx)
+}
+
+// GetCompatibleFlavors is the resolver for the getCompatibleFlavors field.
+func (r *queryResolver) GetCompatibleFlavors(ctx context.Context, imageID string, projectID *string) ([]*model.KVStringListOfFlavor, error) {
+	return r.Backend.GetCompatibleFlavors(ctx, imageID, projectID)
+}
+
+// EstimateInstanceCost is the resolver for the estimateInstanceCost field.
+func (r *queryResolver) EstimateInstanceCost(ctx context.Context, input model.NewInstanceInput) (*model.Cost, error) {
+	return r.Backend.EstimateInstanceCost(ctx, input)
 }
 
 // GetImageList is the resolver for the getImageList field.
-func (r *queryResolver) GetImageList(ctx context.Context) ([]*model.Image, error) {
-	return mockImages, nil
+func (r *queryResolver) GetImageList(ctx context.Context, projectID *string) ([]*model.Image, error) {
+	return r.Backend.GetImageList(ctx, projectID)
+}
+
+// GetImageImports is the resolver for the getImageImports field.
+func (r *queryResolver) GetImageImports(ctx context.Context, projectID string) ([]*model.ImageImport, error) {
+	return r.Backend.GetImageImports(ctx, projectID)
 }
 
 // GetSSHKeys is the resolver for the getSSHKeys field.
-func (r *queryResolver) GetSSHKeys(ctx context.Context) ([]*model.SSHKey, error) {
-	return mockSSHKeys, nil
+func (r *queryResolver) GetSSHKeys(ctx context.Context, userID *string, projectID *string) ([]*model.SSHKey, error) {
+	if projectID == nil {
+		var err error
+		if userID, err = ownUserID(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := r.Backend.GetSSHKeys(ctx, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return r.visibleKeyInstances(ctx, keys), nil
 }
 
 // GetNetworkList is the resolver for the getNetworkList field.
-func (r *queryResolver) GetNetworkList(ctx context.Context) ([]*model.Network, error) {
-	return mockNetworks, nil
+func (r *queryResolver) GetNetworkList(ctx context.Context, projectID *string) ([]*model.Network, error) {
+	networks, err := r.Backend.GetNetworkList(ctx, projectID)
+	if err != nil || projectID != nil {
+		return networks, err
+	}
+	return visibleTo(ctx, r.Resolver, networks, func(network *model.Network) string { return network.ProjectID }), nil
+}
+
+// GetSecurityGroups is the resolver for the getSecurityGroups field.
+func (r *queryResolver) GetSecurityGroups(ctx context.Context, projectID string) ([]*model.SecurityGroup, error) {
+	return r.Backend.GetSecurityGroups(ctx, projectID)
+}
+
+// GetRoleBindings is the resolver for the getRoleBindings field.
+func (r *queryResolver) GetRoleBindings(ctx context.Context, projectID string) ([]*model.RoleBinding, error) {
+	return r.Backend.GetRoleBindings(ctx, projectID)
 }
 
 // InstancesUpdates is the resolver for the instancesUpdates field.
 func (r *subscriptionResolver) InstancesUpdates(ctx context.Context) (<-chan []*model.Instance, error) {
+	updates := r.Backend.Subscribe(ctx)
+
+	instances, err := r.Backend.GetInstanceList(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
 	instanceChan := make(chan []*model.Instance, 1)
+	instanceChan <- r.visibleInstances(ctx, instances)
+
 	go func() {
+		defer close(instanceChan)
 		for {
-			instanceChan <- mockInstanceLiveUpd()
-			time.Sleep(2 * time.Second)
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-updates:
+				if !ok {
+					// The backend dropped us for falling behind
+					return
+				}
+				if event.Resource != events.ResourceInstance {
+					continue
+				}
+				drainEvents(updates)
+
+				instances, err := r.Backend.GetInstanceList(ctx, "")
+				if err != nil {
+					continue
+				}
+
+				select {
+				case instanceChan <- r.visibleInstances(ctx, instances):
+				case <-ctx.Done():
+					return
+				}
+			}
 		}
 	}()
+
 	return instanceChan, nil
 }
 
+// InstanceEvents is the resolver for the instanceEvents field.
+func (r *subscriptionResolver) InstanceEvents(ctx context.Context, projectID string) (<-chan *model.InstanceEvent, error) {
+	return forwardEvents(ctx, r.Backend.Subscribe(ctx), func(event events.Event) (*model.InstanceEvent, bool) {
+		if event.Resource != events.ResourceInstance || event.ProjectID != projectID {
+			return nil, false
+		}
+		return &model.InstanceEvent{
+			Type:          event.Type,
+			Instance:      event.Instance,
+			ChangedFields: nonNilFields(event.ChangedFields),
+		}, true
+	}), nil
+}
+
+// DiskEvents is the resolver for the diskEvents field.
+func (r *subscriptionResolver) DiskEvents(ctx context.Context, projectID string) (<-chan *model.DiskEvent, error) {
+	return forwardEvents(ctx, r.Backend.Subscribe(ctx), func(event events.Event) (*model.DiskEvent, bool) {
+		if event.Resource != events.ResourceDisk || event.ProjectID != projectID {
+			return nil, false
+		}
+		return &model.DiskEvent{
+			Type:          event.Type,
+			Disk:          event.Disk,
+			ChangedFields: nonNilFields(event.ChangedFields),
+		}, true
+	}), nil
+}
+
+// ImageImportEvents is the resolver for the imageImportEvents field.
+func (r *subscriptionResolver) ImageImportEvents(ctx context.Context, projectID string) (<-chan *model.ImageImportEvent, error) {
+	return forwardEvents(ctx, r.Backend.Subscribe(ctx), func(event events.Event) (*model.ImageImportEvent, bool) {
+		if event.Resource != events.ResourceImageImport || event.ProjectID != projectID {
+			return nil, false
+		}
+		return &model.ImageImportEvent{
+			Type:          event.Type,
+			ImageImport:   event.ImageImport,
+			ChangedFields: nonNilFields(event.ChangedFields),
+		}, true
+	}), nil
+}
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
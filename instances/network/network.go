@@ -0,0 +1,169 @@
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"gqlfed/instances/graph/model"
+)
+
+// maxPrefixLength - самая маленькая подсеть, в которой помещаются шлюз и несколько VM
+const maxPrefixLength = 29
+
+// Subnet описывает проверенную подсеть сети
+type Subnet struct {
+	// CIDR содержит адрес подсети в каноническом виде
+	CIDR    string
+	Gateway string
+}
+
+// ParseSubnet проверяет подсеть IPv4 и адрес шлюза. Без шлюза используется
+// первый адрес подсети
+func ParseSubnet(cidr string, gateway *string) (*Subnet, error) {
+	_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil || ipNet.IP.To4() == nil {
+		return nil, fmt.Errorf("invalid cidr %q: expected an IPv4 subnet", cidr)
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	if ones > maxPrefixLength {
+		return nil, fmt.Errorf("subnet %s is too small, the prefix must be /%d or shorter", ipNet, maxPrefixLength)
+	}
+
+	first := binary.BigEndian.Uint32(ipNet.IP.To4())
+	last := first | (1<<(bits-ones) - 1)
+
+	gatewayIP := first + 1
+	if gateway != nil && strings.TrimSpace(*gateway) != "" {
+		ip := net.ParseIP(strings.TrimSpace(*gateway)).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid gateway ip %q", *gateway)
+		}
+
+		gatewayIP = binary.BigEndian.Uint32(ip)
+		if gatewayIP <= first || gatewayIP >= last {
+			return nil, fmt.Errorf("gateway ip %s is not a host address of subnet %s", ip, ipNet)
+		}
+	}
+
+	gatewayAddr := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(gatewayAddr, gatewayIP)
+
+	return &Subnet{
+		CIDR:    ipNet.String(),
+		Gateway: gatewayAddr.String(),
+	}, nil
+}
+
+// Overlaps сообщает, пересекаются ли две подсети
+func Overlaps(a, b string) bool {
+	_, netA, errA := net.ParseCIDR(a)
+	_, netB, errB := net.ParseCIDR(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+
+	return netA.Contains(netB.IP) || netB.Contains(netA.IP)
+}
+
+// Rules проверяет правила группы безопасности. Порты задаются только для
+// TCP, UDP и SCTP; правило без портов разрешает все порты протокола
+func Rules(input []*model.SecurityGroupRuleInput) ([]*model.SecurityGroupRule, error) {
+	rules := make([]*model.SecurityGroupRule, 0, len(input))
+	for i, rule := range input {
+		if !rule.Direction.IsValid() {
+			return nil, fmt.Errorf("rule %d: invalid direction %q", i+1, rule.Direction)
+		}
+		if !rule.Protocol.IsValid() {
+			return nil, fmt.Errorf("rule %d: invalid protocol %q", i+1, rule.Protocol)
+		}
+
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(rule.Cidr))
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: invalid cidr %q", i+1, rule.Cidr)
+		}
+
+		portMin, portMax := rule.PortMin, rule.PortMax
+		if portMax != nil && portMin == nil {
+			return nil, fmt.Errorf("rule %d: port_max requires port_min", i+1)
+		}
+		if portMin != nil {
+			if rule.Protocol == model.ProtocolAny {
+				return nil, fmt.Errorf("rule %d: ports cannot be set for protocol %s", i+1, model.ProtocolAny)
+			}
+			if *portMin < 1 || *portMin > 65535 {
+				return nil, fmt.Errorf("rule %d: port_min must be between 1 and 65535", i+1)
+			}
+			if portMax != nil && (*portMax < *portMin || *portMax > 65535) {
+				return nil, fmt.Errorf("rule %d: port_max must be between port_min and 65535", i+1)
+			}
+			// Диапазон из одного порта хранится как один порт
+			if portMax != nil && *portMax == *portMin {
+				portMax = nil
+			}
+		}
+
+		rules = append(rules, &model.SecurityGroupRule{
+			Direction: rule.Direction,
+			Protocol:  rule.Protocol,
+			PortMin:   portMin,
+			PortMax:   portMax,
+			Cidr:      ipNet.String(),
+		})
+	}
+
+	return rules, nil
+}
+
+// InstanceExternal возвращает способ публикации и порты нового инстанса. Без
+// указанных способа и портов наружу открывается только SSH
+func InstanceExternal(input model.NewInstanceInput) (model.ExternalMethod, []int32, error) {
+	method := model.ExternalMethodPortList
+	if input.ExternalMethod != nil {
+		method = *input.ExternalMethod
+	}
+	ports := input.ExternalPorts
+	if input.ExternalMethod == nil && ports == nil {
+		ports = []int32{22}
+	}
+
+	ports, err := ExternalPorts(method, ports)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return method, ports, nil
+}
+
+// ExternalPorts проверяет способ публикации инстанса и его порты. В режиме
+// WHOLE_IP наружу открыты все порты, поэтому список портов должен быть пустым.
+// Порты возвращаются отсортированными и без повторов
+func ExternalPorts(method model.ExternalMethod, ports []int32) ([]int32, error) {
+	if !method.IsValid() {
+		return nil, fmt.Errorf("invalid external method %q", method)
+	}
+	if method == model.ExternalMethodWholeIP {
+		if len(ports) > 0 {
+			return nil, fmt.Errorf("ports cannot be set with %s, all ports are exposed", model.ExternalMethodWholeIP)
+		}
+		return []int32{}, nil
+	}
+
+	seen := make(map[int32]bool)
+	result := make([]int32, 0, len(ports))
+	for _, port := range ports {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d: must be between 1 and 65535", port)
+		}
+		if !seen[port] {
+			seen[port] = true
+			result = append(result, port)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+
+	return result, nil
+}
@@ -0,0 +1,221 @@
+package network
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"gqlfed/instances/graph/model"
+)
+
+func int32Ptr(value int32) *int32 { return &value }
+
+func stringPtr(value string) *string { return &value }
+
+func TestParseSubnet(t *testing.T) {
+	tests := []struct {
+		name        string
+		cidr        string
+		gateway     *string
+		wantCIDR    string
+		wantGateway string
+		wantErr     string
+	}{
+		{"default gateway", "10.0.0.0/24", nil, "10.0.0.0/24", "10.0.0.1", ""},
+		{"host bits are cleared", " 10.0.0.17/24 ", nil, "10.0.0.0/24", "10.0.0.1", ""},
+		{"explicit gateway", "192.168.1.0/24", stringPtr("192.168.1.254"), "192.168.1.0/24", "192.168.1.254", ""},
+		{"empty gateway means default", "192.168.1.0/24", stringPtr(" "), "192.168.1.0/24", "192.168.1.1", ""},
+		{"smallest subnet", "10.0.0.0/29", nil, "10.0.0.0/29", "10.0.0.1", ""},
+		{"subnet too small", "10.0.0.0/30", nil, "", "", "too small"},
+		{"IPv6", "fd00::/64", nil, "", "", "expected an IPv4 subnet"},
+		{"not a cidr", "10.0.0.0", nil, "", "", "expected an IPv4 subnet"},
+		{"invalid gateway", "10.0.0.0/24", stringPtr("gateway"), "", "", "invalid gateway ip"},
+		{"gateway is the network address", "10.0.0.0/24", stringPtr("10.0.0.0"), "", "", "not a host address"},
+		{"gateway is the broadcast address", "10.0.0.0/24", stringPtr("10.0.0.255"), "", "", "not a host address"},
+		{"gateway outside the subnet", "10.0.0.0/24", stringPtr("10.0.1.1"), "", "", "not a host address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSubnet(tt.cidr, tt.gateway)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseSubnet() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSubnet() error = %v", err)
+			}
+			if got.CIDR != tt.wantCIDR || got.Gateway != tt.wantGateway {
+				t.Errorf("ParseSubnet() = %+v, want %s via %s", *got, tt.wantCIDR, tt.wantGateway)
+			}
+		})
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"10.0.0.0/24", "10.0.0.0/24", true},
+		{"10.0.0.0/16", "10.0.5.0/24", true},
+		{"10.0.5.0/24", "10.0.0.0/16", true},
+		{"10.0.0.0/24", "10.0.1.0/24", false},
+		{"10.0.0.0/25", "10.0.0.128/25", false},
+		{"10.0.0.0/24", "invalid", false},
+	}
+
+	for _, tt := range tests {
+		if got := Overlaps(tt.a, tt.b); got != tt.want {
+			t.Errorf("Overlaps(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	rule := func(protocol model.Protocol, portMin, portMax *int32, cidr string) *model.SecurityGroupRuleInput {
+		return &model.SecurityGroupRuleInput{
+			Direction: model.TrafficDirectionIngress,
+			Protocol:  protocol,
+			PortMin:   portMin,
+			PortMax:   portMax,
+			Cidr:      cidr,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		input   *model.SecurityGroupRuleInput
+		want    *model.SecurityGroupRule
+		wantErr string
+	}{
+		{
+			name:  "single port",
+			input: rule(model.ProtocolTCP, int32Ptr(22), nil, "0.0.0.0/0"),
+			want:  &model.SecurityGroupRule{Direction: model.TrafficDirectionIngress, Protocol: model.ProtocolTCP, PortMin: int32Ptr(22), Cidr: "0.0.0.0/0"},
+		},
+		{
+			name:  "port range",
+			input: rule(model.ProtocolUDP, int32Ptr(1000), int32Ptr(2000), "10.0.0.0/8"),
+			want:  &model.SecurityGroupRule{Direction: model.TrafficDirectionIngress, Protocol: model.ProtocolUDP, PortMin: int32Ptr(1000), PortMax: int32Ptr(2000), Cidr: "10.0.0.0/8"},
+		},
+		{
+			name:  "range of one port is stored as a port",
+			input: rule(model.ProtocolSctp, int32Ptr(80), int32Ptr(80), "10.1.2.3/16"),
+			want:  &model.SecurityGroupRule{Direction: model.TrafficDirectionIngress, Protocol: model.ProtocolSctp, PortMin: int32Ptr(80), Cidr: "10.1.0.0/16"},
+		},
+		{
+			name:  "any protocol without ports",
+			input: rule(model.ProtocolAny, nil, nil, "0.0.0.0/0"),
+			want:  &model.SecurityGroupRule{Direction: model.TrafficDirectionIngress, Protocol: model.ProtocolAny, Cidr: "0.0.0.0/0"},
+		},
+		{"ports with any protocol", rule(model.ProtocolAny, int32Ptr(22), nil, "0.0.0.0/0"), nil, "ports cannot be set"},
+		{"port_max without port_min", rule(model.ProtocolTCP, nil, int32Ptr(22), "0.0.0.0/0"), nil, "port_max requires port_min"},
+		{"port zero", rule(model.ProtocolTCP, int32Ptr(0), nil, "0.0.0.0/0"), nil, "port_min must be between"},
+		{"port too large", rule(model.ProtocolTCP, int32Ptr(65536), nil, "0.0.0.0/0"), nil, "port_min must be between"},
+		{"reversed range", rule(model.ProtocolTCP, int32Ptr(2000), int32Ptr(1000), "0.0.0.0/0"), nil, "port_max must be between"},
+		{"invalid cidr", rule(model.ProtocolTCP, nil, nil, "everywhere"), nil, "invalid cidr"},
+		{"invalid protocol", rule("ICMP", nil, nil, "0.0.0.0/0"), nil, "invalid protocol"},
+		{
+			name:    "invalid direction",
+			input:   &model.SecurityGroupRuleInput{Direction: "BOTH", Protocol: model.ProtocolTCP, Cidr: "0.0.0.0/0"},
+			wantErr: "invalid direction",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Rules([]*model.SecurityGroupRuleInput{tt.input})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Rules() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Rules() error = %v", err)
+			}
+			if len(got) != 1 || !sameRule(got[0], tt.want) {
+				t.Errorf("Rules() = %+v, want %+v", got[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestExternalPorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  model.ExternalMethod
+		ports   []int32
+		want    []int32
+		wantErr string
+	}{
+		{"ports are sorted and deduplicated", model.ExternalMethodPortList, []int32{443, 22, 80, 22}, []int32{22, 80, 443}, ""},
+		{"no ports", model.ExternalMethodPortList, nil, []int32{}, ""},
+		{"whole IP", model.ExternalMethodWholeIP, nil, []int32{}, ""},
+		{"ports with whole IP", model.ExternalMethodWholeIP, []int32{22}, nil, "ports cannot be set"},
+		{"port zero", model.ExternalMethodPortList, []int32{0}, nil, "invalid port 0"},
+		{"port too large", model.ExternalMethodPortList, []int32{65536}, nil, "invalid port 65536"},
+		{"invalid method", "NAT", nil, nil, "invalid external method"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExternalPorts(tt.method, tt.ports)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ExternalPorts() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExternalPorts() error = %v", err)
+			}
+			if got == nil || !slices.Equal(got, tt.want) {
+				t.Errorf("ExternalPorts() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstanceExternal(t *testing.T) {
+	wholeIP := model.ExternalMethodWholeIP
+	portList := model.ExternalMethodPortList
+
+	tests := []struct {
+		name       string
+		input      model.NewInstanceInput
+		wantMethod model.ExternalMethod
+		wantPorts  []int32
+		wantErr    bool
+	}{
+		{"SSH by default", model.NewInstanceInput{}, model.ExternalMethodPortList, []int32{22}, false},
+		{"explicit ports", model.NewInstanceInput{ExternalPorts: []int32{80}}, model.ExternalMethodPortList, []int32{80}, false},
+		{"explicitly closed", model.NewInstanceInput{ExternalPorts: []int32{}}, model.ExternalMethodPortList, []int32{}, false},
+		{"port list without ports", model.NewInstanceInput{ExternalMethod: &portList}, model.ExternalMethodPortList, []int32{}, false},
+		{"whole IP", model.NewInstanceInput{ExternalMethod: &wholeIP}, model.ExternalMethodWholeIP, []int32{}, false},
+		{"whole IP with ports", model.NewInstanceInput{ExternalMethod: &wholeIP, ExternalPorts: []int32{22}}, "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, ports, err := InstanceExternal(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InstanceExternal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if method != tt.wantMethod || !slices.Equal(ports, tt.wantPorts) {
+				t.Errorf("InstanceExternal() = %s, %v, want %s, %v", method, ports, tt.wantMethod, tt.wantPorts)
+			}
+		})
+	}
+}
+
+func sameRule(a, b *model.SecurityGroupRule) bool {
+	samePort := func(x, y *int32) bool {
+		return (x == nil && y == nil) || (x != nil && y != nil && *x == *y)
+	}
+	return a.Direction == b.Direction && a.Protocol == b.Protocol && a.Cidr == b.Cidr &&
+		samePort(a.PortMin, b.PortMin) && samePort(a.PortMax, b.PortMax)
+}
@@ -0,0 +1,115 @@
+package pricing
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gqlfed/instances/graph/model"
+	"gqlfed/instances/usage"
+)
+
+// Currency - валюта цен каталога flavor
+const Currency = "RUB"
+
+// HoursPerMonth - среднее число часов в месяце, по которому месячная цена
+// переводится в почасовую
+const HoursPerMonth = 730
+
+// Prices содержит месячные цены ресурсов, которых нет в flavor. Незаданная
+// цена равна нулю, то есть ресурс не тарифицируется
+type Prices struct {
+	// DiskGB - цена гигабайта диска в месяц
+	DiskGB float64 `json:"disk_gb_rub_month"`
+	// PublicIP - цена публичного адреса в месяц
+	PublicIP float64 `json:"public_ip_rub_month"`
+}
+
+// Validate проверяет, что цены не отрицательны
+func (p Prices) Validate() error {
+	if p.DiskGB < 0 {
+		return fmt.Errorf("disk price must not be negative, got %g", p.DiskGB)
+	}
+	if p.PublicIP < 0 {
+		return fmt.Errorf("public IP price must not be negative, got %g", p.PublicIP)
+	}
+
+	return nil
+}
+
+// FlavorPrice возвращает месячную цену flavor из rub_month
+func FlavorPrice(flavor model.Flavor) (float64, error) {
+	var price string
+	switch f := flavor.(type) {
+	case *model.BaseFlavor:
+		price = f.RubMonth
+	case *model.HiFreqFlavor:
+		price = f.RubMonth
+	case *model.PremiumFlavor:
+		price = f.RubMonth
+	case *model.ProFlavor:
+		price = f.RubMonth
+	default:
+		return 0, fmt.Errorf("unknown flavor type %T", flavor)
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(price), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("flavor has invalid price %q", price)
+	}
+
+	return value, nil
+}
+
+// Instance считает стоимость инстанса: flavor, подключенные диски и публичный
+// адрес, если инстанс открыт наружу. Flavor с неизвестной ценой не учитывается
+func Instance(prices Prices, instance *model.Instance) *model.Cost {
+	flavorMonthly, _ := FlavorPrice(instance.Flavor)
+
+	var diskGB int32
+	for _, disk := range instance.AttachedDisks {
+		diskGB += disk.SizeGb
+	}
+
+	publicIPMonthly := 0.0
+	if usage.Exposed(instance) {
+		publicIPMonthly = prices.PublicIP
+	}
+
+	return newCost(flavorMonthly, float64(diskGB)*prices.DiskGB, publicIPMonthly)
+}
+
+// ProjectMonthly считает месячную стоимость проекта: flavor его инстансов,
+// все его диски, в том числе не подключенные, и публичные адреса
+func ProjectMonthly(prices Prices, instances []*model.Instance, disks []*model.Disk) float64 {
+	used := usage.Compute(instances, disks)
+
+	total := float64(used.DiskGb)*prices.DiskGB + float64(used.PublicIps)*prices.PublicIP
+	for _, instance := range instances {
+		if price, err := FlavorPrice(instance.Flavor); err == nil {
+			total += price
+		}
+	}
+
+	return round(total)
+}
+
+// newCost собирает стоимость из месячных цен составляющих
+func newCost(flavorMonthly, diskMonthly, publicIPMonthly float64) *model.Cost {
+	monthly := flavorMonthly + diskMonthly + publicIPMonthly
+
+	return &model.Cost{
+		Currency:        Currency,
+		Hourly:          round(monthly / HoursPerMonth),
+		Monthly:         round(monthly),
+		FlavorMonthly:   round(flavorMonthly),
+		DiskMonthly:     round(diskMonthly),
+		PublicIPMonthly: round(publicIPMonthly),
+	}
+}
+
+// round округляет сумму до копеек
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
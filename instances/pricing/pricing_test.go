@@ -0,0 +1,150 @@
+package pricing
+
+import (
+	"testing"
+
+	"gqlfed/instances/graph/model"
+)
+
+func TestFlavorPrice(t *testing.T) {
+	tests := []struct {
+		name    string
+		flavor  model.Flavor
+		want    float64
+		wantErr bool
+	}{
+		{"base", &model.BaseFlavor{RubMonth: "1460"}, 1460, false},
+		{"hifreq", &model.HiFreqFlavor{RubMonth: "2190.5"}, 2190.5, false},
+		{"premium", &model.PremiumFlavor{RubMonth: " 3650 "}, 3650, false},
+		{"pro", &model.ProFlavor{RubMonth: "0"}, 0, false},
+		{"empty price", &model.BaseFlavor{RubMonth: ""}, 0, true},
+		{"not a number", &model.BaseFlavor{RubMonth: "free"}, 0, true},
+		{"negative", &model.BaseFlavor{RubMonth: "-1"}, 0, true},
+		{"no flavor", nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FlavorPrice(tt.flavor)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FlavorPrice() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("FlavorPrice() = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstance(t *testing.T) {
+	prices := Prices{DiskGB: 7.3, PublicIP: 146}
+
+	tests := []struct {
+		name     string
+		instance *model.Instance
+		want     model.Cost
+	}{
+		{
+			name: "flavor and disks",
+			instance: &model.Instance{
+				Flavor:         &model.BaseFlavor{RubMonth: "1460"},
+				AttachedDisks:  []*model.Disk{{SizeGb: 20}, {SizeGb: 30}},
+				ExternalMethod: model.ExternalMethodPortList,
+			},
+			want: model.Cost{Currency: Currency, Hourly: 2.5, Monthly: 1825, FlavorMonthly: 1460, DiskMonthly: 365},
+		},
+		{
+			name: "whole IP is charged",
+			instance: &model.Instance{
+				Flavor:         &model.BaseFlavor{RubMonth: "730"},
+				ExternalMethod: model.ExternalMethodWholeIP,
+			},
+			want: model.Cost{Currency: Currency, Hourly: 1.2, Monthly: 876, FlavorMonthly: 730, PublicIPMonthly: 146},
+		},
+		{
+			name: "open ports are charged",
+			instance: &model.Instance{
+				Flavor:         &model.BaseFlavor{RubMonth: "730"},
+				ExternalMethod: model.ExternalMethodPortList,
+				ExternalPorts:  []int32{22},
+			},
+			want: model.Cost{Currency: Currency, Hourly: 1.2, Monthly: 876, FlavorMonthly: 730, PublicIPMonthly: 146},
+		},
+		{
+			name: "unknown flavor price is not charged",
+			instance: &model.Instance{
+				Flavor:        &model.BaseFlavor{RubMonth: "n/a"},
+				AttachedDisks: []*model.Disk{{SizeGb: 10}},
+			},
+			want: model.Cost{Currency: Currency, Hourly: 0.1, Monthly: 73, DiskMonthly: 73},
+		},
+		{
+			name: "amounts are rounded to kopecks",
+			instance: &model.Instance{
+				Flavor: &model.BaseFlavor{RubMonth: "1000"},
+			},
+			want: model.Cost{Currency: Currency, Hourly: 1.37, Monthly: 1000, FlavorMonthly: 1000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Instance(prices, tt.instance)
+			if *got != tt.want {
+				t.Errorf("Instance() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectMonthly(t *testing.T) {
+	prices := Prices{DiskGB: 2.5, PublicIP: 100}
+	instances := []*model.Instance{
+		{Flavor: &model.BaseFlavor{RubMonth: "1000"}, ExternalMethod: model.ExternalMethodWholeIP},
+		{Flavor: &model.BaseFlavor{RubMonth: "500.555"}, ExternalMethod: model.ExternalMethodPortList},
+		{Flavor: &model.BaseFlavor{RubMonth: "unknown"}, ExternalMethod: model.ExternalMethodPortList, ExternalPorts: []int32{80}},
+	}
+	// Detached disks are charged too.
+	disks := []*model.Disk{{SizeGb: 10}, {SizeGb: 30}}
+
+	tests := []struct {
+		name      string
+		prices    Prices
+		instances []*model.Instance
+		disks     []*model.Disk
+		want      float64
+	}{
+		{"instances and disks", prices, instances, disks, 1000 + 500.555 + 2*100 + 40*2.5},
+		{"no prices for disks and addresses", Prices{}, instances, disks, 1500.56},
+		{"empty project", prices, nil, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProjectMonthly(tt.prices, tt.instances, tt.disks); got != round(tt.want) {
+				t.Errorf("ProjectMonthly() = %g, want %g", got, round(tt.want))
+			}
+		})
+	}
+}
+
+func TestPricesValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		prices  Prices
+		wantErr bool
+	}{
+		{"zero", Prices{}, false},
+		{"positive", Prices{DiskGB: 7.3, PublicIP: 146}, false},
+		{"negative disk", Prices{DiskGB: -1}, true},
+		{"negative public IP", Prices{PublicIP: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.prices.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package quota
+
+import (
+	"fmt"
+	"strings"
+
+	"gqlfed/instances/graph/model"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"sigs.k8s.io/yaml"
+)
+
+// CodeQuotaExceeded - код ошибки GraphQL для запросов, превышающих квоту проекта
+const CodeQuotaExceeded = "QUOTA_EXCEEDED"
+
+// Config - формат файла квот. Квоты проекта из projects заменяют квоты по
+// умолчанию только в заданных полях; незаданная квота не ограничена
+type Config struct {
+	Default  model.QuotaLimits            `json:"default"`
+	Projects map[string]model.QuotaLimits `json:"projects"`
+}
+
+// Violation описывает ресурс, квота на который будет превышена
+type Violation struct {
+	// Resource - ресурс квоты: instances, vcpus, ram_gb, disk_gb или public_ips
+	Resource  string  `json:"resource"`
+	Limit     float64 `json:"limit"`
+	Used      float64 `json:"used"`
+	Requested float64 `json:"requested"`
+}
+
+// Parse разбирает файл квот в формате YAML или JSON
+func Parse(content []byte) (*Config, error) {
+	var config Config
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse quotas: %v", err)
+	}
+
+	if err := validate("default", &config.Default); err != nil {
+		return nil, err
+	}
+	for projectID, limits := range config.Projects {
+		if err := validate("project "+projectID, &limits); err != nil {
+			return nil, err
+		}
+	}
+
+	return &config, nil
+}
+
+// For возвращает квоты проекта. nil-конфигурация ничего не ограничивает
+func (c *Config) For(projectID string) *model.QuotaLimits {
+	if c == nil {
+		return &model.QuotaLimits{}
+	}
+
+	limits := c.Default
+	override, exists := c.Projects[projectID]
+	if !exists {
+		return &limits
+	}
+
+	if override.Instances != nil {
+		limits.Instances = override.Instances
+	}
+	if override.Vcpus != nil {
+		limits.Vcpus = override.Vcpus
+	}
+	if override.RAMGb != nil {
+		limits.RAMGb = override.RAMGb
+	}
+	if override.DiskGb != nil {
+		limits.DiskGb = override.DiskGb
+	}
+	if override.PublicIps != nil {
+		limits.PublicIps = override.PublicIps
+	}
+
+	return &limits
+}
+
+// Check проверяет, что потребление проекта вместе с запрошенными ресурсами не
+// превысит квот. Ошибка содержит все превышенные квоты в extensions; ресурсы,
+// которые запрос не увеличивает, не проверяются, чтобы проект сверх квоты мог
+// освобождать ресурсы
+func Check(limits *model.QuotaLimits, used, requested *model.ProjectUsage) error {
+	var violations []Violation
+	add := func(resource string, limit *float64, used, requested float64) {
+		if limit != nil && requested > 0 && used+requested > *limit {
+			violations = append(violations, Violation{Resource: resource, Limit: *limit, Used: used, Requested: requested})
+		}
+	}
+
+	add("instances", asFloat(limits.Instances), float64(used.Instances), float64(requested.Instances))
+	add("vcpus", asFloat(limits.Vcpus), float64(used.Vcpus), float64(requested.Vcpus))
+	add("ram_gb", limits.RAMGb, used.RAMGb, requested.RAMGb)
+	add("disk_gb", asFloat(limits.DiskGb), float64(used.DiskGb), float64(requested.DiskGb))
+	add("public_ips", asFloat(limits.PublicIps), float64(used.PublicIps), float64(requested.PublicIps))
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	details := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		details = append(details, fmt.Sprintf("%s: %g of %g used, %g requested", violation.Resource, violation.Used, violation.Limit, violation.Requested))
+	}
+
+	return &gqlerror.Error{
+		Message: fmt.Sprintf("project quota exceeded: %s", strings.Join(details, "; ")),
+		Extensions: map[string]interface{}{
+			"code":       CodeQuotaExceeded,
+			"violations": violations,
+		},
+	}
+}
+
+// validate проверяет, что квоты не отрицательны
+func validate(scope string, limits *model.QuotaLimits) error {
+	values := map[string]*float64{
+		"instances":  asFloat(limits.Instances),
+		"vcpus":      asFloat(limits.Vcpus),
+		"ram_gb":     limits.RAMGb,
+		"disk_gb":    asFloat(limits.DiskGb),
+		"public_ips": asFloat(limits.PublicIps),
+	}
+	for resource, value := range values {
+		if value != nil && *value < 0 {
+			return fmt.Errorf("%s quota of %s must not be negative, got %g", resource, scope, *value)
+		}
+	}
+
+	return nil
+}
+
+// asFloat приводит необязательную целую квоту к float64
+func asFloat(value *int32) *float64 {
+	if value == nil {
+		return nil
+	}
+
+	converted := float64(*value)
+	return &converted
+}
@@ -0,0 +1,176 @@
+package quota
+
+import (
+	"testing"
+
+	"gqlfed/instances/graph/model"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func int32Ptr(value int32) *int32 { return &value }
+
+func float64Ptr(value float64) *float64 { return &value }
+
+func TestCheck(t *testing.T) {
+	limits := &model.QuotaLimits{
+		Instances: int32Ptr(2),
+		Vcpus:     int32Ptr(8),
+		RAMGb:     float64Ptr(16),
+		DiskGb:    int32Ptr(100),
+		PublicIps: int32Ptr(1),
+	}
+
+	tests := []struct {
+		name      string
+		limits    *model.QuotaLimits
+		used      model.ProjectUsage
+		requested model.ProjectUsage
+		// want lists the exceeded resources in the order they are checked.
+		want []string
+	}{
+		{
+			name:      "below limits",
+			limits:    limits,
+			used:      model.ProjectUsage{Instances: 1, Vcpus: 2, RAMGb: 4, DiskGb: 20},
+			requested: model.ProjectUsage{Instances: 1, Vcpus: 2, RAMGb: 4, DiskGb: 20, PublicIps: 1},
+		},
+		{
+			name:      "exactly at limits",
+			limits:    limits,
+			used:      model.ProjectUsage{Instances: 1, Vcpus: 4, RAMGb: 8, DiskGb: 50},
+			requested: model.ProjectUsage{Instances: 1, Vcpus: 4, RAMGb: 8, DiskGb: 50, PublicIps: 1},
+		},
+		{
+			name:      "one over each limit",
+			limits:    limits,
+			used:      model.ProjectUsage{Instances: 2, Vcpus: 8, RAMGb: 16, DiskGb: 100, PublicIps: 1},
+			requested: model.ProjectUsage{Instances: 1, Vcpus: 1, RAMGb: 0.5, DiskGb: 1, PublicIps: 1},
+			want:      []string{"instances", "vcpus", "ram_gb", "disk_gb", "public_ips"},
+		},
+		{
+			name:      "request alone exceeds limit",
+			limits:    limits,
+			requested: model.ProjectUsage{DiskGb: 101},
+			want:      []string{"disk_gb"},
+		},
+		{
+			name:      "over quota project may free resources",
+			limits:    limits,
+			used:      model.ProjectUsage{Instances: 5, Vcpus: 20, RAMGb: 40, DiskGb: 500, PublicIps: 3},
+			requested: model.ProjectUsage{},
+		},
+		{
+			name:      "over quota on one resource blocks only that resource",
+			limits:    limits,
+			used:      model.ProjectUsage{DiskGb: 200},
+			requested: model.ProjectUsage{Instances: 1, Vcpus: 2, RAMGb: 4},
+		},
+		{
+			name:      "zero limit",
+			limits:    &model.QuotaLimits{PublicIps: int32Ptr(0)},
+			requested: model.ProjectUsage{PublicIps: 1},
+			want:      []string{"public_ips"},
+		},
+		{
+			name:      "unset limits",
+			limits:    &model.QuotaLimits{},
+			used:      model.ProjectUsage{Instances: 100, Vcpus: 100, RAMGb: 100, DiskGb: 100, PublicIps: 100},
+			requested: model.ProjectUsage{Instances: 100, Vcpus: 100, RAMGb: 100, DiskGb: 100, PublicIps: 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.limits, &tt.used, &tt.requested)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Check() error = %v", err)
+				}
+				return
+			}
+
+			gqlErr, ok := err.(*gqlerror.Error)
+			if !ok {
+				t.Fatalf("Check() error = %v, want *gqlerror.Error", err)
+			}
+			if gqlErr.Extensions["code"] != CodeQuotaExceeded {
+				t.Errorf("Check() code = %v, want %s", gqlErr.Extensions["code"], CodeQuotaExceeded)
+			}
+			violations, _ := gqlErr.Extensions["violations"].([]Violation)
+			if len(violations) != len(tt.want) {
+				t.Fatalf("Check() violations = %+v, want %v", violations, tt.want)
+			}
+			for i, violation := range violations {
+				if violation.Resource != tt.want[i] {
+					t.Errorf("Check() violation %d = %s, want %s", i, violation.Resource, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConfigFor(t *testing.T) {
+	config, err := Parse([]byte(`
+default:
+  instances: 10
+  disk_gb: 500
+projects:
+  big:
+    disk_gb: 2000
+    public_ips: 5
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		config        *Config
+		projectID     string
+		wantInstances *int32
+		wantDiskGB    *int32
+		wantPublicIPs *int32
+	}{
+		{"default", config, "small", int32Ptr(10), int32Ptr(500), nil},
+		{"override", config, "big", int32Ptr(10), int32Ptr(2000), int32Ptr(5)},
+		{"no config", nil, "big", nil, nil, nil},
+	}
+
+	equal := func(a, b *int32) bool {
+		return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := tt.config.For(tt.projectID)
+			if !equal(limits.Instances, tt.wantInstances) || !equal(limits.DiskGb, tt.wantDiskGB) || !equal(limits.PublicIps, tt.wantPublicIPs) {
+				t.Errorf("For(%q) = %+v", tt.projectID, limits)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"yaml", "default:\n  vcpus: 4\n", false},
+		{"json", `{"projects": {"p": {"ram_gb": 8.5}}}`, false},
+		{"empty", "", false},
+		{"negative default", "default:\n  vcpus: -1\n", true},
+		{"negative project", "projects:\n  p:\n    disk_gb: -10\n", true},
+		{"unknown field", "default:\n  cpus: 4\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
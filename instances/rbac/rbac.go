@@ -0,0 +1,55 @@
+package rbac
+
+import (
+	"fmt"
+
+	"gqlfed/instances/graph/model"
+)
+
+// ranks упорядочивает роли: каждая следующая роль включает права предыдущих
+var ranks = map[model.Role]int{
+	model.RoleViewer:   1,
+	model.RoleOperator: 2,
+	model.RoleAdmin:    3,
+}
+
+// Allows сообщает, достаточно ли роли have для действия, которому нужна роль need
+func Allows(have, need model.Role) bool {
+	return ranks[need] > 0 && ranks[have] >= ranks[need]
+}
+
+// Role возвращает роль пользователя в проекте по привязкам проекта. Пока у
+// проекта нет привязок, участник проекта из токена получает ADMIN, чтобы
+// создать первые привязки
+func Role(bindings []*model.RoleBinding, userID string, member bool) (model.Role, bool) {
+	if len(bindings) == 0 {
+		return model.RoleAdmin, member
+	}
+
+	for _, binding := range bindings {
+		if binding.UserID == userID {
+			return binding.Role, true
+		}
+	}
+
+	return "", false
+}
+
+// CheckChange проверяет, что после изменения роли пользователя в проекте
+// останется хотя бы один ADMIN. Пустая роль означает удаление привязки
+func CheckChange(bindings []*model.RoleBinding, userID string, role model.Role) error {
+	if role != "" && !role.IsValid() {
+		return fmt.Errorf("invalid role %q", role)
+	}
+	if role == model.RoleAdmin {
+		return nil
+	}
+
+	for _, binding := range bindings {
+		if binding.UserID != userID && binding.Role == model.RoleAdmin {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("project must keep at least one %s", model.RoleAdmin)
+}
@@ -0,0 +1,102 @@
+package rbac
+
+import (
+	"testing"
+
+	"gqlfed/instances/graph/model"
+)
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		have, need model.Role
+		want       bool
+	}{
+		{model.RoleViewer, model.RoleViewer, true},
+		{model.RoleViewer, model.RoleOperator, false},
+		{model.RoleViewer, model.RoleAdmin, false},
+		{model.RoleOperator, model.RoleViewer, true},
+		{model.RoleOperator, model.RoleOperator, true},
+		{model.RoleOperator, model.RoleAdmin, false},
+		{model.RoleAdmin, model.RoleViewer, true},
+		{model.RoleAdmin, model.RoleAdmin, true},
+		{"", model.RoleViewer, false},
+		{"OWNER", model.RoleViewer, false},
+		{model.RoleAdmin, "", false},
+		{model.RoleAdmin, "OWNER", false},
+	}
+
+	for _, tt := range tests {
+		if got := Allows(tt.have, tt.need); got != tt.want {
+			t.Errorf("Allows(%q, %q) = %v, want %v", tt.have, tt.need, got, tt.want)
+		}
+	}
+}
+
+func TestRole(t *testing.T) {
+	bindings := []*model.RoleBinding{
+		{ProjectID: "project-1", UserID: "admin", Role: model.RoleAdmin},
+		{ProjectID: "project-1", UserID: "viewer", Role: model.RoleViewer},
+	}
+
+	tests := []struct {
+		name     string
+		bindings []*model.RoleBinding
+		userID   string
+		member   bool
+		wantRole model.Role
+		wantOK   bool
+	}{
+		{"bound user", bindings, "viewer", false, model.RoleViewer, true},
+		{"bound member", bindings, "admin", true, model.RoleAdmin, true},
+		{"unbound member", bindings, "someone", true, "", false},
+		{"no bindings, member", nil, "someone", true, model.RoleAdmin, true},
+		{"no bindings, not a member", nil, "someone", false, model.RoleAdmin, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role, ok := Role(tt.bindings, tt.userID, tt.member)
+			if ok != tt.wantOK || (ok && role != tt.wantRole) {
+				t.Errorf("Role() = %q, %v, want %q, %v", role, ok, tt.wantRole, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCheckChange(t *testing.T) {
+	oneAdmin := []*model.RoleBinding{
+		{ProjectID: "project-1", UserID: "admin", Role: model.RoleAdmin},
+		{ProjectID: "project-1", UserID: "viewer", Role: model.RoleViewer},
+	}
+	twoAdmins := append([]*model.RoleBinding{
+		{ProjectID: "project-1", UserID: "second", Role: model.RoleAdmin},
+	}, oneAdmin...)
+
+	tests := []struct {
+		name     string
+		bindings []*model.RoleBinding
+		userID   string
+		role     model.Role
+		wantErr  bool
+	}{
+		{"demote last admin", oneAdmin, "admin", model.RoleOperator, true},
+		{"remove last admin", oneAdmin, "admin", "", true},
+		{"demote one of two admins", twoAdmins, "admin", model.RoleViewer, false},
+		{"remove one of two admins", twoAdmins, "admin", "", false},
+		{"keep last admin", oneAdmin, "admin", model.RoleAdmin, false},
+		{"change another user", oneAdmin, "viewer", model.RoleOperator, false},
+		{"remove another user", oneAdmin, "viewer", "", false},
+		{"add admin to empty project", nil, "someone", model.RoleAdmin, false},
+		{"add viewer to empty project", nil, "someone", model.RoleViewer, true},
+		{"invalid role", twoAdmins, "viewer", "OWNER", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckChange(tt.bindings, tt.userID, tt.role)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckChange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package requirements
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gqlfed/instances/graph/model"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// CodeBadUserInput - код ошибки GraphQL для запросов, не прошедших проверку
+const CodeBadUserInput = "BAD_USER_INPUT"
+
+// detailFormats содержит текст сообщения для каждого требования
+var detailFormats = map[string]string{
+	"cpu":     "instance type has %g vCPU, image requires at least %d",
+	"ram_gb":  "instance type has %g GB RAM, image requires at least %d GB",
+	"disk_gb": "disk is %g GB, image requires at least %d GB",
+}
+
+// Violation описывает требование образа, которому не удовлетворяет запрос
+type Violation struct {
+	// Field - поле запроса, из-за которого нарушено требование
+	Field string `json:"field"`
+	// Requirement - требование образа: cpu, ram_gb или disk_gb
+	Requirement string  `json:"requirement"`
+	Min         int32   `json:"min"`
+	Actual      float64 `json:"actual"`
+}
+
+// Check проверяет flavor и размер диска инстанса по минимальным требованиям
+// образа. Ошибка содержит все нарушенные требования в extensions
+func Check(image *model.Image, flavor model.Flavor, diskGB int32) error {
+	violations, err := flavorViolations(image, flavor)
+	if err != nil {
+		return err
+	}
+
+	violations = append(violations, diskViolations(image, diskGB, "disk_gb")...)
+
+	return newError(image, violations)
+}
+
+// CheckDisk проверяет размер диска, создаваемого из образа
+func CheckDisk(image *model.Image, sizeGB int32) error {
+	return newError(image, diskViolations(image, sizeGB, "size_gb"))
+}
+
+// CheckDiskSize отклоняет нулевой и отрицательный размер диска, заданный в
+// поле field. Ошибка содержит нарушение в extensions, как у Check
+func CheckDiskSize(sizeGB int32, field string) error {
+	if sizeGB > 0 {
+		return nil
+	}
+
+	return &gqlerror.Error{
+		Message: fmt.Sprintf("%s must be positive, got %d", field, sizeGB),
+		Extensions: map[string]interface{}{
+			"code":       CodeBadUserInput,
+			"violations": []Violation{{Field: field, Requirement: "disk_gb", Min: 1, Actual: float64(sizeGB)}},
+		},
+	}
+}
+
+// Fits сообщает, подходит ли flavor для образа
+func Fits(image *model.Image, flavor model.Flavor) bool {
+	violations, err := flavorViolations(image, flavor)
+	return err == nil && len(violations) == 0
+}
+
+// CompatibleFlavors оставляет в списке flavor только те, что подходят для образа
+func CompatibleFlavors(image *model.Image, flavorList []*model.KVStringListOfFlavor) []*model.KVStringListOfFlavor {
+	compatible := make([]*model.KVStringListOfFlavor, 0, len(flavorList))
+	for _, category := range flavorList {
+		flavors := make([]model.Flavor, 0, len(category.Value))
+		for _, flavor := range category.Value {
+			if Fits(image, flavor) {
+				flavors = append(flavors, flavor)
+			}
+		}
+		if len(flavors) == 0 {
+			continue
+		}
+
+		compatible = append(compatible, &model.KVStringListOfFlavor{
+			Key:   category.Key,
+			Value: flavors,
+		})
+	}
+
+	return compatible
+}
+
+// Resources возвращает число vCPU и объем RAM flavor в гигабайтах. RAM без
+// единиц измерения считается заданной в гигабайтах
+func Resources(flavor model.Flavor) (int, float64, error) {
+	var vcpus, ram string
+	switch f := flavor.(type) {
+	case *model.BaseFlavor:
+		vcpus, ram = f.Vcpus, f.RAM
+	case *model.HiFreqFlavor:
+		vcpus, ram = f.Vcpus, f.RAM
+	case *model.PremiumFlavor:
+		vcpus, ram = f.Vcpus, f.RAM
+	case *model.ProFlavor:
+		vcpus, ram = f.Vcpus, f.RAM
+	default:
+		return 0, 0, fmt.Errorf("unknown flavor type %T", flavor)
+	}
+
+	cpuCount, err := strconv.Atoi(strings.TrimSpace(vcpus))
+	if err != nil {
+		return 0, 0, fmt.Errorf("flavor has invalid vcpus %q", vcpus)
+	}
+
+	if gb, err := strconv.ParseFloat(strings.TrimSpace(ram), 64); err == nil {
+		return cpuCount, gb, nil
+	}
+
+	quantity, err := resource.ParseQuantity(strings.TrimSpace(ram))
+	if err != nil {
+		return 0, 0, fmt.Errorf("flavor has invalid ram %q", ram)
+	}
+
+	return cpuCount, float64(quantity.Value()) / (1 << 30), nil
+}
+
+// flavorViolations возвращает требования к vCPU и RAM, которым не удовлетворяет flavor
+func flavorViolations(image *model.Image, flavor model.Flavor) ([]Violation, error) {
+	vcpus, ramGB, err := Resources(flavor)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []Violation
+	if image.CPU != nil && vcpus < int(image.CPU.Min) {
+		violations = append(violations, Violation{Field: "instanceType", Requirement: "cpu", Min: image.CPU.Min, Actual: float64(vcpus)})
+	}
+	if image.RAMGb != nil && ramGB < float64(image.RAMGb.Min) {
+		violations = append(violations, Violation{Field: "instanceType", Requirement: "ram_gb", Min: image.RAMGb.Min, Actual: ramGB})
+	}
+
+	return violations, nil
+}
+
+// diskViolations возвращает требование к размеру диска, если диск слишком мал
+func diskViolations(image *model.Image, diskGB int32, field string) []Violation {
+	if image.DiskGb == nil || diskGB >= image.DiskGb.Min {
+		return nil
+	}
+
+	return []Violation{{Field: field, Requirement: "disk_gb", Min: image.DiskGb.Min, Actual: float64(diskGB)}}
+}
+
+// UnknownFlavor возвращает ошибку запроса с flavor, которого нет в каталоге
+func UnknownFlavor(instanceType string) error {
+	return &gqlerror.Error{
+		Message: fmt.Sprintf("unknown instance type %s", instanceType),
+		Extensions: map[string]interface{}{
+			"code":  CodeBadUserInput,
+			"field": "instanceType",
+		},
+	}
+}
+
+// newError собирает ошибку GraphQL из нарушенных требований
+func newError(image *model.Image, violations []Violation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+
+	details := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		details = append(details, fmt.Sprintf(detailFormats[violation.Requirement], violation.Actual, violation.Min))
+	}
+
+	return &gqlerror.Error{
+		Message: fmt.Sprintf("request does not meet the requirements of image %s: %s", image.ImageID, strings.Join(details, "; ")),
+		Extensions: map[string]interface{}{
+			"code":       CodeBadUserInput,
+			"violations": violations,
+		},
+	}
+}
@@ -0,0 +1,220 @@
+package requirements
+
+import (
+	"slices"
+	"testing"
+
+	"gqlfed/instances/graph/model"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+var testImage = &model.Image{
+	ImageID: "ubuntu",
+	CPU:     &model.MinRec{Min: 2, Rec: 4},
+	RAMGb:   &model.MinRec{Min: 4, Rec: 8},
+	DiskGb:  &model.MinRec{Min: 20, Rec: 40},
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		image   *model.Image
+		flavor  model.Flavor
+		diskGB  int32
+		want    []Violation
+		wantErr bool
+	}{
+		{
+			name:   "exactly at minimums",
+			image:  testImage,
+			flavor: &model.BaseFlavor{Vcpus: "2", RAM: "4"},
+			diskGB: 20,
+		},
+		{
+			name:   "above minimums",
+			image:  testImage,
+			flavor: &model.ProFlavor{Vcpus: "8", RAM: "32Gi"},
+			diskGB: 100,
+		},
+		{
+			name:   "every requirement violated",
+			image:  testImage,
+			flavor: &model.BaseFlavor{Vcpus: "1", RAM: "2"},
+			diskGB: 10,
+			want: []Violation{
+				{Field: "instanceType", Requirement: "cpu", Min: 2, Actual: 1},
+				{Field: "instanceType", Requirement: "ram_gb", Min: 4, Actual: 2},
+				{Field: "disk_gb", Requirement: "disk_gb", Min: 20, Actual: 10},
+			},
+		},
+		{
+			name:   "RAM in binary units",
+			image:  testImage,
+			flavor: &model.HiFreqFlavor{Vcpus: "2", RAM: "3584Mi"},
+			diskGB: 20,
+			want:   []Violation{{Field: "instanceType", Requirement: "ram_gb", Min: 4, Actual: 3.5}},
+		},
+		{
+			name:   "image without requirements",
+			image:  &model.Image{ImageID: "custom"},
+			flavor: &model.BaseFlavor{Vcpus: "1", RAM: "1"},
+			diskGB: 1,
+		},
+		{
+			name:    "invalid flavor",
+			image:   testImage,
+			flavor:  &model.BaseFlavor{Vcpus: "two", RAM: "4"},
+			diskGB:  20,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.image, tt.flavor, tt.diskGB)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Check() succeeded, want an error")
+				}
+				return
+			}
+			assertViolations(t, err, tt.want)
+		})
+	}
+}
+
+func TestCheckDisk(t *testing.T) {
+	tests := []struct {
+		name   string
+		sizeGB int32
+		want   []Violation
+	}{
+		{"large enough", 20, nil},
+		{"too small", 19, []Violation{{Field: "size_gb", Requirement: "disk_gb", Min: 20, Actual: 19}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertViolations(t, CheckDisk(testImage, tt.sizeGB), tt.want)
+		})
+	}
+}
+
+func TestCheckDiskSize(t *testing.T) {
+	tests := []struct {
+		name   string
+		sizeGB int32
+		want   []Violation
+	}{
+		{"positive", 1, nil},
+		{"zero", 0, []Violation{{Field: "disk_gb", Requirement: "disk_gb", Min: 1, Actual: 0}}},
+		{"negative", -5, []Violation{{Field: "disk_gb", Requirement: "disk_gb", Min: 1, Actual: -5}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertViolations(t, CheckDiskSize(tt.sizeGB, "disk_gb"), tt.want)
+		})
+	}
+}
+
+func TestResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		flavor    model.Flavor
+		wantVCPUs int
+		wantRAMGB float64
+		wantErr   bool
+	}{
+		{"plain gigabytes", &model.BaseFlavor{Vcpus: "2", RAM: "4"}, 2, 4, false},
+		{"fractional gigabytes", &model.BaseFlavor{Vcpus: " 1 ", RAM: "0.5"}, 1, 0.5, false},
+		{"quantity", &model.PremiumFlavor{Vcpus: "4", RAM: "8Gi"}, 4, 8, false},
+		{"invalid vcpus", &model.BaseFlavor{Vcpus: "", RAM: "4"}, 0, 0, true},
+		{"invalid ram", &model.BaseFlavor{Vcpus: "2", RAM: "lots"}, 0, 0, true},
+		{"no flavor", nil, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vcpus, ramGB, err := Resources(tt.flavor)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if vcpus != tt.wantVCPUs || ramGB != tt.wantRAMGB {
+				t.Errorf("Resources() = %d, %g, want %d, %g", vcpus, ramGB, tt.wantVCPUs, tt.wantRAMGB)
+			}
+		})
+	}
+}
+
+func TestCompatibleFlavors(t *testing.T) {
+	flavorList := []*model.KVStringListOfFlavor{
+		{Key: "base", Value: []model.Flavor{
+			&model.BaseFlavor{OriginalName: "base-1", Vcpus: "1", RAM: "2"},
+			&model.BaseFlavor{OriginalName: "base-2", Vcpus: "2", RAM: "4"},
+		}},
+		{Key: "small", Value: []model.Flavor{
+			&model.BaseFlavor{OriginalName: "tiny", Vcpus: "1", RAM: "1"},
+		}},
+		{Key: "pro", Value: []model.Flavor{
+			&model.ProFlavor{OriginalName: "pro-1", Vcpus: "8", RAM: "32"},
+		}},
+	}
+
+	got := CompatibleFlavors(testImage, flavorList)
+
+	var names []string
+	for _, category := range got {
+		for _, flavor := range category.Value {
+			names = append(names, category.Key+"/"+flavorName(t, flavor))
+		}
+	}
+	want := []string{"base/base-2", "pro/pro-1"}
+	if !slices.Equal(names, want) {
+		t.Errorf("CompatibleFlavors() = %v, want %v", names, want)
+	}
+}
+
+func flavorName(t *testing.T, flavor model.Flavor) string {
+	t.Helper()
+
+	switch f := flavor.(type) {
+	case *model.BaseFlavor:
+		return f.OriginalName
+	case *model.ProFlavor:
+		return f.OriginalName
+	}
+	t.Fatalf("unexpected flavor %T", flavor)
+	return ""
+}
+
+// assertViolations checks that err is a BAD_USER_INPUT error listing exactly
+// the given violations, or nil when there are none.
+func assertViolations(t *testing.T, err error, want []Violation) {
+	t.Helper()
+
+	if len(want) == 0 {
+		if err != nil {
+			t.Fatalf("error = %v, want nil", err)
+		}
+		return
+	}
+
+	gqlErr, ok := err.(*gqlerror.Error)
+	if !ok {
+		t.Fatalf("error = %v, want *gqlerror.Error", err)
+	}
+	if gqlErr.Extensions["code"] != CodeBadUserInput {
+		t.Errorf("code = %v, want %s", gqlErr.Extensions["code"], CodeBadUserInput)
+	}
+	got, _ := gqlErr.Extensions["violations"].([]Violation)
+	if len(got) != len(want) {
+		t.Fatalf("violations = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("violation %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -6,4 +6,4 @@ import (
 
 const DefaultPort = "4001"
 
-var Schema = graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{}})
+var Schema = graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{Backend: graph.NewMockBackend()}})
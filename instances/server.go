@@ -1,9 +1,15 @@
 package main
 
 import (
+	"fmt"
+	"gqlfed/instances/auth"
+	"gqlfed/instances/cozystack"
 	"gqlfed/instances/graph"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
@@ -18,25 +24,145 @@ import (
 	"github.com/rs/cors"
 )
 
-const defaultPort = "4001"
+const (
+	defaultPort           = "4001"
+	defaultNamespace      = "tenant-root"
+	defaultUploadProxyURL = "https://cdi-uploadproxy.cdi.svc"
+
+	// Image uploads larger than maxUploadMemory are buffered in temporary files
+	maxUploadSize   = 20 << 30
+	maxUploadMemory = 32 << 20
+)
+
+// newBackend picks the data source for the resolvers from the BACKEND
+// environment variable: "mock" (default) or "cozystack".
+func newBackend() (graph.Backend, error) {
+	switch backend := os.Getenv("BACKEND"); backend {
+	case "", "mock":
+		return graph.NewMockBackend(), nil
+	case "cozystack":
+		namespace := os.Getenv("COZYSTACK_NAMESPACE")
+		if namespace == "" {
+			namespace = defaultNamespace
+		}
+		uploadProxyURL := os.Getenv("CDI_UPLOADPROXY_URL")
+		if uploadProxyURL == "" {
+			uploadProxyURL = defaultUploadProxyURL
+		}
+		tenantNamespaces, err := cozystack.ParseTenantNamespaces(os.Getenv("TENANT_NAMESPACES"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid TENANT_NAMESPACES: %v", err)
+		}
+		// An empty KUBECONFIG falls back to the in-cluster config
+		return cozystack.NewInstanceManager(os.Getenv("KUBECONFIG"), namespace, cozystack.Options{
+			FlavorConfigPath:      os.Getenv("FLAVOR_CONFIG"),
+			FlavorConfigMap:       os.Getenv("FLAVOR_CONFIGMAP"),
+			DiscoverFlavors:       os.Getenv("FLAVOR_DISCOVERY") != "false",
+			QuotaConfigPath:       os.Getenv("QUOTA_CONFIG"),
+			QuotaConfigMap:        os.Getenv("QUOTA_CONFIGMAP"),
+			ImageConfigPath:       os.Getenv("IMAGE_CONFIG"),
+			ImageConfigMap:        os.Getenv("IMAGE_CONFIGMAP"),
+			GoldenImageNamespace:  os.Getenv("GOLDEN_IMAGE_NAMESPACE"),
+			UploadProxyURL:        uploadProxyURL,
+			UploadProxyInsecure:   os.Getenv("CDI_UPLOADPROXY_INSECURE") == "true",
+			TenantNamespacePrefix: os.Getenv("TENANT_NAMESPACE_PREFIX"),
+			TenantNamespaces:      tenantNamespaces,
+		})
+	default:
+		return nil, fmt.Errorf("unknown backend %q", backend)
+	}
+}
+
+// newVerifier loads the JWT keys from the environment. It returns nil when
+// AUTH_DISABLED is "true"; otherwise at least one key source is required.
+func newVerifier() (*auth.Verifier, error) {
+	if os.Getenv("AUTH_DISABLED") == "true" {
+		return nil, nil
+	}
+
+	return auth.NewVerifier(auth.Options{
+		JWKS:             os.Getenv("JWT_JWKS"),
+		HMACSecret:       os.Getenv("JWT_HMAC_SECRET"),
+		RSAPublicKeyFile: os.Getenv("JWT_RSA_PUBLIC_KEY"),
+		Issuer:           os.Getenv("JWT_ISSUER"),
+		Audience:         os.Getenv("JWT_AUDIENCE"),
+	})
+}
+
+// allowedOrigins reads the comma-separated CORS_ALLOWED_ORIGINS list; "*"
+// allows every origin.
+func allowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// checkOrigin accepts websocket upgrades from the listed origins, from the
+// server's own origin and from non-browser clients that send no Origin.
+func checkOrigin(origins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range origins {
+			if allowed == "*" || strings.EqualFold(allowed, origin) {
+				return true
+			}
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
 
 func main() {
 	port := defaultPort
 
+	backend, err := newBackend()
+	if err != nil {
+		log.Fatalf("failed to initialize backend: %v", err)
+	}
+
+	verifier, err := newVerifier()
+	if err != nil {
+		log.Fatalf("failed to initialize authentication (set AUTH_DISABLED=true to run without it): %v", err)
+	}
+	if verifier == nil {
+		log.Printf("authentication is disabled, every request is accepted")
+	}
+
+	origins := allowedOrigins()
+
 	router := chi.NewRouter()
 	// Add CORS middleware around every request
 	// See https://github.com/rs/cors for full option listing
-	router.Use(cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
+	corsOptions := cors.Options{
+		AllowedOrigins:   origins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		AllowCredentials: true,
-		Debug:            true,
-	}).Handler)
+		Debug:            false,
+	}
+	if len(origins) == 0 {
+		// An empty list would allow every origin; allow none instead
+		corsOptions.AllowOriginFunc = func(string) bool { return false }
+	}
+	router.Use(cors.New(corsOptions).Handler)
+	if verifier != nil {
+		router.Use(verifier.Middleware)
+	}
 
-	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{}}))
+	resolver := &graph.Resolver{Backend: backend}
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers:  resolver,
+		Directives: graph.DirectiveRoot{HasRole: resolver.HasRole},
+	}))
 
-	srv.AddTransport(transport.Websocket{
+	ws := transport.Websocket{
 		// Keep-alives are important for WebSockets to detect dead connections. This is
 		// not unlike asking a partner who seems to have zoned out while you tell them
 		// a story crucial to understanding the dynamics of your workplace: "Are you
@@ -52,18 +178,30 @@ func main() {
 		// you must check the origin of the request to prevent cross-site request forgery
 		// attacks.
 		Upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true
-			},
+			CheckOrigin: checkOrigin(origins),
 		},
-	})
+	}
+	if verifier != nil {
+		// Browsers cannot set headers on websocket connections, so the token
+		// comes in the connection_init payload instead
+		ws.InitFunc = verifier.WebsocketInit
+	}
+	srv.AddTransport(ws)
 
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{
+		MaxUploadSize: maxUploadSize,
+		MaxMemory:     maxUploadMemory,
+	})
 
 	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
 
+	if verifier != nil {
+		srv.AroundOperations(auth.RequireClaims)
+	}
+
 	srv.Use(extension.Introspection{})
 	srv.Use(extension.AutomaticPersistedQuery{
 		Cache: lru.New[string](100),
@@ -0,0 +1,69 @@
+package sshkey
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// minRSABits определяет минимальную длину RSA-ключа
+const minRSABits = 2048
+
+// allowedTypes содержит типы ключей, которые можно добавить
+var allowedTypes = map[string]bool{
+	ssh.KeyAlgoRSA:        true,
+	ssh.KeyAlgoED25519:    true,
+	ssh.KeyAlgoECDSA256:   true,
+	ssh.KeyAlgoECDSA384:   true,
+	ssh.KeyAlgoECDSA521:   true,
+	ssh.KeyAlgoSKED25519:  true,
+	ssh.KeyAlgoSKECDSA256: true,
+}
+
+// Key описывает проверенный публичный SSH-ключ
+type Key struct {
+	// PublicKey содержит ключ в формате authorized_keys без опций
+	PublicKey string
+	// Fingerprint содержит отпечаток ключа в формате SHA256:...
+	Fingerprint string
+}
+
+// Parse проверяет публичный ключ в формате authorized_keys и вычисляет его отпечаток
+func Parse(publicKey string) (*Key, error) {
+	publicKey = strings.TrimSpace(publicKey)
+	if strings.ContainsAny(publicKey, "\r\n") {
+		return nil, fmt.Errorf("public key must be a single line")
+	}
+
+	parsed, comment, options, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", err)
+	}
+
+	if len(options) > 0 {
+		return nil, fmt.Errorf("public key must not contain authorized_keys options")
+	}
+
+	if !allowedTypes[parsed.Type()] {
+		return nil, fmt.Errorf("unsupported key type %s", parsed.Type())
+	}
+
+	// Короткие RSA-ключи небезопасны
+	if cryptoKey, ok := parsed.(ssh.CryptoPublicKey); ok {
+		if rsaKey, ok := cryptoKey.CryptoPublicKey().(*rsa.PublicKey); ok && rsaKey.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits, got %d", minRSABits, rsaKey.N.BitLen())
+		}
+	}
+
+	normalized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(parsed)))
+	if comment != "" {
+		normalized += " " + comment
+	}
+
+	return &Key{
+		PublicKey:   normalized,
+		Fingerprint: ssh.FingerprintSHA256(parsed),
+	}, nil
+}
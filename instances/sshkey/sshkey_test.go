@@ -0,0 +1,94 @@
+package sshkey
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	ed25519Key = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIC1pfYIg3pNj84jHlBv4WkW7ptBKmSQ1eBUCB+OTeQph"
+	ecdsaKey   = "ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBPnXTVpsD1b3BNp5hQBfAM2B7/LgydD3SchnFAXpZTpZ1O/aEcBXo13/c1goQp8o5luN+Sp4Kcs1s2J+9gVnM0U="
+	rsa2048Key = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQCHIfPIP6uN3b1/GgsuSNmTQgix8MB9JBK6gxSzKNxj7jswEEUN1C4JLeXG3QmwmD9oD+7Js4wi7DXuwMtMYYB1YJ5cXM3cbgigqWBUvTIENk0kwnxz5QjFxMTRq/x6fOn1KTXi5oR7ClAOcS7RA8cvwefI8GR9i/THV/Jb1oQ3AdVQPI2rfY4gscMvIQj7/gCseFC7S7D3WK08KEWyevO+OdoK3b7RQVmmCSllE4NrwIYhXWiVP9AGrRU1CbdgauG6hvoIOWHR5ModZBHYn2V6U6R+CGZ7co50PqIxQxwggotYUc2ZJBd7Dl7Q4BI5yuv35RmitfOU3Q+nt4lb3g+7"
+	rsa1024Key = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAAgQD3Dkx+kuLj/sr0BcseTkGeySkjZfumKYX4dTnz4RTMT13i92VKSxFT8vwLf8jFiEk4eN0sBaRJ8P++mbOiDSIyMlK2ZLClHSYarSasVOR8oUeRZpI+uGKw2h+nXvVPJRR1Rm6AMWTABmfjiiPjxWbf+yzXd0pCVboOlkL2vhvtDw=="
+	dsaKey     = "ssh-dss AAAAB3NzaC1kc3MAAACBAPJx+Cd0+8CrP/aMk/jczWIikcP4m+qXJG7mj4rYZiIhf28tvPZSQxOhsAvV6Ok4IE2JR0fXCgyTLk1NFd7RfqJvkbgVouQG6umxfqbOaEDUhMI7fIpSMPkOZ3atvpo9xukf7KqeDAlESFmBJ5mVneRri01kE1at8VB7O2pZD7d7AAAAFQD/rDNZpN9BuO0Grnv+3LBmDV9fhQAAAIEA17Yai1VYDrjJGMIw3zsvYt2d26YS0ZyTexlFYTCBUBDUybf3z9kev+0A2WD4AoCSehtLX3mYBlJlbTt1MtRvfD11m6YO0ZwLT6Rk5Kw/TuyzarHDHpP7neCI4ihEFxqlYsyT4fGtu/2v7kmVS5tMR30zRed6v3W9W2jPHWZJpq0AAACADzDeAZgSTtpLZbwmLtieCzRoCHpib5cKym/tO3Cmi9/+lang0w0DUaaIogC8jINng7j7kNsjzaL0PwhWEUSDrkAqAAiEXQBk9IK6+3pUFpmdDjhbd1JGRRP/NgSCuCOMP1r4+loqlVEgEELaj2zvPEu/XokXjQUt4yrTiVk8lW4="
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name            string
+		publicKey       string
+		wantPublicKey   string
+		wantFingerprint string
+		wantErr         string
+	}{
+		{
+			name:            "ed25519 with comment",
+			publicKey:       ed25519Key + " alice@laptop",
+			wantPublicKey:   ed25519Key + " alice@laptop",
+			wantFingerprint: "SHA256:w+lOX1p7jRiixEebDDUfFDvHCXruyxmwBDQqAg7InKU",
+		},
+		{
+			name:            "surrounding whitespace is trimmed",
+			publicKey:       "  " + ecdsaKey + "\n",
+			wantPublicKey:   ecdsaKey,
+			wantFingerprint: "SHA256:1PG1T3zILUbfigZny6x6SoJ7b8j+3wi55fiRe2+b2x0",
+		},
+		{
+			name:            "rsa 2048",
+			publicKey:       rsa2048Key,
+			wantPublicKey:   rsa2048Key,
+			wantFingerprint: "SHA256:u57y64Uf9P4krorZ8haXRyNBg7Vtsi2mazk+BZwKjio",
+		},
+		{
+			name:      "rsa shorter than 2048 bits",
+			publicKey: rsa1024Key,
+			wantErr:   "RSA key must be at least 2048 bits, got 1024",
+		},
+		{
+			name:      "unsupported type",
+			publicKey: dsaKey,
+			wantErr:   "unsupported key type ssh-dss",
+		},
+		{
+			name:      "authorized_keys options",
+			publicKey: `command="/bin/true" ` + ed25519Key,
+			wantErr:   "must not contain authorized_keys options",
+		},
+		{
+			name:      "several lines",
+			publicKey: ed25519Key + "\n" + ecdsaKey,
+			wantErr:   "must be a single line",
+		},
+		{
+			name:      "not a key",
+			publicKey: "ssh-ed25519 not-base64",
+			wantErr:   "invalid public key",
+		},
+		{
+			name:      "empty",
+			publicKey: "",
+			wantErr:   "invalid public key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.publicKey)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got.PublicKey != tt.wantPublicKey {
+				t.Errorf("Parse() PublicKey = %q, want %q", got.PublicKey, tt.wantPublicKey)
+			}
+			if got.Fingerprint != tt.wantFingerprint {
+				t.Errorf("Parse() Fingerprint = %q, want %q", got.Fingerprint, tt.wantFingerprint)
+			}
+		})
+	}
+}
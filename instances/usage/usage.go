@@ -0,0 +1,35 @@
+package usage
+
+import (
+	"gqlfed/instances/graph/model"
+	"gqlfed/instances/requirements"
+)
+
+// Compute считает ресурсы, занятые инстансами и дисками проекта. Инстансы с
+// неизвестным flavor учитываются без vCPU и RAM; публичный адрес занимает
+// каждый инстанс, открытый наружу
+func Compute(instances []*model.Instance, disks []*model.Disk) *model.ProjectUsage {
+	usage := &model.ProjectUsage{Instances: int32(len(instances))}
+
+	for _, instance := range instances {
+		if vcpus, ramGB, err := requirements.Resources(instance.Flavor); err == nil {
+			usage.Vcpus += int32(vcpus)
+			usage.RAMGb += ramGB
+		}
+		if Exposed(instance) {
+			usage.PublicIps++
+		}
+	}
+
+	for _, disk := range disks {
+		usage.DiskGb += disk.SizeGb
+	}
+
+	return usage
+}
+
+// Exposed сообщает, занимает ли инстанс публичный адрес: в режиме WHOLE_IP
+// всегда, в режиме PORT_LIST - пока открыт хотя бы один порт
+func Exposed(instance *model.Instance) bool {
+	return instance.ExternalMethod == model.ExternalMethodWholeIP || len(instance.ExternalPorts) > 0
+}